@@ -0,0 +1,197 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"entgo.io/ent"
+	"github.com/99designs/gqlgen/graphql"
+	gen "github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/auditlog"
+)
+
+// auditedMutation is implemented by all generated mutations
+type auditedMutation interface {
+	ent.Mutation
+	Client() *gen.Client
+	ID() (int, bool)
+	IDs(ctx context.Context) ([]int, error)
+}
+
+// AuditHook records every change of an entity in the audit log together with identity of the user
+// and the GraphQL mutation making it. Changes touching only the ignored fields are not recorded,
+// those are the fields the resource manager maintains by itself.
+func AuditHook(ignoredFields ...string) ent.Hook {
+	ignored := make(map[string]bool, len(ignoredFields))
+	for _, name := range ignoredFields {
+		ignored[name] = true
+	}
+
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			mutation, ok := m.(auditedMutation)
+			if !ok || onlyIgnoredFields(m, ignored) {
+				return next.Mutate(ctx, m)
+			}
+			client := mutation.Client()
+
+			var ids []int
+			before := map[int]map[string]interface{}{}
+			if !m.Op().Is(ent.OpCreate) {
+				var err error
+				if ids, err = mutation.IDs(ctx); err != nil {
+					return nil, err
+				}
+				for _, id := range ids {
+					if before[id], err = auditSnapshot(ctx, client, m.Type(), id); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			value, err := next.Mutate(ctx, m)
+			if err != nil {
+				return value, err
+			}
+
+			if id, exists := mutation.ID(); exists && m.Op().Is(ent.OpCreate) {
+				ids = []int{id}
+			}
+			for _, id := range ids {
+				var after map[string]interface{}
+				if !m.Op().Is(ent.OpDelete | ent.OpDeleteOne) {
+					if after, err = auditSnapshot(ctx, client, m.Type(), id); err != nil {
+						return nil, err
+					}
+				}
+				if err := recordAudit(ctx, client, m, id, before[id], after, ignored); err != nil {
+					return nil, err
+				}
+			}
+			return value, nil
+		})
+	}
+}
+
+func onlyIgnoredFields(m ent.Mutation, ignored map[string]bool) bool {
+	if !m.Op().Is(ent.OpUpdate|ent.OpUpdateOne) ||
+		len(m.AddedEdges())+len(m.RemovedEdges())+len(m.ClearedEdges()) > 0 {
+		return false
+	}
+	for _, name := range append(m.Fields(), m.ClearedFields()...) {
+		if !ignored[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// auditSnapshot loads fields of an audited entity as they are serialized to JSON
+func auditSnapshot(ctx context.Context, client *gen.Client, entityType string, id int) (map[string]interface{}, error) {
+	var entity interface{}
+	var err error
+	switch entityType {
+	case gen.TypeResourcePool:
+		entity, err = client.ResourcePool.Get(ctx, id)
+	case gen.TypeResourceType:
+		entity, err = client.ResourceType.Get(ctx, id)
+	case gen.TypeAllocationStrategy:
+		entity, err = client.AllocationStrategy.Get(ctx, id)
+	case gen.TypeTag:
+		entity, err = client.Tag.Get(ctx, id)
+	case gen.TypePropertyType:
+		entity, err = client.PropertyType.Get(ctx, id)
+	default:
+		return nil, fmt.Errorf("Unable to audit changes of %s entities", entityType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load %s #%d for audit log: %w", entityType, id, err)
+	}
+
+	serialized, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to serialize %s #%d for audit log: %w", entityType, id, err)
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(serialized, &snapshot); err != nil {
+		return nil, fmt.Errorf("Unable to serialize %s #%d for audit log: %w", entityType, id, err)
+	}
+	delete(snapshot, "id")
+	delete(snapshot, "edges")
+	return snapshot, nil
+}
+
+// auditDiff keeps only fields that differ between the snapshots
+func auditDiff(before map[string]interface{}, after map[string]interface{},
+	ignored map[string]bool) (map[string]interface{}, map[string]interface{}) {
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	diff := func(name string) {
+		if ignored[name] || reflect.DeepEqual(before[name], after[name]) {
+			return
+		}
+		if value, ok := before[name]; ok {
+			changedBefore[name] = value
+		}
+		if value, ok := after[name]; ok {
+			changedAfter[name] = value
+		}
+	}
+	for name := range before {
+		diff(name)
+	}
+	for name := range after {
+		diff(name)
+	}
+	return changedBefore, changedAfter
+}
+
+func recordAudit(ctx context.Context, client *gen.Client, m ent.Mutation, id int,
+	before map[string]interface{}, after map[string]interface{}, ignored map[string]bool) error {
+	changedBefore, changedAfter := auditDiff(before, after, ignored)
+	for _, name := range m.RemovedEdges() {
+		changedBefore[name] = m.RemovedIDs(name)
+	}
+	for _, name := range m.AddedEdges() {
+		changedAfter[name] = m.AddedIDs(name)
+	}
+	if m.Op().Is(ent.OpUpdate|ent.OpUpdateOne) && len(changedBefore)+len(changedAfter) == 0 {
+		return nil
+	}
+
+	action := auditlog.ActionUpdate
+	if m.Op().Is(ent.OpCreate) {
+		action = auditlog.ActionCreate
+	} else if m.Op().Is(ent.OpDelete | ent.OpDeleteOne) {
+		action = auditlog.ActionDelete
+	}
+
+	create := client.AuditLog.Create().
+		SetEntityType(m.Type()).
+		SetEntityID(id).
+		SetAction(action).
+		SetBefore(changedBefore).
+		SetAfter(changedAfter)
+
+	if identity, err := GetIdentity(ctx); err == nil {
+		create.SetTenant(identity.Tenant).SetUser(identity.User).SetRoles(identity.Roles)
+	}
+	if graphql.HasOperationContext(ctx) {
+		create.SetOperationName(graphql.GetOperationContext(ctx).OperationName)
+	}
+	if field := graphql.GetFieldContext(ctx); field != nil {
+		for field.Parent != nil {
+			field = field.Parent
+		}
+		if field.Object == "Mutation" {
+			create.SetMutation(field.Field.Name).SetArguments(field.Args)
+		}
+	}
+
+	if err := create.Exec(ctx); err != nil {
+		return fmt.Errorf("Unable to record %s of %s #%d in audit log: %w", action, m.Type(), id, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// AuditLog holds the schema definition for the AuditLog entity.
+// An entry is recorded by AuditHook for every change of a pool, resource type, allocation strategy, tag or property type.
+// Entities are referenced by type and ID only, so that the log outlives deleted entities.
+type AuditLog struct {
+	ent.Schema
+}
+
+// Fields of the AuditLog.
+func (AuditLog) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("entity_type").
+			NotEmpty().
+			Immutable(),
+		field.Int("entity_id").
+			Immutable(),
+		field.Enum("action").
+			Values("create", "update", "delete").
+			Immutable(),
+		field.String("tenant").
+			Optional().
+			Immutable(),
+		field.String("user").
+			Optional().
+			Immutable(),
+		field.JSON("roles", []string{}).
+			Optional().
+			Immutable(),
+		field.String("operation_name").
+			Optional().
+			Immutable().
+			Comment("Name of the GraphQL operation, empty for anonymous operations and changes made outside of GraphQL"),
+		field.String("mutation").
+			Optional().
+			Immutable().
+			Comment("GraphQL mutation field causing the change"),
+		field.JSON("arguments", map[string]interface{}{}).
+			Optional().
+			Immutable().
+			Comment("Arguments of the GraphQL mutation field"),
+		field.JSON("before", map[string]interface{}{}).
+			Optional().
+			Immutable().
+			Comment("Changed fields before the change and IDs of removed edges, empty for created entities"),
+		field.JSON("after", map[string]interface{}{}).
+			Optional().
+			Immutable().
+			Comment("Changed fields after the change and IDs of added edges, empty for deleted entities"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+func (AuditLog) Indexes() []ent.Index {
+	return []ent.Index{
+		index.
+			Fields("entity_type", "entity_id"),
+		index.
+			Fields("user"),
+		index.
+			Fields("created_at"),
+	}
+}
+
+func (AuditLog) Policy() ent.Policy {
+	return ALWAYS_ALLOWED
+}
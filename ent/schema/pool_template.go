@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"entgo.io/contrib/entgql"
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+// PoolTemplate holds the schema definition for the PoolTemplate entity.
+// A template describes a pool, together with pools nested in it, to be created repeatedly.
+// Strings of the template can contain ${variable} placeholders replaced when the template is instantiated.
+type PoolTemplate struct {
+	ent.Schema
+}
+
+// Fields of the PoolTemplate.
+func (PoolTemplate) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").
+			NotEmpty().
+			Unique(),
+		field.Text("description").
+			Optional().
+			Nillable(),
+		field.Enum("pool_type").
+			Values("singleton", "set", "allocating"),
+		field.String("pool_name").
+			NotEmpty().
+			Comment("Name of the created pool, it should contain placeholders to keep pool names unique"),
+		field.Int("dealocation_safety_period").
+			Default(0),
+		field.JSON("pool_values", []map[string]interface{}{}).
+			Optional().
+			Comment("Values of created set and singleton pools"),
+		field.JSON("pool_property_types", map[string]interface{}{}).
+			Optional().
+			Comment("Types of pool properties of a created root allocating pool"),
+		field.JSON("pool_properties", map[string]interface{}{}).
+			Optional().
+			Comment("Default pool properties of a created root allocating pool, variables of the same name override them"),
+		field.JSON("tags", []string{}).
+			Optional(),
+		field.JSON("parent_claim", map[string]interface{}{}).
+			Optional().
+			Comment("User input of the claim providing parent resource of a nested template in the parent pool"),
+	}
+}
+
+// Edges of the PoolTemplate.
+func (PoolTemplate) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("resource_type", ResourceType.Type).
+			Unique().
+			Required(),
+		edge.To("allocation_strategy", AllocationStrategy.Type).
+			Unique(),
+		edge.To("children", PoolTemplate.Type).
+			Annotations(entgql.Bind()).
+			From("parent").
+			Unique(),
+	}
+}
+
+func (PoolTemplate) Policy() ent.Policy {
+	return RBAC
+}
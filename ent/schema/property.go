@@ -106,6 +106,12 @@ func (PropertyType) Policy() ent.Policy {
 	return RBAC
 }
 
+func (PropertyType) Hooks() []ent.Hook {
+	return []ent.Hook{
+		AuditHook(),
+	}
+}
+
 // Property defines the property schema.
 type Property struct {
 	ent.Schema
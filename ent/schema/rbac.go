@@ -101,16 +101,22 @@ func (generalRBACPolicy) EvalQuery(ctx context.Context, m ent.Query) error {
 }
 
 // EvalMutation grants access to user possessing admin roles or groups
-func (generalRBACPolicy) EvalMutation(ctx context.Context, m ent.Mutation) error {
+func (p generalRBACPolicy) EvalMutation(ctx context.Context, m ent.Mutation) error {
 	// Mutations allowed only for admins
+	return p.EvalOperation(ctx, m.Type())
+}
+
+// EvalOperation grants access to user possessing admin roles or groups.
+// Used to guard administrative operations on entities whose mutations are allowed to everyone.
+func (generalRBACPolicy) EvalOperation(ctx context.Context, operation string) error {
 	identity, err := GetIdentity(ctx)
 	if err != nil {
-		return fmt.Errorf("Identity context not found. User unauthorized to mutate: %q", m.Type())
+		return fmt.Errorf("Identity context not found. User unauthorized to mutate: %q", operation)
 	}
 	if isAllowed(identity) {
 		return nil
 	} else {
-		return fmt.Errorf("User unauthorized to mutate: %q", m.Type())
+		return fmt.Errorf("User unauthorized to mutate: %q", operation)
 	}
 }
 
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// ResourceEvent holds the schema definition for the ResourceEvent entity.
+// An event is recorded for every claim, reservation, commit of a reservation, free, bench, retire,
+// alternative ID update and move of a resource.
+// Resources and pools are referenced by ID only, so that the history outlives deleted resources.
+type ResourceEvent struct {
+	ent.Schema
+}
+
+// Fields of the ResourceEvent.
+func (ResourceEvent) Fields() []ent.Field {
+	return []ent.Field{
+		field.Enum("event").
+			Values("claim", "reserve", "commit", "free", "bench", "retire", "update_alternative_id", "move").
+			Immutable(),
+		field.Int("resource_id").
+			Immutable(),
+		field.Int("pool_id").
+			Immutable().
+			Comment("Pool the resource belongs to after the change"),
+		field.String("tenant").
+			Optional().
+			Immutable(),
+		field.String("user").
+			Optional().
+			Immutable(),
+		field.JSON("roles", []string{}).
+			Optional().
+			Immutable(),
+		field.Text("description").
+			Optional().
+			Nillable().
+			Immutable(),
+		field.JSON("before", map[string]interface{}{}).
+			Optional().
+			Immutable().
+			Comment("Status, properties, alternative ID and pool of the resource before the change, empty for created resources"),
+		field.JSON("after", map[string]interface{}{}).
+			Optional().
+			Immutable().
+			Comment("Status, properties, alternative ID and pool of the resource after the change, empty for deleted resources"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+func (ResourceEvent) Indexes() []ent.Index {
+	return []ent.Index{
+		index.
+			Fields("resource_id"),
+		index.
+			Fields("pool_id"),
+		index.
+			Fields("user"),
+		index.
+			Fields("created_at"),
+	}
+}
+
+func (ResourceEvent) Policy() ent.Policy {
+	return ALWAYS_ALLOWED
+}
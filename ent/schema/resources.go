@@ -14,6 +14,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"github.com/net-auto/resourceManager/ent/hook"
 )
 
 // ResourceType holds the schema definition for the ResourceType entity.
@@ -45,6 +46,12 @@ func (ResourceType) Policy() ent.Policy {
 	return RBAC
 }
 
+func (ResourceType) Hooks() []ent.Hook {
+	return []ent.Hook{
+		AuditHook(),
+	}
+}
+
 type Tag struct {
 	ent.Schema
 }
@@ -64,6 +71,12 @@ func (Tag) Edges() []ent.Edge {
 	}
 }
 
+func (Tag) Hooks() []ent.Hook {
+	return []ent.Hook{
+		AuditHook(),
+	}
+}
+
 type AllocationStrategy struct {
 	ent.Schema
 }
@@ -91,6 +104,8 @@ func (AllocationStrategy) Edges() []ent.Edge {
 			Ref("allocation_strategy"),
 		edge.To("pool_property_types", PropertyType.Type).
 			Annotations(entgql.Bind()),
+		edge.To("revisions", AllocationStrategyRevision.Type),
+		edge.To("test_cases", StrategyTestCase.Type),
 	}
 }
 
@@ -98,6 +113,13 @@ func (AllocationStrategy) Policy() ent.Policy {
 	return RBAC
 }
 
+func (AllocationStrategy) Hooks() []ent.Hook {
+	return []ent.Hook{
+		AuditHook(),
+		hook.On(initialRevisionHook, ent.OpCreate),
+	}
+}
+
 // ResourcePool holds the schema definition for the Resource pool entity.
 type ResourcePool struct {
 	ent.Schema
@@ -123,6 +145,33 @@ func (ResourcePool) Fields() []ent.Field {
 			Comment("How long to keep resources unavailable after dealocation (in seconds)." +
 				" -1 release never, 0 release immediately").
 			Annotations(entgql.OrderField("dealocationSafetyPeriod")),
+		field.Enum("lifecycle_state").
+			Values("active", "draining", "frozen").
+			Default("active").
+			Comment("active pools allow everything, draining pools reject new claims but allow freeing resources," +
+				" frozen pools are read-only"),
+		field.Float("utilization_warning_threshold").
+			Optional().
+			Nillable().
+			Comment("Utilization in percent at which the pool reaches the warning level, disabled if not set"),
+		field.Float("utilization_critical_threshold").
+			Optional().
+			Nillable().
+			Comment("Utilization in percent at which the pool reaches the critical level, disabled if not set"),
+		field.Enum("utilization_level").
+			Values("ok", "warning", "critical").
+			Default("ok").
+			Comment("Utilization level computed after the last claim or free in the pool"),
+		field.String("free_capacity").
+			Optional().
+			Nillable().
+			Comment("Free capacity as a decimal integer, maintained by claims and frees in the pool." +
+				" Computed on first read if not set"),
+		field.String("utilized_capacity").
+			Optional().
+			Nillable().
+			Comment("Utilized capacity as a decimal integer, maintained by claims and frees in the pool." +
+				" Computed on first read if not set"),
 	}
 }
 
@@ -146,6 +195,9 @@ func (ResourcePool) Edges() []ent.Edge {
 			Ref("nested_pool").
 			Comment("pool hierarchies can use this link between resource and pool").
 			Unique(),
+		edge.To("strategy_revision", AllocationStrategyRevision.Type).
+			Comment("revision of the allocation strategy the pool runs, the latest revision if not set").
+			Unique(),
 	}
 }
 
@@ -166,6 +218,13 @@ func (ResourcePool) Policy() ent.Policy {
 	return RBAC
 }
 
+func (ResourcePool) Hooks() []ent.Hook {
+	return []ent.Hook{
+		// capacity and utilization level are updated by every claim and free in the pool
+		AuditHook("free_capacity", "utilized_capacity", "utilization_level"),
+	}
+}
+
 // Resource holds the schema definition for the Resource entity.
 type Resource struct {
 	ent.Schema
@@ -175,7 +234,7 @@ type Resource struct {
 func (Resource) Fields() []ent.Field {
 	return []ent.Field{
 		field.Enum("status").
-			Values("free", "claimed", "retired", "bench"),
+			Values("free", "claimed", "retired", "bench", "reserved"),
 		field.Text("description").
 			Optional().
 			Nillable(),
@@ -184,6 +243,11 @@ func (Resource) Fields() []ent.Field {
 		field.Time("updated_at").
 			Default(time.Now).
 			UpdateDefault(time.Now),
+		field.Time("lease_expires_at").
+			Optional().
+			Nillable().
+			Comment("Claimed resource is freed and reserved resource is returned to its pool automatically after this time." +
+				" No expiration if not set"),
 	}
 }
 
@@ -206,6 +270,8 @@ func (Resource) Indexes() []ent.Index {
 	return []ent.Index{
 		index.
 			Edges("pool"),
+		index.
+			Fields("lease_expires_at"),
 	}
 }
 
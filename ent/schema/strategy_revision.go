@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	gen "github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategyrevision"
+	"github.com/net-auto/resourceManager/ent/hook"
+)
+
+// AllocationStrategyRevision holds the schema definition for the AllocationStrategyRevision entity.
+// Revisions are immutable, updating a strategy stores a new revision. Pools are pinned to a revision
+// once their strategy is updated, pools which are not pinned run the latest revision.
+type AllocationStrategyRevision struct {
+	ent.Schema
+}
+
+// Fields of the AllocationStrategyRevision.
+func (AllocationStrategyRevision) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("revision").
+			Positive().
+			Immutable(),
+		field.Enum("lang").
+			Values("py", "js", "go").
+			Immutable(),
+		field.Text("script").
+			NotEmpty().
+			Immutable(),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+// Edges of the AllocationStrategyRevision.
+func (AllocationStrategyRevision) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("allocation_strategy", AllocationStrategy.Type).
+			Ref("revisions").
+			Unique().
+			Required(),
+		edge.From("pools", ResourcePool.Type).
+			Ref("strategy_revision"),
+	}
+}
+
+func (AllocationStrategyRevision) Indexes() []ent.Index {
+	return []ent.Index{
+		index.
+			Fields("revision").
+			Edges("allocation_strategy").
+			Unique(),
+	}
+}
+
+func (AllocationStrategyRevision) Policy() ent.Policy {
+	return RBAC
+}
+
+// initialRevisionHook stores the script of a created allocation strategy as its first revision
+func initialRevisionHook(next ent.Mutator) ent.Mutator {
+	return hook.AllocationStrategyFunc(func(ctx context.Context, m *gen.AllocationStrategyMutation) (ent.Value, error) {
+		value, err := next.Mutate(ctx, m)
+		if err != nil {
+			return value, err
+		}
+		strat := value.(*gen.AllocationStrategy)
+		if err := m.Client().AllocationStrategyRevision.Create().
+			SetRevision(1).
+			SetLang(allocationstrategyrevision.Lang(strat.Lang)).
+			SetScript(strat.Script).
+			SetAllocationStrategy(strat).
+			Exec(ctx); err != nil {
+			return nil, fmt.Errorf("Unable to store first revision of allocation strategy %s: %w", strat.Name, err)
+		}
+		return strat, nil
+	})
+}
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// StrategyTestCase holds the schema definition for the StrategyTestCase entity.
+// A test case runs the allocation strategy with given pool properties, current resources and user input
+// and expects either the output or an error.
+type StrategyTestCase struct {
+	ent.Schema
+}
+
+// Fields of the StrategyTestCase.
+func (StrategyTestCase) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").
+			NotEmpty(),
+		field.JSON("pool_properties", map[string]interface{}{}),
+		field.JSON("current_resources", []map[string]interface{}{}).
+			Comment("Properties of resources claimed from the pool before the strategy runs"),
+		field.JSON("user_input", map[string]interface{}{}),
+		field.JSON("expected_output", map[string]interface{}{}).
+			Optional(),
+		field.String("expected_error").
+			Optional().
+			Nillable().
+			Comment("The strategy is expected to fail with an error containing this text"),
+	}
+}
+
+// Edges of the StrategyTestCase.
+func (StrategyTestCase) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("allocation_strategy", AllocationStrategy.Type).
+			Ref("test_cases").
+			Unique().
+			Required(),
+	}
+}
+
+func (StrategyTestCase) Indexes() []ent.Index {
+	return []ent.Index{
+		index.
+			Fields("name").
+			Edges("allocation_strategy").
+			Unique(),
+	}
+}
+
+func (StrategyTestCase) Policy() ent.Policy {
+	return RBAC
+}
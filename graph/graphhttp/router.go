@@ -10,6 +10,7 @@ import (
 	"net/http"
 
 	"github.com/net-auto/resourceManager/graph/graphql"
+	"github.com/net-auto/resourceManager/server/lock"
 	"github.com/net-auto/resourceManager/viewer"
 
 	"github.com/gorilla/mux"
@@ -19,7 +20,8 @@ type routerConfig struct {
 	viewer struct {
 		tenancy viewer.Tenancy
 	}
-	logger log.Logger
+	logger         log.Logger
+	lockingService lock.LockingService
 }
 
 func newRouter(cfg routerConfig) (*mux.Router, error) {
@@ -32,7 +34,8 @@ func newRouter(cfg routerConfig) (*mux.Router, error) {
 
 	handler, err := graphql.NewHandler(
 		graphql.HandlerConfig{
-			Logger: cfg.logger,
+			Logger:         cfg.logger,
+			LockingService: cfg.lockingService,
 		},
 	)
 	if err != nil {
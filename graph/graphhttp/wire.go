@@ -10,6 +10,7 @@ package graphhttp
 import (
 	"github.com/net-auto/resourceManager/logging/log"
 	"github.com/net-auto/resourceManager/server"
+	"github.com/net-auto/resourceManager/server/lock"
 	"github.com/net-auto/resourceManager/server/xserver"
 	"github.com/net-auto/resourceManager/telemetry"
 	"net/http"
@@ -22,10 +23,11 @@ import (
 
 // Config defines the http server config.
 type Config struct {
-	Tenancy      viewer.Tenancy
-	Logger       log.Logger
-	Telemetry    telemetry.Config
-	HealthChecks []health.Checker
+	Tenancy        viewer.Tenancy
+	Logger         log.Logger
+	Telemetry      telemetry.Config
+	HealthChecks   []health.Checker
+	LockingService lock.LockingService
 }
 
 // NewServer creates a server from config.
@@ -41,7 +43,7 @@ func NewServer(cfg Config) (*server.Server, func(), error) {
 }
 
 func newRouterConfig(config Config) (cfg routerConfig, err error) {
-	cfg = routerConfig{logger: config.Logger}
+	cfg = routerConfig{logger: config.Logger, lockingService: config.LockingService}
 	cfg.viewer.tenancy = config.Tenancy
 	return cfg, nil
 }
@@ -9,6 +9,7 @@ package graphhttp
 import (
 	"github.com/net-auto/resourceManager/logging/log"
 	"github.com/net-auto/resourceManager/server"
+	"github.com/net-auto/resourceManager/server/lock"
 	"github.com/net-auto/resourceManager/server/xserver"
 	"github.com/net-auto/resourceManager/telemetry"
 	"github.com/net-auto/resourceManager/viewer"
@@ -63,14 +64,15 @@ var (
 
 // Config defines the http server config.
 type Config struct {
-	Tenancy      viewer.Tenancy
-	Logger       log.Logger
-	Telemetry    telemetry.Config
-	HealthChecks []health.Checker
+	Tenancy        viewer.Tenancy
+	Logger         log.Logger
+	Telemetry      telemetry.Config
+	HealthChecks   []health.Checker
+	LockingService lock.LockingService
 }
 
 func newRouterConfig(config Config) (cfg routerConfig, err error) {
-	cfg = routerConfig{logger: config.Logger}
+	cfg = routerConfig{logger: config.Logger, lockingService: config.LockingService}
 	cfg.viewer.tenancy = config.Tenancy
 	return cfg, nil
 }
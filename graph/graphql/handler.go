@@ -45,6 +45,8 @@ import (
 type HandlerConfig struct {
 	Client *ent.Client
 	Logger log.Logger
+	// LockingService locks pools during mutations, shared with other writers of the pools such as the lease reaper
+	LockingService lock.LockingService
 }
 
 const Infinite = 1<<(bits.UintSize-1) - 1
@@ -66,6 +68,28 @@ func init() {
 	}
 }
 
+// serializableMutations change resources or capacity counters of pools, they run in serializable transactions
+// so that concurrent changes of the same pool never overwrite each other
+var serializableMutations = map[string]bool{
+	"ClaimResource":           true,
+	"ClaimResourceWithAltId":  true,
+	"ClaimResources":          true,
+	"ReserveResource":         true,
+	"CancelReservation":       true,
+	"FreeResource":            true,
+	"FreeResources":           true,
+	"MoveResource":            true,
+	"UpdateResourcePool":      true,
+	"AddSetPoolValues":        true,
+	"RemoveSetPoolValues":     true,
+	"RecomputePoolCapacity":   true,
+	"UpgradePoolStrategy":     true,
+	"InstantiatePoolTemplate": true,
+	"UnbenchResources":        true,
+	"UnretireResources":       true,
+	"ForceFreeResources":      true,
+}
+
 // Modified OpenTxFromContext from ent
 func OpenTxFromContext(ctx context.Context) (context.Context, driver.Tx, error) {
 	client := ent.FromContext(ctx)
@@ -78,7 +102,7 @@ func OpenTxFromContext(ctx context.Context) (context.Context, driver.Tx, error)
 		for i := 0; i < len(opCtx.Operation.SelectionSet); i++ {
 			field, ok := opCtx.Operation.SelectionSet[i].(*ast.Field)
 			if ok {
-				if field.Name == "ClaimResource" {
+				if serializableMutations[field.Name] {
 					txOptions = sql.TxOptions{
 						Isolation: sql.LevelSerializable,
 					}
@@ -138,7 +162,11 @@ func NewHandler(cfg HandlerConfig) (http.Handler, error) {
 
 	// Add locking service (to prevent race conditions while claiming resources) before transactioner
 	// So that transaction is encapsulated by locking service to prevent transaction serialization issues
-	srv.Use(lock.NewLockRequestInterceptor(lock.NewLockingService(time.Minute, nil)))
+	lockingService := cfg.LockingService
+	if lockingService == nil {
+		lockingService = lock.NewLockingService(time.Minute, nil)
+	}
+	srv.Use(lock.NewLockRequestInterceptor(lockingService))
 	srv.Use(entgql.Transactioner{
 		TxOpener: entgql.TxOpenerFunc(openAndExposeTx),
 	})
@@ -0,0 +1,75 @@
+package resolver_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/auditlog"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	"github.com/net-auto/resourceManager/graph/graphql/resolver"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestAuditLog(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	ctx := ent.NewContext(s.ctx, s.client)
+	res := resolver.New(resolver.Config{})
+	mutation := res.Mutation()
+
+	created, err := mutation.CreateTag(ctx, model.CreateTagInput{TagText: "edge"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagID := created.Tag.ID
+
+	// simulate the GraphQL request executing the resolver
+	updateInput := model.UpdateTagInput{TagID: tagID, TagText: "core"}
+	updateCtx := graphql.WithOperationContext(ctx, &graphql.OperationContext{OperationName: "renameTag"})
+	updateCtx = graphql.WithFieldContext(updateCtx, &graphql.FieldContext{
+		Object: "Mutation",
+		Field:  graphql.CollectedField{Field: &ast.Field{Name: "UpdateTag"}},
+		Args:   map[string]interface{}{"input": updateInput},
+	})
+	if _, err := mutation.UpdateTag(updateCtx, updateInput); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mutation.DeleteTag(ctx, model.DeleteTagInput{TagID: tagID}); err != nil {
+		t.Fatal(err)
+	}
+
+	entityType := "Tag"
+	entries, err := res.Query().QueryAuditLog(ctx, &entityType, &tagID, nil, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries.Edges) != 3 {
+		t.Fatalf("Expected create, update and delete of the tag, got %d entries", len(entries.Edges))
+	}
+
+	create, update, del := entries.Edges[0].Node, entries.Edges[1].Node, entries.Edges[2].Node
+	assert.Equal(t, auditlog.ActionCreate, create.Action)
+	assert.Equal(t, map[string]interface{}{"tag": "edge"}, create.After)
+	assert.Equal(t, "fb-user", create.User)
+	assert.Equal(t, "fb", create.Tenant)
+
+	assert.Equal(t, auditlog.ActionUpdate, update.Action)
+	assert.Equal(t, map[string]interface{}{"tag": "edge"}, update.Before)
+	assert.Equal(t, map[string]interface{}{"tag": "core"}, update.After)
+	assert.Equal(t, "renameTag", update.OperationName)
+	assert.Equal(t, "UpdateTag", update.Mutation)
+	assert.Equal(t, "core", update.Arguments["input"].(map[string]interface{})["tagText"])
+
+	assert.Equal(t, auditlog.ActionDelete, del.Action)
+	assert.Equal(t, map[string]interface{}{"tag": "core"}, del.Before)
+	assert.Empty(t, del.After)
+
+	otherUser := "someone-else"
+	entries, err = res.Query().QueryAuditLog(ctx, nil, nil, &otherUser, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, entries.TotalCount)
+}
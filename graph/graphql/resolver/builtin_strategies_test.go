@@ -0,0 +1,281 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/allocationstrategyrevision"
+	"github.com/net-auto/resourceManager/ent/propertytype"
+	"github.com/net-auto/resourceManager/ent/resourcetype"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	"github.com/net-auto/resourceManager/graph/graphql/resolver"
+	pools2 "github.com/net-auto/resourceManager/pools"
+	pools "github.com/net-auto/resourceManager/pools/allocating_strategies"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinStrategiesMigratedToGo(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+
+	// route_distinguisher loaded by a version running it as js
+	rdProperty := s.client.PropertyType.Create().
+		SetName("rd").
+		SetType(propertytype.TypeString).
+		SaveX(s.ctx)
+	s.client.ResourceType.Create().
+		SetName("route_distinguisher").
+		AddPropertyTypes(rdProperty).
+		SaveX(s.ctx)
+	rd := s.client.AllocationStrategy.Create().
+		SetName("route_distinguisher").
+		SetLang(allocationstrategy.LangJs).
+		SetScript("old script").
+		SaveX(s.ctx)
+
+	// vlan_range loaded by a version without strategy revisions
+	s.client.ResourceType.Create().
+		SetName("vlan_range").
+		SaveX(s.ctx)
+	vlanRange := s.client.AllocationStrategy.Create().
+		SetName("vlan_range").
+		SetLang(allocationstrategy.LangJs).
+		SetScript("legacy script").
+		SaveX(s.ctx)
+	s.client.AllocationStrategyRevision.Delete().
+		Where(allocationstrategyrevision.HasAllocationStrategyWith(allocationstrategy.ID(vlanRange.ID))).
+		ExecX(s.ctx)
+
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"vlan_range", "route_distinguisher", "random_signed_int32"} {
+		strat := s.client.AllocationStrategy.Query().Where(allocationstrategy.Name(name)).OnlyX(s.ctx)
+		assert.Equal(t, allocationstrategy.LangGo, strat.Lang, name)
+	}
+
+	revisions := rd.QueryRevisions().Order(ent.Asc(allocationstrategyrevision.FieldRevision)).AllX(s.ctx)
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, allocationstrategyrevision.LangJs, revisions[0].Lang)
+		assert.Equal(t, "old script", revisions[0].Script)
+		assert.Equal(t, allocationstrategyrevision.LangGo, revisions[1].Lang)
+	}
+
+	revisions = vlanRange.QueryRevisions().Order(ent.Asc(allocationstrategyrevision.FieldRevision)).AllX(s.ctx)
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, 1, revisions[0].Revision)
+		assert.Equal(t, allocationstrategyrevision.LangJs, revisions[0].Lang)
+		assert.Equal(t, "legacy script", revisions[0].Script)
+		assert.Equal(t, 2, revisions[1].Revision)
+		assert.Equal(t, allocationstrategyrevision.LangGo, revisions[1].Lang)
+	}
+}
+
+type ticketStrategy struct {
+	currentResources []map[string]interface{}
+}
+
+func (ticket *ticketStrategy) Invoke() (map[string]interface{}, error) {
+	return map[string]interface{}{"ticket": float64(len(ticket.currentResources) + 1)}, nil
+}
+
+func (ticket *ticketStrategy) Capacity() (map[string]interface{}, error) {
+	return map[string]interface{}{"freeCapacity": "100", "utilizedCapacity": "0"}, nil
+}
+
+func init() {
+	// go strategy compiled in by an embedder of the resource manager
+	if err := pools2.RegisterGoStrategy(pools2.GoStrategyDefinition{
+		Name:         "test_ticket",
+		ResourceType: "ticket",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) pools2.GoStrategy {
+			return &ticketStrategy{currentResources}
+		},
+		ResourcePropertyTypes: []pools2.GoStrategyProperty{{Name: "ticket", Type: propertytype.TypeInt}},
+		PoolPropertyTypes:     []pools2.GoStrategyProperty{{Name: "queue", Type: propertytype.TypeString, Default: "default"}},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisteredGoStrategies(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	ctx := ent.NewContext(s.ctx, s.client)
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+
+	ticketType := s.client.ResourceType.Query().Where(resourcetype.Name("ticket")).OnlyX(s.ctx)
+	assert.Equal(t, "ticket", ticketType.QueryPropertyTypes().OnlyX(s.ctx).Name)
+	ticket := s.client.AllocationStrategy.Query().Where(allocationstrategy.Name("test_ticket")).OnlyX(s.ctx)
+	assert.Equal(t, allocationstrategy.LangGo, ticket.Lang)
+	queue := ticket.QueryPoolPropertyTypes().OnlyX(s.ctx)
+	assert.Equal(t, "default", *queue.StringVal)
+
+	mutation := resolver.New(resolver.Config{}).Mutation()
+	if _, err := mutation.CreateAllocationStrategy(ctx, &model.CreateAllocationStrategyInput{
+		Name: "custom_vlan", Script: "vlan", Lang: allocationstrategy.LangGo}); err == nil {
+		t.Fatalf("Creating a go strategy should fail")
+	}
+	if _, err := mutation.UpdateAllocationStrategy(ctx, model.UpdateAllocationStrategyInput{
+		AllocationStrategyID: ticket.ID, Script: "changed"}); err == nil {
+		t.Fatalf("Updating a go strategy should fail")
+	}
+}
+
+func TestMacAddressPool(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	ctx := ent.NewContext(s.ctx, s.client)
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+	macType := s.client.ResourceType.Query().Where(resourcetype.Name("mac_address")).OnlyX(s.ctx)
+	macStrategy := s.client.AllocationStrategy.Query().Where(allocationstrategy.Name("mac_address")).OnlyX(s.ctx)
+
+	mutation := resolver.New(resolver.Config{}).Mutation()
+	created, err := mutation.CreateAllocatingPool(ctx, &model.CreateAllocatingPoolInput{
+		AllocationStrategyID: macStrategy.ID,
+		PoolName:             "vrrp",
+		ResourceTypeID:       macType.ID,
+		PoolProperties: map[string]interface{}{
+			"prefix": "02:00:5e", "from": 1, "to": 3, "locally_administered": true, "unicast": true},
+		PoolPropertyTypes: map[string]interface{}{
+			"prefix": "string", "from": "int", "to": "int", "locally_administered": "bool", "unicast": "bool"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolID := created.Pool.ID
+
+	claimed, err := mutation.ClaimResource(ctx, poolID, nil, map[string]interface{}{"desiredValue": "02:00:5e:00:00:02"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "02:00:5e:00:00:02", *claimed.QueryProperties().OnlyX(s.ctx).StringVal)
+
+	for _, expected := range []string{"02:00:5e:00:00:01", "02:00:5e:00:00:03"} {
+		claimed, err := mutation.ClaimResource(ctx, poolID, nil, map[string]interface{}{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, *claimed.QueryProperties().OnlyX(s.ctx).StringVal)
+	}
+	if _, err := mutation.ClaimResource(ctx, poolID, nil, map[string]interface{}{}, nil); err == nil {
+		t.Fatalf("Claiming from a full MAC address pool should fail")
+	}
+}
+
+func TestUniqueIdPoolClaimAndFree(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+	uniqueIdType := s.client.ResourceType.Query().Where(resourcetype.Name("unique_id")).OnlyX(s.ctx)
+	uniqueIdStrategy := s.client.AllocationStrategy.Query().Where(allocationstrategy.Name("unique_id")).OnlyX(s.ctx)
+	mutation := resolver.New(resolver.Config{}).Mutation()
+
+	ctx, tx := mutationContext(t, s)
+	created, err := mutation.CreateAllocatingPool(ctx, &model.CreateAllocatingPoolInput{
+		AllocationStrategyID: uniqueIdStrategy.ID,
+		PoolName:             "ids",
+		ResourceTypeID:       uniqueIdType.ID,
+		PoolProperties:       map[string]interface{}{"from": 1, "to": 10, "idFormat": "id-{counter}"},
+		PoolPropertyTypes:    map[string]interface{}{"from": "int", "to": "int", "idFormat": "string"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	poolID := created.Pool.ID
+
+	// claims and frees run in the transaction of the mutation, capacity of the pool is updated within it
+	ctx, tx = mutationContext(t, s)
+	claimed, err := mutation.ClaimResource(ctx, poolID, nil, map[string]interface{}{"desiredValue": 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	props, err := pools2.PropertiesToMap(s.client.Resource.GetX(s.ctx, claimed.ID).QueryProperties().WithType().AllX(s.ctx))
+	assert.Nil(t, err)
+	assert.Equal(t, "id-5", props["text"])
+	pool := s.client.ResourcePool.GetX(s.ctx, poolID)
+	assert.Equal(t, "9", *pool.FreeCapacity)
+	assert.Equal(t, "1", *pool.UtilizedCapacity)
+
+	ctx, tx = mutationContext(t, s)
+	if _, err := mutation.FreeResource(ctx, map[string]interface{}{"counter": 5, "text": "id-5"}, poolID); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	pool = s.client.ResourcePool.GetX(s.ctx, poolID)
+	assert.Equal(t, "10", *pool.FreeCapacity)
+	assert.Equal(t, "0", *pool.UtilizedCapacity)
+	assert.Equal(t, 0, s.client.Resource.Query().CountX(s.ctx))
+}
+
+func TestUniqueIdPoolPreviewClaim(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+	uniqueIdType := s.client.ResourceType.Query().Where(resourcetype.Name("unique_id")).OnlyX(s.ctx)
+	uniqueIdStrategy := s.client.AllocationStrategy.Query().Where(allocationstrategy.Name("unique_id")).OnlyX(s.ctx)
+	res := resolver.New(resolver.Config{})
+
+	ctx, tx := mutationContext(t, s)
+	created, err := res.Mutation().CreateAllocatingPool(ctx, &model.CreateAllocatingPoolInput{
+		AllocationStrategyID: uniqueIdStrategy.ID,
+		PoolName:             "ids",
+		ResourceTypeID:       uniqueIdType.ID,
+		PoolProperties:       map[string]interface{}{"from": 1, "to": 10, "idFormat": "id-{counter}"},
+		PoolPropertyTypes:    map[string]interface{}{"from": "int", "to": "int", "idFormat": "string"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	poolID := created.Pool.ID
+	userInput := map[string]interface{}{"desiredValue": 3}
+
+	// queries run without a transaction, the preview opens its own
+	preview, err := res.Query().PreviewClaim(ent.NewContext(s.ctx, s.client), poolID, userInput, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "id-3", preview.Resources[0]["text"])
+	assert.Equal(t, 0, s.client.Resource.Query().CountX(s.ctx))
+
+	// within a mutation the preview is rolled back without affecting the rest of the transaction,
+	// the previewed value remains free to be claimed
+	ctx, tx = mutationContext(t, s)
+	if _, err := res.Query().PreviewClaim(ctx, poolID, userInput, nil); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := res.Mutation().ClaimResource(ctx, poolID, nil, userInput, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	props, err := pools2.PropertiesToMap(s.client.Resource.GetX(s.ctx, claimed.ID).QueryProperties().WithType().AllX(s.ctx))
+	assert.Nil(t, err)
+	assert.Equal(t, "id-3", props["text"])
+	assert.Equal(t, 1, s.client.Resource.Query().CountX(s.ctx))
+}
@@ -0,0 +1,350 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/pooltemplate"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	log "github.com/net-auto/resourceManager/logging"
+	p "github.com/net-auto/resourceManager/pools"
+	"github.com/pkg/errors"
+)
+
+var templatePlaceholder = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// createPoolTemplate stores a template together with its child templates
+func createPoolTemplate(ctx context.Context, client *ent.Client, input model.CreatePoolTemplateInput,
+	parent *ent.PoolTemplate) (*ent.PoolTemplate, error) {
+	if err := validatePoolTemplateInput(input, parent != nil); err != nil {
+		return nil, err
+	}
+
+	create := client.PoolTemplate.Create().
+		SetName(input.Name).
+		SetNillableDescription(input.Description).
+		SetPoolType(pooltemplate.PoolType(input.PoolType)).
+		SetPoolName(input.PoolName).
+		SetDealocationSafetyPeriod(input.PoolDealocationSafetyPeriod).
+		SetResourceTypeID(input.ResourceTypeID).
+		SetNillableAllocationStrategyID(input.AllocationStrategyID).
+		SetPoolValues(input.PoolValues).
+		SetPoolPropertyTypes(input.PoolPropertyTypes).
+		SetPoolProperties(input.PoolProperties).
+		SetTags(input.Tags).
+		SetParentClaim(input.ParentClaim)
+	if parent != nil {
+		create.SetParent(parent)
+	}
+	template, err := create.Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to create pool template \"%s\"", input.Name)
+		return nil, errors.Wrapf(err, "Unable to create pool template \"%s\"", input.Name)
+	}
+
+	for _, child := range input.Children {
+		if _, err := createPoolTemplate(ctx, client, *child, template); err != nil {
+			return nil, err
+		}
+	}
+	return template, nil
+}
+
+func validatePoolTemplateInput(input model.CreatePoolTemplateInput, nested bool) error {
+	switch input.PoolType {
+	case resourcePool.PoolTypeSingleton:
+		if len(input.PoolValues) != 1 {
+			return errors.Errorf("Unable to create pool template \"%s\", singleton pool needs exactly one value", input.Name)
+		}
+	case resourcePool.PoolTypeSet:
+		if len(input.PoolValues) == 0 {
+			return errors.Errorf("Unable to create pool template \"%s\", set pool needs values", input.Name)
+		}
+	case resourcePool.PoolTypeAllocating:
+		if input.AllocationStrategyID == nil {
+			return errors.Errorf("Unable to create pool template \"%s\", allocating pool needs an allocation strategy", input.Name)
+		}
+		if !nested && input.PoolPropertyTypes == nil {
+			return errors.Errorf("Unable to create pool template \"%s\", root allocating pool needs pool property types", input.Name)
+		}
+	}
+	if !nested && input.ParentClaim != nil {
+		return errors.Errorf("Unable to create pool template \"%s\", only nested templates claim a parent resource", input.Name)
+	}
+	return nil
+}
+
+// deletePoolTemplate deletes a template together with its child templates, children first
+func deletePoolTemplate(ctx context.Context, client *ent.Client, template *ent.PoolTemplate) error {
+	children, err := template.QueryChildren().All(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to load child templates of template \"%s\"", template.Name)
+	}
+	for _, child := range children {
+		if err := deletePoolTemplate(ctx, client, child); err != nil {
+			return err
+		}
+	}
+	if err := client.PoolTemplate.DeleteOne(template).Exec(ctx); err != nil {
+		log.Error(ctx, err, "Unable to delete pool template ID %d", template.ID)
+		return errors.Wrapf(err, "Unable to delete pool template \"%s\"", template.Name)
+	}
+	return nil
+}
+
+// instantiatePoolTemplate creates a pool from the template followed by pools of its child templates.
+// Pools of child templates are nested in resources claimed from the pool of their parent template.
+func (r *mutationResolver) instantiatePoolTemplate(ctx context.Context, template *ent.PoolTemplate,
+	variables map[string]interface{}, parentPool p.Pool) ([]*ent.ResourcePool, error) {
+	client := r.ClientFrom(ctx)
+
+	poolName, err := expandTemplateString(template.PoolName, variables)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to instantiate template \"%s\", invalid pool name", template.Name)
+	}
+	expanded, err := expandTemplateValue(template.Tags, variables)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to instantiate template \"%s\", invalid tags", template.Name)
+	}
+	tags := template.Tags
+	if expanded != nil {
+		tags = expanded.([]string)
+	}
+
+	resourceTypeID, err := template.QueryResourceType().OnlyID(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to instantiate template \"%s\", unable to load resource type", template.Name)
+	}
+
+	createPool := func() (*ent.ResourcePool, error) {
+		return r.createPoolFromTemplate(ctx, template, variables, poolName, resourceTypeID, tags, parentPool != nil)
+	}
+
+	var pool *ent.ResourcePool
+	if parentPool == nil {
+		pool, err = createPool()
+	} else {
+		var parentResource *ent.Resource
+		parentResource, err = r.claimTemplateParentResource(template, variables, parentPool, poolName)
+		if err != nil {
+			return nil, err
+		}
+		pool, err = createNestedPool(ctx, parentResource.ID, client, createPool)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to instantiate template \"%s\"", template.Name)
+	}
+	created := []*ent.ResourcePool{pool}
+
+	children, err := template.QueryChildren().Order(ent.Asc(pooltemplate.FieldID)).All(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to load child templates of template \"%s\"", template.Name)
+	}
+	if len(children) == 0 {
+		return created, nil
+	}
+	createdPool, err := p.ExistingPoolFromId(ctx, client, pool.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		nested, err := r.instantiatePoolTemplate(ctx, child, variables, createdPool)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, nested...)
+	}
+	return created, nil
+}
+
+func (r *mutationResolver) createPoolFromTemplate(ctx context.Context, template *ent.PoolTemplate,
+	variables map[string]interface{}, poolName string, resourceTypeID int, tags []string, nested bool) (*ent.ResourcePool, error) {
+	expanded, err := expandTemplateValue(template.PoolValues, variables)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid pool values")
+	}
+	var poolValues []map[string]interface{}
+	if expanded != nil {
+		poolValues = expanded.([]map[string]interface{})
+	}
+
+	switch template.PoolType {
+	case pooltemplate.PoolTypeSet:
+		payload, err := r.CreateSetPool(ctx, model.CreateSetPoolInput{
+			ResourceTypeID:              resourceTypeID,
+			PoolName:                    poolName,
+			Description:                 template.Description,
+			PoolDealocationSafetyPeriod: template.DealocationSafetyPeriod,
+			PoolValues:                  poolValues,
+			Tags:                        tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return payload.Pool, nil
+	case pooltemplate.PoolTypeSingleton:
+		payload, err := r.CreateSingletonPool(ctx, &model.CreateSingletonPoolInput{
+			ResourceTypeID: resourceTypeID,
+			PoolName:       poolName,
+			Description:    template.Description,
+			PoolValues:     poolValues,
+			Tags:           tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return payload.Pool, nil
+	default:
+		strategyID, err := template.QueryAllocationStrategy().OnlyID(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to load allocation strategy")
+		}
+		input := &model.CreateAllocatingPoolInput{
+			ResourceTypeID:              resourceTypeID,
+			PoolName:                    poolName,
+			Description:                 template.Description,
+			AllocationStrategyID:        strategyID,
+			PoolDealocationSafetyPeriod: template.DealocationSafetyPeriod,
+			Tags:                        tags,
+		}
+		// nested pools take their pool properties from the parent resource
+		if !nested {
+			input.PoolPropertyTypes = template.PoolPropertyTypes
+			if input.PoolProperties, err = templatePoolProperties(template.PoolProperties, variables); err != nil {
+				return nil, errors.Wrapf(err, "Invalid pool properties")
+			}
+		}
+		payload, err := r.CreateAllocatingPool(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return payload.Pool, nil
+	}
+}
+
+func (r *mutationResolver) claimTemplateParentResource(template *ent.PoolTemplate, variables map[string]interface{},
+	parentPool p.Pool, poolName string) (*ent.Resource, error) {
+	expanded, err := expandTemplateValue(template.ParentClaim, variables)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to instantiate template \"%s\", invalid parent claim", template.Name)
+	}
+	userInput := map[string]interface{}{}
+	if expanded != nil {
+		userInput = expanded.(map[string]interface{})
+	}
+
+	description := fmt.Sprintf("Parent resource of pool %s", poolName)
+	res, err := ClaimResource(parentPool, userInput, &description, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to instantiate template \"%s\", unable to claim parent resource", template.Name)
+	}
+	if err := p.UpdatePoolCapacity(parentPool); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// templatePoolProperties applies variables named after pool properties on top of the template defaults
+func templatePoolProperties(defaults map[string]interface{}, variables map[string]interface{}) (map[string]interface{}, error) {
+	expanded, err := expandTemplateValue(defaults, variables)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]interface{}{}
+	if expanded != nil {
+		properties = expanded.(map[string]interface{})
+	}
+	for name := range properties {
+		if value, ok := variables[name]; ok {
+			properties[name] = value
+		}
+	}
+	return properties, nil
+}
+
+// expandTemplateValue replaces ${variable} placeholders in all strings of a template value.
+// A string consisting of a single placeholder is replaced by the variable itself, keeping its type.
+func expandTemplateValue(value interface{}, variables map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if match := templatePlaceholder.FindStringSubmatch(v); match != nil && match[0] == v {
+			variable, ok := variables[match[1]]
+			if !ok {
+				return nil, errors.Errorf("Missing template variable \"%s\"", match[1])
+			}
+			return variable, nil
+		}
+		return expandTemplateString(v, variables)
+	case []string:
+		if v == nil {
+			return nil, nil
+		}
+		expanded := make([]string, len(v))
+		for i, item := range v {
+			s, err := expandTemplateString(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = s
+		}
+		return expanded, nil
+	case []map[string]interface{}:
+		if v == nil {
+			return nil, nil
+		}
+		expanded := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			m, err := expandTemplateValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = m.(map[string]interface{})
+		}
+		return expanded, nil
+	case map[string]interface{}:
+		if v == nil {
+			return nil, nil
+		}
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			e, err := expandTemplateValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = e
+		}
+		return expanded, nil
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			e, err := expandTemplateValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = e
+		}
+		return expanded, nil
+	default:
+		return value, nil
+	}
+}
+
+func expandTemplateString(value string, variables map[string]interface{}) (string, error) {
+	var missing []string
+	expanded := templatePlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		variable, ok := variables[name]
+		if !ok {
+			missing = append(missing, name)
+			return placeholder
+		}
+		return fmt.Sprintf("%v", variable)
+	})
+	if len(missing) > 0 {
+		return "", errors.Errorf("Missing template variables %v", missing)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,85 @@
+package resolver_test
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/resourcetype"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	"github.com/net-auto/resourceManager/graph/graphql/resolver"
+	pools2 "github.com/net-auto/resourceManager/pools"
+	pools "github.com/net-auto/resourceManager/pools/allocating_strategies"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstantiatePoolTemplate(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+	ctx := ent.NewContext(s.ctx, s.client)
+	mutation := resolver.New(resolver.Config{}).Mutation()
+
+	ipv4PrefixType := s.client.ResourceType.Query().Where(resourcetype.Name("ipv4_prefix")).OnlyX(ctx)
+	ipv4PrefixStrategy := s.client.AllocationStrategy.Query().Where(allocationstrategy.Name("ipv4_prefix")).OnlyX(ctx)
+	vlanType := s.client.ResourceType.Query().Where(resourcetype.Name("vlan")).OnlyX(ctx)
+
+	template, err := mutation.CreatePoolTemplate(ctx, model.CreatePoolTemplateInput{
+		Name:                        "region",
+		PoolType:                    resourcePool.PoolTypeAllocating,
+		PoolName:                    "${region}-prefixes",
+		ResourceTypeID:              ipv4PrefixType.ID,
+		AllocationStrategyID:        &ipv4PrefixStrategy.ID,
+		PoolDealocationSafetyPeriod: 0,
+		PoolPropertyTypes:           map[string]interface{}{"address": "string", "prefix": "int", "subnet": "bool"},
+		PoolProperties:              map[string]interface{}{"address": "${network}", "prefix": 16, "subnet": false},
+		Tags:                        []string{"region-${region}"},
+		Children: []*model.CreatePoolTemplateInput{{
+			Name:                        "region-vlans",
+			PoolType:                    resourcePool.PoolTypeSet,
+			PoolName:                    "${region}-vlans",
+			ResourceTypeID:              vlanType.ID,
+			PoolDealocationSafetyPeriod: 0,
+			PoolValues:                  []map[string]interface{}{{"vlan": "${vlan}"}, {"vlan": 200}},
+			ParentClaim:                 map[string]interface{}{"desiredSize": "${siteSize}"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mutation.InstantiatePoolTemplate(ctx, template.ID, map[string]interface{}{"region": "eu"}); err == nil {
+		t.Fatalf("Instantiating template with missing variables should fail")
+	}
+
+	payload, err := mutation.InstantiatePoolTemplate(ctx, template.ID, map[string]interface{}{
+		"region": "eu", "network": "10.0.0.0", "vlan": 100, "siteSize": 256, "prefix": 8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(payload.Pools))
+	assert.Equal(t, "eu-prefixes", payload.Pool.Name)
+	assert.Equal(t, []string{"region-eu"}, s.client.ResourcePool.GetX(ctx, payload.Pool.ID).QueryTags().Select("tag").StringsX(ctx))
+
+	// variables named after pool properties override template defaults
+	properties, err := pools2.PropertiesToMap(payload.Pool.QueryPoolProperties().QueryProperties().WithType().AllX(ctx))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 8, properties["prefix"])
+	assert.Equal(t, "10.0.0.0", properties["address"])
+
+	vlans := payload.Pools[1]
+	assert.Equal(t, "eu-vlans", vlans.Name)
+	parent := vlans.QueryParentResource().QueryPool().OnlyX(ctx)
+	assert.Equal(t, payload.Pool.ID, parent.ID)
+	values := vlans.QueryClaims().QueryProperties().Select("int_val").IntsX(ctx)
+	assert.ElementsMatch(t, []int{100, 200}, values)
+
+	if _, err := mutation.DeletePoolTemplate(ctx, template.ID); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, s.client.PoolTemplate.Query().CountX(ctx))
+}
@@ -2,6 +2,7 @@ package resolver
 
 // This file will be automatically regenerated based on the schema, any resolver implementations
 // will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.44
 
 import (
 	"context"
@@ -13,12 +14,17 @@ import (
 	"entgo.io/ent/dialect/sql"
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/allocationstrategyrevision"
+	"github.com/net-auto/resourceManager/ent/auditlog"
+	"github.com/net-auto/resourceManager/ent/pooltemplate"
 	"github.com/net-auto/resourceManager/ent/predicate"
 	"github.com/net-auto/resourceManager/ent/property"
 	"github.com/net-auto/resourceManager/ent/propertytype"
 	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
 	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
 	"github.com/net-auto/resourceManager/ent/resourcetype"
+	"github.com/net-auto/resourceManager/ent/strategytestcase"
 	"github.com/net-auto/resourceManager/graph/graphql/generated"
 	"github.com/net-auto/resourceManager/graph/graphql/model"
 	log "github.com/net-auto/resourceManager/logging"
@@ -28,6 +34,21 @@ import (
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
+// Lang is the resolver for the Lang field.
+func (r *allocationStrategyRevisionResolver) Lang(ctx context.Context, obj *ent.AllocationStrategyRevision) (allocationstrategy.Lang, error) {
+	return allocationstrategy.Lang(obj.Lang), nil
+}
+
+// CreatedAt is the resolver for the CreatedAt field.
+func (r *allocationStrategyRevisionResolver) CreatedAt(ctx context.Context, obj *ent.AllocationStrategyRevision) (string, error) {
+	return obj.CreatedAt.Format(time.RFC3339), nil
+}
+
+// CreatedAt is the resolver for the CreatedAt field.
+func (r *auditLogResolver) CreatedAt(ctx context.Context, obj *ent.AuditLog) (string, error) {
+	return obj.CreatedAt.Format(time.RFC3339), nil
+}
+
 // CreateTag is the resolver for the CreateTag field.
 func (r *mutationResolver) CreateTag(ctx context.Context, input model.CreateTagInput) (*model.CreateTagPayload, error) {
 	var client = r.ClientFrom(ctx)
@@ -93,6 +114,28 @@ func (r *mutationResolver) CreateAllocationStrategy(ctx context.Context, input *
 	var propertyTypes []*ent.PropertyType
 	var strat *ent.AllocationStrategy
 	var err error
+
+	if input.Lang == allocationstrategy.LangGo {
+		return &model.CreateAllocationStrategyPayload{Strategy: nil}, gqlerror.Errorf(
+			"Unable to create strategy: go strategies are compiled in and loaded with built-in resource types")
+	}
+
+	var testCases []*ent.StrategyTestCase
+	for _, testCase := range input.TestCases {
+		testCases = append(testCases, p.StrategyTestCaseFromInput(*testCase))
+	}
+	if input.RequirePassingTests != nil && *input.RequirePassingTests {
+		wasmer, err := p.NewWasmerUsingEnvVars()
+		if err != nil {
+			log.Error(ctx, err, "Unable to create a scripting engine (wasmer)")
+			return &model.CreateAllocationStrategyPayload{Strategy: nil}, gqlerror.Errorf("Unable to create scripting engine: %v", err)
+		}
+		candidate := &ent.AllocationStrategy{Name: input.Name, Lang: input.Lang, Script: input.Script}
+		if err := p.RequirePassingStrategyTests(ctx, wasmer, candidate, testCases); err != nil {
+			return &model.CreateAllocationStrategyPayload{Strategy: nil}, gqlerror.Errorf("Unable to create strategy: %v", err)
+		}
+	}
+
 	if input.ExpectedPoolPropertyTypes != nil {
 		for propName, rawPropType := range input.ExpectedPoolPropertyTypes {
 			var propertyType, err = p.CreatePropertyType(ctx, client, propName, rawPropType)
@@ -126,6 +169,10 @@ func (r *mutationResolver) CreateAllocationStrategy(ctx context.Context, input *
 		}
 	}
 
+	if _, err := p.CreateStrategyTestCases(ctx, client, strat, testCases); err != nil {
+		return &model.CreateAllocationStrategyPayload{Strategy: nil}, gqlerror.Errorf("Unable to create strategy: %v", err)
+	}
+
 	return &model.CreateAllocationStrategyPayload{Strategy: strat}, nil
 }
 
@@ -146,6 +193,20 @@ func (r *mutationResolver) DeleteAllocationStrategy(ctx context.Context, input *
 			return &emptyRetVal, gqlerror.Errorf("Unable to delete, Allocation strategy is still in use")
 		}
 
+		if _, err := client.StrategyTestCase.Delete().
+			Where(strategytestcase.HasAllocationStrategyWith(allocationstrategy.ID(strat.ID))).
+			Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to delete test cases of allocation strategy ID %d", input.AllocationStrategyID)
+			return &emptyRetVal, err
+		}
+
+		if _, err := client.AllocationStrategyRevision.Delete().
+			Where(allocationstrategyrevision.HasAllocationStrategyWith(allocationstrategy.ID(strat.ID))).
+			Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to delete revisions of allocation strategy ID %d", input.AllocationStrategyID)
+			return &emptyRetVal, err
+		}
+
 		if err := client.AllocationStrategy.DeleteOneID(input.AllocationStrategyID).Exec(ctx); err != nil {
 			log.Error(ctx, err, "Unable to delete allocation strategy ID %d", input.AllocationStrategyID)
 			return &emptyRetVal, err
@@ -155,6 +216,43 @@ func (r *mutationResolver) DeleteAllocationStrategy(ctx context.Context, input *
 	}
 }
 
+// UpdateAllocationStrategy is the resolver for the UpdateAllocationStrategy field.
+func (r *mutationResolver) UpdateAllocationStrategy(ctx context.Context, input model.UpdateAllocationStrategyInput) (*model.UpdateAllocationStrategyPayload, error) {
+	var client = r.ClientFrom(ctx)
+	var testInvoker p.ScriptInvoker
+	if input.RequirePassingTests != nil && *input.RequirePassingTests {
+		wasmer, err := p.NewWasmerUsingEnvVars()
+		if err != nil {
+			log.Error(ctx, err, "Unable to create a scripting engine (wasmer)")
+			return nil, gqlerror.Errorf("Unable to create scripting engine: %v", err)
+		}
+		testInvoker = wasmer
+	}
+	strat, err := p.UpdateAllocationStrategy(ctx, client, input.AllocationStrategyID, input.Lang, input.Script, input.Description, testInvoker)
+	if err != nil {
+		log.Error(ctx, err, "Unable to update allocation strategy ID %d", input.AllocationStrategyID)
+		return nil, gqlerror.Errorf("Unable to update strategy: %v", err)
+	}
+	revision, err := p.LatestStrategyRevision(ctx, client, strat)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to update strategy: %v", err)
+	}
+	return &model.UpdateAllocationStrategyPayload{Strategy: strat, Revision: revision}, nil
+}
+
+// UpgradePoolStrategy is the resolver for the UpgradePoolStrategy field.
+func (r *mutationResolver) UpgradePoolStrategy(ctx context.Context, poolID int, revision *int, userInput map[string]interface{}, dryRun *bool) (*model.UpgradePoolStrategyPayload, error) {
+	if userInput == nil {
+		userInput = map[string]interface{}{}
+	}
+	upgrade, err := p.UpgradePoolStrategy(ctx, r.ClientFrom(ctx), poolID, revision, userInput, dryRun != nil && *dryRun)
+	if err != nil {
+		log.Error(ctx, err, "Unable to upgrade strategy of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to upgrade strategy of pool: %v", err)
+	}
+	return upgradePoolStrategyPayload(upgrade), nil
+}
+
 // TestAllocationStrategy is the resolver for the TestAllocationStrategy field.
 func (r *mutationResolver) TestAllocationStrategy(ctx context.Context, allocationStrategyID int, resourcePool model.ResourcePoolInput, currentResources []*model.ResourceInput, userInput map[string]interface{}) (map[string]interface{}, error) {
 	var client = r.ClientFrom(ctx)
@@ -196,24 +294,175 @@ func (r *mutationResolver) TestAllocationStrategy(ctx context.Context, allocatio
 	return result, nil
 }
 
+// CreateStrategyTestCase is the resolver for the CreateStrategyTestCase field.
+func (r *mutationResolver) CreateStrategyTestCase(ctx context.Context, allocationStrategyID int, input model.StrategyTestCaseInput) (*ent.StrategyTestCase, error) {
+	var client = r.ClientFrom(ctx)
+	strat, err := client.AllocationStrategy.Get(ctx, allocationStrategyID)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find allocation strategy %d", allocationStrategyID)
+		return nil, gqlerror.Errorf("Unable to get strategy: %v", err)
+	}
+	created, err := p.CreateStrategyTestCases(ctx, client, strat, []*ent.StrategyTestCase{p.StrategyTestCaseFromInput(input)})
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to create test case: %v", err)
+	}
+	return created[0], nil
+}
+
+// DeleteStrategyTestCase is the resolver for the DeleteStrategyTestCase field.
+func (r *mutationResolver) DeleteStrategyTestCase(ctx context.Context, testCaseID int) (string, error) {
+	if err := r.ClientFrom(ctx).StrategyTestCase.DeleteOneID(testCaseID).Exec(ctx); err != nil {
+		log.Error(ctx, err, "Unable to delete strategy test case %d", testCaseID)
+		return "", gqlerror.Errorf("Unable to delete test case: %v", err)
+	}
+	return "Test case deleted successfully", nil
+}
+
+// RunStrategyTests is the resolver for the RunStrategyTests field.
+func (r *mutationResolver) RunStrategyTests(ctx context.Context, allocationStrategyID int) (*model.RunStrategyTestsPayload, error) {
+	strat, err := r.ClientFrom(ctx).AllocationStrategy.Get(ctx, allocationStrategyID)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find allocation strategy %d", allocationStrategyID)
+		return nil, gqlerror.Errorf("Unable to get strategy: %v", err)
+	}
+	wasmer, err := p.NewWasmerUsingEnvVars()
+	if err != nil {
+		log.Error(ctx, err, "Unable to create a scripting engine (wasmer)")
+		return nil, gqlerror.Errorf("Unable to create scripting engine: %v", err)
+	}
+	results, err := p.RunStrategyTests(ctx, wasmer, strat)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to run tests of strategy: %v", err)
+	}
+	return runStrategyTestsPayload(results), nil
+}
+
 // ClaimResource is the resolver for the ClaimResource field.
-func (r *mutationResolver) ClaimResource(ctx context.Context, poolID int, description *string, userInput map[string]interface{}) (*ent.Resource, error) {
+func (r *mutationResolver) ClaimResource(ctx context.Context, poolID int, description *string, userInput map[string]interface{}, leaseSeconds *int) (*ent.Resource, error) {
 	pool, err := p.ExistingPoolFromId(ctx, r.ClientFrom(ctx), poolID)
 	if err != nil {
 		return nil, gqlerror.Errorf("Resource pool is not existing, for you to be able to claim resource: %v", err)
 	}
 
-	return ClaimResource(pool, userInput, description, nil)
+	res, err := ClaimResource(pool, userInput, description, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resource: %v", err)
+	}
+
+	return leaseResource(ctx, r.ClientFrom(ctx), res, leaseSeconds)
 }
 
 // ClaimResourceWithAltID is the resolver for the ClaimResourceWithAltId field.
-func (r *mutationResolver) ClaimResourceWithAltID(ctx context.Context, poolID int, description *string, userInput map[string]interface{}, alternativeID map[string]interface{}) (*ent.Resource, error) {
+func (r *mutationResolver) ClaimResourceWithAltID(ctx context.Context, poolID int, description *string, userInput map[string]interface{}, alternativeID map[string]interface{}, leaseSeconds *int, idempotent *bool) (*ent.Resource, error) {
 	pool, err := p.ExistingPoolFromId(ctx, r.ClientFrom(ctx), poolID)
 	if err != nil {
 		return nil, gqlerror.Errorf("Resource pool is not existing, for you to be able to claim resource: %v", err)
 	}
 
-	return ClaimResource(pool, userInput, description, alternativeID)
+	var res *ent.Resource
+	if idempotent != nil && *idempotent {
+		res, err = ClaimResourceIdempotent(pool, userInput, description, alternativeID)
+	} else {
+		res, err = ClaimResource(pool, userInput, description, alternativeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resource: %v", err)
+	}
+
+	return leaseResource(ctx, r.ClientFrom(ctx), res, leaseSeconds)
+}
+
+// ClaimResources is the resolver for the ClaimResources field.
+func (r *mutationResolver) ClaimResources(ctx context.Context, poolID int, count int, description *string, userInput map[string]interface{}, alternativeID map[string]interface{}, leaseSeconds *int) ([]*ent.Resource, error) {
+	pool, err := p.ExistingPoolFromId(ctx, r.ClientFrom(ctx), poolID)
+	if err != nil {
+		return nil, gqlerror.Errorf("Resource pool is not existing, for you to be able to claim resources: %v", err)
+	}
+
+	resources, err := ClaimResources(pool, count, userInput, description, alternativeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resources: %v", err)
+	}
+
+	for i, res := range resources {
+		if resources[i], err = leaseResource(ctx, r.ClientFrom(ctx), res, leaseSeconds); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// RenewResourceLease is the resolver for the RenewResourceLease field.
+func (r *mutationResolver) RenewResourceLease(ctx context.Context, resourceID int, leaseSeconds int) (*ent.Resource, error) {
+	res, err := p.RenewResourceLease(ctx, r.ClientFrom(ctx), resourceID, leaseSeconds)
+	if err != nil {
+		log.Error(ctx, err, "Unable to renew lease of resource ID %d", resourceID)
+		return nil, gqlerror.Errorf("Unable to renew resource lease: %v", err)
+	}
+	return res, nil
+}
+
+// ReserveResource is the resolver for the ReserveResource field.
+func (r *mutationResolver) ReserveResource(ctx context.Context, poolID int, description *string, userInput map[string]interface{}, alternativeID map[string]interface{}, ttlSeconds int) (*ent.Resource, error) {
+	pool, err := p.ExistingPoolFromId(ctx, r.ClientFrom(ctx), poolID)
+	if err != nil {
+		return nil, gqlerror.Errorf("Resource pool is not existing, for you to be able to reserve resource: %v", err)
+	}
+
+	input, err := normalizeUserInput(userInput)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := pool.ReserveResource(input, description, alternativeID, ttlSeconds)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to reserve resource: %v", err)
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return nil, gqlerror.Errorf("Unable to reserve resource: %v", err)
+	}
+	return res, nil
+}
+
+// CommitReservation is the resolver for the CommitReservation field.
+func (r *mutationResolver) CommitReservation(ctx context.Context, resourceID int, leaseSeconds *int) (*ent.Resource, error) {
+	pool, err := poolOfResource(ctx, r.ClientFrom(ctx), resourceID)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to commit reservation: %v", err)
+	}
+
+	res, err := pool.CommitReservation(resourceID)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to commit reservation: %v", err)
+	}
+
+	return leaseResource(ctx, r.ClientFrom(ctx), res, leaseSeconds)
+}
+
+// CancelReservation is the resolver for the CancelReservation field.
+func (r *mutationResolver) CancelReservation(ctx context.Context, resourceID int) (string, error) {
+	pool, err := poolOfResource(ctx, r.ClientFrom(ctx), resourceID)
+	if err != nil {
+		return "", gqlerror.Errorf("Unable to cancel reservation: %v", err)
+	}
+
+	if err = pool.CancelReservation(resourceID); err != nil {
+		log.Error(ctx, err, "Unable to cancel reservation of resource ID %d", resourceID)
+		return "", gqlerror.Errorf("Unable to cancel reservation: %v", err)
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return "", gqlerror.Errorf("Unable to cancel reservation: %v", err)
+	}
+	return "Reservation cancelled successfully", nil
 }
 
 // FreeResource is the resolver for the FreeResource field.
@@ -224,6 +473,9 @@ func (r *mutationResolver) FreeResource(ctx context.Context, input map[string]in
 	}
 	err = pool.FreeResource(input)
 	if err == nil {
+		if err := p.UpdatePoolCapacity(pool); err != nil {
+			return "", gqlerror.Errorf("Unable to free resource: %v", err)
+		}
 		return "Resource freed successfully", nil
 	}
 
@@ -231,6 +483,66 @@ func (r *mutationResolver) FreeResource(ctx context.Context, input map[string]in
 	return "", gqlerror.Errorf("Unable to free resource: %v", err)
 }
 
+// FreeResources is the resolver for the FreeResources field.
+func (r *mutationResolver) FreeResources(ctx context.Context, poolID int, input []map[string]interface{}, resourceIds []int) (string, error) {
+	pool, err := p.ExistingPoolFromId(ctx, r.ClientFrom(ctx), poolID)
+	if err != nil {
+		return "", gqlerror.Errorf("Unable to free resources: %v", err)
+	}
+
+	raws := make([]p.RawResourceProps, 0, len(input))
+	for _, props := range input {
+		raws = append(raws, props)
+	}
+
+	if err = pool.FreeResources(raws, resourceIds); err != nil {
+		log.Error(ctx, err, "Unable to free resources on pool ID %d", poolID)
+		return "", freeResourcesError(err)
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return "", gqlerror.Errorf("Unable to free resources: %v", err)
+	}
+
+	return "Resources freed successfully", nil
+}
+
+// MoveResource is the resolver for the MoveResource field.
+func (r *mutationResolver) MoveResource(ctx context.Context, resourceID int, targetPoolID int) (*ent.Resource, error) {
+	res, err := p.MoveResource(ctx, r.ClientFrom(ctx), resourceID, targetPoolID)
+	if err != nil {
+		log.Error(ctx, err, "Unable to move resource ID %d into pool ID %d", resourceID, targetPoolID)
+		return nil, gqlerror.Errorf("Unable to move resource: %v", err)
+	}
+	return res, nil
+}
+
+// UnbenchResources is the resolver for the UnbenchResources field.
+func (r *mutationResolver) UnbenchResources(ctx context.Context, resourceIds []int) (string, error) {
+	if err := p.UnbenchResources(ctx, r.ClientFrom(ctx), resourceIds); err != nil {
+		log.Error(ctx, err, "Unable to unbench resources %v", resourceIds)
+		return "", gqlerror.Errorf("Unable to unbench resources: %v", err)
+	}
+	return "Resources unbenched successfully", nil
+}
+
+// UnretireResources is the resolver for the UnretireResources field.
+func (r *mutationResolver) UnretireResources(ctx context.Context, resourceIds []int) (string, error) {
+	if err := p.UnretireResources(ctx, r.ClientFrom(ctx), resourceIds); err != nil {
+		log.Error(ctx, err, "Unable to unretire resources %v", resourceIds)
+		return "", gqlerror.Errorf("Unable to unretire resources: %v", err)
+	}
+	return "Resources unretired successfully", nil
+}
+
+// ForceFreeResources is the resolver for the ForceFreeResources field.
+func (r *mutationResolver) ForceFreeResources(ctx context.Context, resourceIds []int) (string, error) {
+	if err := p.ForceFreeResources(ctx, r.ClientFrom(ctx), resourceIds); err != nil {
+		log.Error(ctx, err, "Unable to force free resources %v", resourceIds)
+		return "", gqlerror.Errorf("Unable to force free resources: %v", err)
+	}
+	return "Resources freed successfully", nil
+}
+
 // CreateSetPool is the resolver for the CreateSetPool field.
 func (r *mutationResolver) CreateSetPool(ctx context.Context, input model.CreateSetPoolInput) (*model.CreateSetPoolPayload, error) {
 	var client = r.ClientFrom(ctx)
@@ -531,6 +843,174 @@ func (r *mutationResolver) DeleteResourcePool(ctx context.Context, input model.D
 	return &retVal, nil
 }
 
+// DeleteResourcePoolTree is the resolver for the DeleteResourcePoolTree field.
+func (r *mutationResolver) DeleteResourcePoolTree(ctx context.Context, poolID int, dryRun bool) (*model.DeleteResourcePoolTreePayload, error) {
+	client := r.ClientFrom(ctx)
+	deletion, err := p.DeleteResourcePoolTree(ctx, client, poolID, dryRun)
+	if err != nil {
+		log.Error(ctx, err, "Unable to delete pool tree of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to delete pool tree: %v", err)
+	}
+
+	if !dryRun {
+		for _, pool := range deletion.Pools {
+			resourceTypes, err := client.ResourceType.Query().Where(resourcetype.Name(pool.Name + "-ResourceType")).All(ctx)
+			if err != nil {
+				log.Error(ctx, err, "Unable to retrieve resource type of pool ID %d", pool.ID)
+				return nil, gqlerror.Errorf("Unable to delete resource type of pool ID %d: %v", pool.ID, err)
+			}
+			for _, resourceType := range resourceTypes {
+				if _, err := r.DeleteResourceType(ctx, model.DeleteResourceTypeInput{ResourceTypeID: resourceType.ID}); err != nil {
+					log.Error(ctx, err, "Unable to delete resource type ID %d", resourceType.ID)
+					return nil, gqlerror.Errorf("Unable to delete resource type ID %d: %v", resourceType.ID, err)
+				}
+			}
+		}
+	}
+
+	return poolTreeDeletionPayload(deletion, dryRun)
+}
+
+// UpdateResourcePool is the resolver for the UpdateResourcePool field.
+func (r *mutationResolver) UpdateResourcePool(ctx context.Context, input model.UpdateResourcePoolInput) (*model.UpdateResourcePoolPayload, error) {
+	emptyRetVal := model.UpdateResourcePoolPayload{Pool: nil}
+
+	pool, err := p.UpdateResourcePool(ctx, r.ClientFrom(ctx), input.PoolID,
+		input.Description, input.PoolDealocationSafetyPeriod, input.PoolProperties)
+	if err != nil {
+		return &emptyRetVal, updateResourcePoolError(err)
+	}
+
+	return &model.UpdateResourcePoolPayload{Pool: pool}, nil
+}
+
+// SetResourcePoolLifecycleState is the resolver for the SetResourcePoolLifecycleState field.
+func (r *mutationResolver) SetResourcePoolLifecycleState(ctx context.Context, poolID int, lifecycleState resourcePool.LifecycleState) (*ent.ResourcePool, error) {
+	pool, err := p.SetPoolLifecycleState(ctx, r.ClientFrom(ctx), poolID, lifecycleState)
+	if err != nil {
+		log.Error(ctx, err, "Unable to change lifecycle state of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to change lifecycle state of pool: %v", err)
+	}
+	return pool, nil
+}
+
+// SetPoolUtilizationThresholds is the resolver for the SetPoolUtilizationThresholds field.
+func (r *mutationResolver) SetPoolUtilizationThresholds(ctx context.Context, poolID int, warningThreshold *float64, criticalThreshold *float64) (*ent.ResourcePool, error) {
+	pool, err := p.SetPoolUtilizationThresholds(ctx, r.ClientFrom(ctx), poolID, warningThreshold, criticalThreshold)
+	if err != nil {
+		log.Error(ctx, err, "Unable to set utilization thresholds of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to set utilization thresholds: %v", err)
+	}
+	return pool, nil
+}
+
+// RecomputePoolCapacity is the resolver for the RecomputePoolCapacity field.
+func (r *mutationResolver) RecomputePoolCapacity(ctx context.Context, poolID int) (*model.PoolCapacityPayload, error) {
+	capacity, err := p.RecomputePoolCapacity(ctx, r.ClientFrom(ctx), poolID)
+	if err != nil {
+		log.Error(ctx, err, "Unable to recompute capacity of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to recompute capacity: %v", err)
+	}
+	return poolCapacityPayload(capacity), nil
+}
+
+// CreatePoolTemplate is the resolver for the CreatePoolTemplate field.
+func (r *mutationResolver) CreatePoolTemplate(ctx context.Context, input model.CreatePoolTemplateInput) (*ent.PoolTemplate, error) {
+	template, err := createPoolTemplate(ctx, r.ClientFrom(ctx), input, nil)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to create pool template: %v", err)
+	}
+	return template, nil
+}
+
+// DeletePoolTemplate is the resolver for the DeletePoolTemplate field.
+func (r *mutationResolver) DeletePoolTemplate(ctx context.Context, templateID int) (string, error) {
+	client := r.ClientFrom(ctx)
+	template, err := client.PoolTemplate.Get(ctx, templateID)
+	if err != nil {
+		return "", gqlerror.Errorf("Unable to find pool template: %v", err)
+	}
+	if isChild, err := template.QueryParent().Exist(ctx); err != nil {
+		return "", gqlerror.Errorf("Unable to delete pool template: %v", err)
+	} else if isChild {
+		return "", gqlerror.Errorf("Unable to delete pool template \"%s\", only root templates can be deleted", template.Name)
+	}
+	if err := deletePoolTemplate(ctx, client, template); err != nil {
+		return "", gqlerror.Errorf("Unable to delete pool template: %v", err)
+	}
+	return "Pool template deleted successfully", nil
+}
+
+// InstantiatePoolTemplate is the resolver for the InstantiatePoolTemplate field.
+func (r *mutationResolver) InstantiatePoolTemplate(ctx context.Context, templateID int, variables map[string]interface{}) (*model.InstantiatePoolTemplatePayload, error) {
+	template, err := r.ClientFrom(ctx).PoolTemplate.Get(ctx, templateID)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to find pool template: %v", err)
+	}
+	if isChild, err := template.QueryParent().Exist(ctx); err != nil {
+		return nil, gqlerror.Errorf("Unable to instantiate pool template: %v", err)
+	} else if isChild {
+		return nil, gqlerror.Errorf("Unable to instantiate pool template \"%s\", only root templates can be instantiated", template.Name)
+	}
+
+	created, err := r.instantiatePoolTemplate(ctx, template, variables, nil)
+	if err != nil {
+		log.Error(ctx, err, "Unable to instantiate pool template ID %d", templateID)
+		return nil, gqlerror.Errorf("Unable to instantiate pool template: %v", err)
+	}
+	return &model.InstantiatePoolTemplatePayload{Pool: created[0], Pools: created}, nil
+}
+
+// AddSetPoolValues is the resolver for the AddSetPoolValues field.
+func (r *mutationResolver) AddSetPoolValues(ctx context.Context, input model.AddSetPoolValuesInput) (*model.AddSetPoolValuesPayload, error) {
+	emptyRetVal := model.AddSetPoolValuesPayload{Pool: nil, Resources: []*ent.Resource{}}
+
+	pool, err := existingSetPool(ctx, r.ClientFrom(ctx), input.PoolID)
+	if err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to add values to pool: %v", err)
+	}
+
+	created, err := pool.AddValues(p.ToRawTypes(input.PoolValues))
+	if err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to add values to pool: %v", err)
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to add values to pool: %v", err)
+	}
+	// reload the pool, its capacity counters changed
+	updated, err := r.ClientFrom(ctx).ResourcePool.Get(ctx, input.PoolID)
+	if err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to add values to pool: %v", err)
+	}
+
+	return &model.AddSetPoolValuesPayload{Pool: updated, Resources: created}, nil
+}
+
+// RemoveSetPoolValues is the resolver for the RemoveSetPoolValues field.
+func (r *mutationResolver) RemoveSetPoolValues(ctx context.Context, input model.RemoveSetPoolValuesInput) (*model.RemoveSetPoolValuesPayload, error) {
+	emptyRetVal := model.RemoveSetPoolValuesPayload{Pool: nil}
+
+	pool, err := existingSetPool(ctx, r.ClientFrom(ctx), input.PoolID)
+	if err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to remove values from pool: %v", err)
+	}
+
+	force := input.Force != nil && *input.Force
+	if err := pool.RemoveValues(p.ToRawTypes(input.PoolValues), force); err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to remove values from pool: %v", err)
+	}
+	if err := p.UpdatePoolCapacity(pool); err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to remove values from pool: %v", err)
+	}
+	// reload the pool, its capacity counters changed
+	updated, err := r.ClientFrom(ctx).ResourcePool.Get(ctx, input.PoolID)
+	if err != nil {
+		return &emptyRetVal, gqlerror.Errorf("Unable to remove values from pool: %v", err)
+	}
+
+	return &model.RemoveSetPoolValuesPayload{Pool: updated}, nil
+}
+
 // CreateResourceType is the resolver for the CreateResourceType field.
 func (r *mutationResolver) CreateResourceType(ctx context.Context, input model.CreateResourceTypeInput) (*model.CreateResourceTypePayload, error) {
 	var client = r.ClientFrom(ctx)
@@ -617,6 +1097,10 @@ func (r *mutationResolver) UpdateResourceAltID(ctx context.Context, input map[st
 		return nil, gqlerror.Errorf("Unable to query resource: %v", err)
 	}
 	var client = r.ClientFrom(ctx)
+	before, err := p.ResourceState(ctx, queryResource, poolID)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to update resource alternative ID: %v", err)
+	}
 	for k, v := range alternativeID {
 		queryResource.AlternateID[k] = v
 	}
@@ -624,9 +1108,17 @@ func (r *mutationResolver) UpdateResourceAltID(ctx context.Context, input map[st
 		log.Error(ctx, err, "Unable to update resource alternative ID %v", alternativeID)
 		return queryResource, gqlerror.Errorf("Unable to update resource alternative ID: %v", err)
 	}
+	if err := p.RecordResourceEvent(ctx, client, resourceevent.EventUpdateAlternativeID, poolID, queryResource.ID, before); err != nil {
+		return nil, gqlerror.Errorf("Unable to update resource alternative ID: %v", err)
+	}
 	return queryResource, nil
 }
 
+// PoolType is the resolver for the PoolType field.
+func (r *poolTemplateResolver) PoolType(ctx context.Context, obj *ent.PoolTemplate) (resourcePool.PoolType, error) {
+	return resourcePool.PoolType(obj.PoolType), nil
+}
+
 // Type is the resolver for the Type field.
 func (r *propertyTypeResolver) Type(ctx context.Context, obj *ent.PropertyType) (string, error) {
 	// Just converts enum to string
@@ -635,22 +1127,11 @@ func (r *propertyTypeResolver) Type(ctx context.Context, obj *ent.PropertyType)
 
 // QueryPoolCapacity is the resolver for the QueryPoolCapacity field.
 func (r *queryResolver) QueryPoolCapacity(ctx context.Context, poolID int) (*model.PoolCapacityPayload, error) {
-	pool, err := p.ExistingPoolFromId(ctx, r.ClientFrom(ctx), poolID)
-
+	pool, err := r.ClientFrom(ctx).ResourcePool.Get(ctx, poolID)
 	if err != nil {
 		return nil, gqlerror.Errorf("Unable to find pool: %v", err)
 	}
-
-	freeCapacity, utilizedCapacity, err2 := pool.Capacity()
-
-	if err2 != nil {
-		return nil, gqlerror.Errorf("Unable to compute capacity: %v", err2)
-	}
-
-	return &model.PoolCapacityPayload{
-		FreeCapacity:     freeCapacity,
-		UtilizedCapacity: utilizedCapacity,
-	}, nil
+	return r.ResourcePool().Capacity(ctx, pool)
 }
 
 // QueryPoolTypes is the resolver for the QueryPoolTypes field.
@@ -733,6 +1214,16 @@ func (r *queryResolver) QueryAllocationStrategies(ctx context.Context, byName *s
 	}
 }
 
+// QueryAllocationStrategyRevisionDiff is the resolver for the QueryAllocationStrategyRevisionDiff field.
+func (r *queryResolver) QueryAllocationStrategyRevisionDiff(ctx context.Context, allocationStrategyID int, fromRevision int, toRevision int) (string, error) {
+	diff, err := p.DiffStrategyRevisions(ctx, r.ClientFrom(ctx), allocationStrategyID, fromRevision, toRevision)
+	if err != nil {
+		log.Error(ctx, err, "Unable to diff revisions of allocation strategy ID %d", allocationStrategyID)
+		return "", gqlerror.Errorf("Unable to diff strategy revisions: %v", err)
+	}
+	return diff, nil
+}
+
 // QueryResourceTypes is the resolver for the QueryResourceTypes field.
 func (r *queryResolver) QueryResourceTypes(ctx context.Context, byName *string) ([]*ent.ResourceType, error) {
 	client := r.ClientFrom(ctx)
@@ -754,6 +1245,21 @@ func (r *queryResolver) QueryResourceTypes(ctx context.Context, byName *string)
 	}
 }
 
+// QueryPoolTemplates is the resolver for the QueryPoolTemplates field.
+func (r *queryResolver) QueryPoolTemplates(ctx context.Context, byName *string) ([]*ent.PoolTemplate, error) {
+	query := r.ClientFrom(ctx).PoolTemplate.Query()
+	if byName != nil {
+		query = query.Where(pooltemplate.Name(*byName))
+	}
+
+	if templates, err := query.All(ctx); err != nil {
+		log.Error(ctx, err, "Unable to retrieve pool templates")
+		return nil, gqlerror.Errorf("Unable to query pool templates: %v", err)
+	} else {
+		return templates, nil
+	}
+}
+
 // QueryRequiredPoolProperties is the resolver for the QueryRequiredPoolProperties field.
 func (r *queryResolver) QueryRequiredPoolProperties(ctx context.Context, allocationStrategyName string) ([]*ent.PropertyType, error) {
 	allocationStrategy, err := r.ClientFrom(ctx).AllocationStrategy.Query().Where(allocationstrategy.Name(allocationStrategyName)).Only(ctx)
@@ -803,7 +1309,7 @@ func (r *queryResolver) QueryEmptyResourcePools(ctx context.Context, resourceTyp
 }
 
 // QueryResourcePools is the resolver for the QueryResourcePools field.
-func (r *queryResolver) QueryResourcePools(ctx context.Context, resourceTypeID *int, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor, filterByResources map[string]interface{}, sortBy *ent.ResourcePoolOrder) (*ent.ResourcePoolConnection, error) {
+func (r *queryResolver) QueryResourcePools(ctx context.Context, resourceTypeID *int, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor, filterByResources map[string]interface{}, sortBy *ent.ResourcePoolOrder, lifecycleState *resourcePool.LifecycleState) (*ent.ResourcePoolConnection, error) {
 	client := r.ClientFrom(ctx)
 	query := client.ResourcePool.Query()
 
@@ -827,6 +1333,10 @@ func (r *queryResolver) QueryResourcePools(ctx context.Context, resourceTypeID *
 		query.Where(resourcePoolTagPredicate(tags))
 	}
 
+	if lifecycleState != nil {
+		query.Where(resourcePool.LifecycleStateEQ(*lifecycleState))
+	}
+
 	if resourcePools, err := query.Paginate(ctx, after, first, before, last, ent.WithResourcePoolOrder(sortBy)); err != nil {
 		log.Error(ctx, err, "Unable to retrieve resource pools")
 		return nil, gqlerror.Errorf("Unable to query resource pools: %v", err)
@@ -883,6 +1393,25 @@ func (r *queryResolver) QueryRecentlyActiveResources(ctx context.Context, fromDa
 	return resources, nil
 }
 
+// QueryResourcesByLeaseExpiry is the resolver for the QueryResourcesByLeaseExpiry field.
+func (r *queryResolver) QueryResourcesByLeaseExpiry(ctx context.Context, expiresBefore string, poolID *int, first *int, last *int, before *ent.Cursor, after *ent.Cursor) (*ent.ResourceConnection, error) {
+	expiresBeforeTime, err := time.Parse(time.RFC3339, expiresBefore)
+	if err != nil {
+		log.Error(ctx, err, "Unable to parse lease expiry: "+expiresBefore+". Must be in RFC3339 format.")
+		return nil, gqlerror.Errorf("Unable to parse lease expiry: "+expiresBefore+
+			". Must be in RFC3339 format. Error: %v", err)
+	}
+
+	query := r.ClientFrom(ctx).Resource.Query().
+		Where(resource.StatusEQ(resource.StatusClaimed)).
+		Where(resource.LeaseExpiresAtLT(expiresBeforeTime))
+	if poolID != nil {
+		query = query.Where(resource.HasPoolWith(resourcePool.ID(*poolID)))
+	}
+
+	return query.Paginate(ctx, after, first, before, last)
+}
+
 // QueryResourcePoolHierarchyPath is the resolver for the QueryResourcePoolHierarchyPath field.
 func (r *queryResolver) QueryResourcePoolHierarchyPath(ctx context.Context, poolID int) ([]*ent.ResourcePool, error) {
 	client := r.ClientFrom(ctx)
@@ -906,8 +1435,109 @@ func (r *queryResolver) QueryResourcePoolHierarchyPath(ctx context.Context, pool
 	return hierarchy, nil
 }
 
+// QueryPoolTreeCapacity is the resolver for the QueryPoolTreeCapacity field.
+func (r *queryResolver) QueryPoolTreeCapacity(ctx context.Context, poolID int) (*model.PoolTreeCapacityPayload, error) {
+	tree, err := p.QueryPoolTreeCapacity(ctx, r.ClientFrom(ctx), poolID)
+	if err != nil {
+		log.Error(ctx, err, "Unable to compute capacity of pool hierarchy of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to compute capacity of pool hierarchy: %v", err)
+	}
+	return poolTreeCapacityPayload(tree), nil
+}
+
+// QueryResourceEvents is the resolver for the QueryResourceEvents field.
+func (r *queryResolver) QueryResourceEvents(ctx context.Context, poolID *int, user *string, fromDatetime *string, toDatetime *string, first *int, last *int, before *ent.Cursor, after *ent.Cursor) (*ent.ResourceEventConnection, error) {
+	query := r.ClientFrom(ctx).ResourceEvent.Query()
+	if poolID != nil {
+		query = query.Where(resourceevent.PoolID(*poolID))
+	}
+	if user != nil {
+		query = query.Where(resourceevent.User(*user))
+	}
+	from, err := parseTimeFilter(ctx, "from", fromDatetime)
+	if err != nil {
+		return nil, err
+	}
+	if from != nil {
+		query = query.Where(resourceevent.CreatedAtGTE(*from))
+	}
+	to, err := parseTimeFilter(ctx, "to", toDatetime)
+	if err != nil {
+		return nil, err
+	}
+	if to != nil {
+		query = query.Where(resourceevent.CreatedAtLTE(*to))
+	}
+
+	return query.Paginate(ctx, after, first, before, last)
+}
+
+// QueryAuditLog is the resolver for the QueryAuditLog field.
+func (r *queryResolver) QueryAuditLog(ctx context.Context, entityType *string, entityID *int, user *string, fromDatetime *string, toDatetime *string, first *int, last *int, before *ent.Cursor, after *ent.Cursor) (*ent.AuditLogConnection, error) {
+	query := r.ClientFrom(ctx).AuditLog.Query()
+	if entityType != nil {
+		query = query.Where(auditlog.EntityType(*entityType))
+	}
+	if entityID != nil {
+		query = query.Where(auditlog.EntityID(*entityID))
+	}
+	if user != nil {
+		query = query.Where(auditlog.User(*user))
+	}
+	from, err := parseTimeFilter(ctx, "from", fromDatetime)
+	if err != nil {
+		return nil, err
+	}
+	if from != nil {
+		query = query.Where(auditlog.CreatedAtGTE(*from))
+	}
+	to, err := parseTimeFilter(ctx, "to", toDatetime)
+	if err != nil {
+		return nil, err
+	}
+	if to != nil {
+		query = query.Where(auditlog.CreatedAtLTE(*to))
+	}
+
+	return query.Paginate(ctx, after, first, before, last)
+}
+
+// QueryFreeRanges is the resolver for the QueryFreeRanges field.
+func (r *queryResolver) QueryFreeRanges(ctx context.Context, poolID int) (*model.FreeRangesPayload, error) {
+	freeRanges, err := p.QueryFreeRanges(ctx, r.ClientFrom(ctx), poolID)
+	if err != nil {
+		log.Error(ctx, err, "Unable to list free ranges of pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to list free ranges: %v", err)
+	}
+	return freeRangesPayload(freeRanges), nil
+}
+
+// PreviewClaim is the resolver for the PreviewClaim field.
+func (r *queryResolver) PreviewClaim(ctx context.Context, poolID int, userInput map[string]interface{}, count *int) (*model.PreviewClaimPayload, error) {
+	claimCount := 1
+	if count != nil {
+		claimCount = *count
+	}
+	input, err := normalizeUserInput(userInput)
+	if err != nil {
+		return nil, err
+	}
+
+	preview, err := p.PreviewClaim(ctx, r.ClientFrom(ctx), poolID, input, claimCount)
+	if err != nil {
+		log.Error(ctx, err, "Unable to preview claim from pool ID %d", poolID)
+		return nil, gqlerror.Errorf("Unable to preview claim: %v", err)
+	}
+
+	resources := make([]map[string]interface{}, 0, len(preview.Resources))
+	for _, props := range preview.Resources {
+		resources = append(resources, props)
+	}
+	return &model.PreviewClaimPayload{Resources: resources, Stderr: preview.StrategyLog}, nil
+}
+
 // QueryRootResourcePools is the resolver for the QueryRootResourcePools field.
-func (r *queryResolver) QueryRootResourcePools(ctx context.Context, resourceTypeID *int, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor, filterByResources map[string]interface{}, sortBy *ent.ResourcePoolOrder) (*ent.ResourcePoolConnection, error) {
+func (r *queryResolver) QueryRootResourcePools(ctx context.Context, resourceTypeID *int, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor, filterByResources map[string]interface{}, sortBy *ent.ResourcePoolOrder, lifecycleState *resourcePool.LifecycleState) (*ent.ResourcePoolConnection, error) {
 	client := r.ClientFrom(ctx)
 	query := client.ResourcePool.
 		Query().
@@ -933,6 +1563,10 @@ func (r *queryResolver) QueryRootResourcePools(ctx context.Context, resourceType
 		query.Where(resourcePoolTagPredicate(tags))
 	}
 
+	if lifecycleState != nil {
+		query.Where(resourcePool.LifecycleStateEQ(*lifecycleState))
+	}
+
 	if resourcePools, err := query.Paginate(ctx, after, first, before, last, ent.WithResourcePoolOrder(sortBy)); err != nil {
 		log.Error(ctx, err, "Unable to retrieve root resource pools")
 		return nil, gqlerror.Errorf("Unable to query resource pools: %v", err)
@@ -942,7 +1576,7 @@ func (r *queryResolver) QueryRootResourcePools(ctx context.Context, resourceType
 }
 
 // QueryLeafResourcePools is the resolver for the QueryLeafResourcePools field.
-func (r *queryResolver) QueryLeafResourcePools(ctx context.Context, resourceTypeID *int, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor, filterByResources map[string]interface{}, sortBy *ent.ResourcePoolOrder) (*ent.ResourcePoolConnection, error) {
+func (r *queryResolver) QueryLeafResourcePools(ctx context.Context, resourceTypeID *int, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor, filterByResources map[string]interface{}, sortBy *ent.ResourcePoolOrder, lifecycleState *resourcePool.LifecycleState) (*ent.ResourcePoolConnection, error) {
 	client := r.ClientFrom(ctx)
 	query := client.ResourcePool.
 		Query().
@@ -969,6 +1603,10 @@ func (r *queryResolver) QueryLeafResourcePools(ctx context.Context, resourceType
 		query.Where(resourcePoolTagPredicate(tags))
 	}
 
+	if lifecycleState != nil {
+		query.Where(resourcePool.LifecycleStateEQ(*lifecycleState))
+	}
+
 	if resourcePools, err := query.Paginate(ctx, after, first, before, last, ent.WithResourcePoolOrder(sortBy)); err != nil {
 		log.Error(ctx, err, "Unable to retrieve leaf resource pools")
 		return nil, gqlerror.Errorf("Unable to query resource pools: %v", err)
@@ -977,6 +1615,27 @@ func (r *queryResolver) QueryLeafResourcePools(ctx context.Context, resourceType
 	}
 }
 
+// QueryPoolsOverThreshold is the resolver for the QueryPoolsOverThreshold field.
+func (r *queryResolver) QueryPoolsOverThreshold(ctx context.Context, level *resourcePool.UtilizationLevel, first *int, last *int, before *ent.Cursor, after *ent.Cursor) (*ent.ResourcePoolConnection, error) {
+	levels := []resourcePool.UtilizationLevel{resourcePool.UtilizationLevelWarning, resourcePool.UtilizationLevelCritical}
+	if level != nil {
+		switch *level {
+		case resourcePool.UtilizationLevelOk:
+			levels = append(levels, resourcePool.UtilizationLevelOk)
+		case resourcePool.UtilizationLevelCritical:
+			levels = []resourcePool.UtilizationLevel{resourcePool.UtilizationLevelCritical}
+		}
+	}
+
+	query := r.ClientFrom(ctx).ResourcePool.Query().Where(resourcePool.UtilizationLevelIn(levels...))
+	if resourcePools, err := query.Paginate(ctx, after, first, before, last); err != nil {
+		log.Error(ctx, err, "Unable to retrieve resource pools over threshold")
+		return nil, gqlerror.Errorf("Unable to query resource pools: %v", err)
+	} else {
+		return resourcePools, nil
+	}
+}
+
 // SearchPoolsByTags is the resolver for the SearchPoolsByTags field.
 func (r *queryResolver) SearchPoolsByTags(ctx context.Context, tags *model.TagOr, first *int, last *int, before *ent.Cursor, after *ent.Cursor) (*ent.ResourcePoolConnection, error) {
 	var client = r.ClientFrom(ctx)
@@ -1063,9 +1722,34 @@ func (r *resourceResolver) AlternativeID(ctx context.Context, obj *ent.Resource)
 	return obj.AlternateID, nil
 }
 
+// LeaseExpiresAt is the resolver for the LeaseExpiresAt field.
+func (r *resourceResolver) LeaseExpiresAt(ctx context.Context, obj *ent.Resource) (*string, error) {
+	if obj.LeaseExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := obj.LeaseExpiresAt.Format(time.RFC3339)
+	return &expiresAt, nil
+}
+
+// History is the resolver for the history field.
+func (r *resourceResolver) History(ctx context.Context, obj *ent.Resource, first *int, last *int, before *ent.Cursor, after *ent.Cursor) (*ent.ResourceEventConnection, error) {
+	return r.ClientFrom(ctx).ResourceEvent.Query().
+		Where(resourceevent.ResourceID(obj.ID)).
+		Paginate(ctx, after, first, before, last)
+}
+
+// CreatedAt is the resolver for the CreatedAt field.
+func (r *resourceEventResolver) CreatedAt(ctx context.Context, obj *ent.ResourceEvent) (string, error) {
+	return obj.CreatedAt.Format(time.RFC3339), nil
+}
+
 // Capacity is the resolver for the Capacity field.
 func (r *resourcePoolResolver) Capacity(ctx context.Context, obj *ent.ResourcePool) (*model.PoolCapacityPayload, error) {
-	return r.Query().QueryPoolCapacity(ctx, obj.ID)
+	capacity, err := p.StoredPoolCapacity(ctx, r.ClientFrom(ctx), obj)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to compute capacity: %v", err)
+	}
+	return poolCapacityPayload(capacity), nil
 }
 
 // PoolProperties is the resolver for the PoolProperties field.
@@ -1135,9 +1819,20 @@ func (r *resourcePoolResolver) AllocatedResources(ctx context.Context, obj *ent.
 	return resourceConnection, err
 }
 
+// AllocationStrategyRevision returns generated.AllocationStrategyRevisionResolver implementation.
+func (r *Resolver) AllocationStrategyRevision() generated.AllocationStrategyRevisionResolver {
+	return &allocationStrategyRevisionResolver{r}
+}
+
+// AuditLog returns generated.AuditLogResolver implementation.
+func (r *Resolver) AuditLog() generated.AuditLogResolver { return &auditLogResolver{r} }
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
+// PoolTemplate returns generated.PoolTemplateResolver implementation.
+func (r *Resolver) PoolTemplate() generated.PoolTemplateResolver { return &poolTemplateResolver{r} }
+
 // PropertyType returns generated.PropertyTypeResolver implementation.
 func (r *Resolver) PropertyType() generated.PropertyTypeResolver { return &propertyTypeResolver{r} }
 
@@ -1147,13 +1842,20 @@ func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 // Resource returns generated.ResourceResolver implementation.
 func (r *Resolver) Resource() generated.ResourceResolver { return &resourceResolver{r} }
 
+// ResourceEvent returns generated.ResourceEventResolver implementation.
+func (r *Resolver) ResourceEvent() generated.ResourceEventResolver { return &resourceEventResolver{r} }
+
 // ResourcePool returns generated.ResourcePoolResolver implementation.
 func (r *Resolver) ResourcePool() generated.ResourcePoolResolver { return &resourcePoolResolver{r} }
 
+type allocationStrategyRevisionResolver struct{ *Resolver }
+type auditLogResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type poolTemplateResolver struct{ *Resolver }
 type propertyTypeResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type resourceResolver struct{ *Resolver }
+type resourceEventResolver struct{ *Resolver }
 type resourcePoolResolver struct{ *Resolver }
 
 // !!! WARNING !!!
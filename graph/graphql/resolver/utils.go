@@ -3,8 +3,6 @@ package resolver
 import (
 	"context"
 	"encoding/json"
-	"entgo.io/ent/dialect/sql"
-	"entgo.io/ent/dialect/sql/sqljson"
 	"fmt"
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/predicate"
@@ -15,7 +13,9 @@ import (
 	"github.com/net-auto/resourceManager/ent/tag"
 	"github.com/net-auto/resourceManager/graph/graphql/model"
 	"github.com/net-auto/resourceManager/pools"
+	strategies "github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/generated"
 	"strconv"
+	"time"
 	//"github.com/net-auto/resourceManager/graph/graphql/model"
 	log "github.com/net-auto/resourceManager/logging"
 	"github.com/pkg/errors"
@@ -170,11 +170,7 @@ func QueryResourcesByAltId(ctx context.Context, client *ent.Client, alternativeI
 	if poolId != nil {
 		res, err := client.Resource.Query().
 			Where(resource.HasPoolWith(resourcePool.ID(*poolId))).
-			Where(func(selector *sql.Selector) {
-				for k, v := range alternativeId {
-					selector.Where(sqljson.ValueContains("alternate_id", v, sqljson.Path(k)))
-				}
-			}).
+			Where(pools.HasAlternativeId(alternativeId)).
 			Paginate(ctx, after, first, before, last)
 
 		if err != nil {
@@ -187,11 +183,7 @@ func QueryResourcesByAltId(ctx context.Context, client *ent.Client, alternativeI
 		}
 	} else {
 		res, err := client.Resource.Query().
-			Where(func(selector *sql.Selector) {
-				for k, v := range alternativeId {
-					selector.Where(sqljson.ValueContains("alternate_id", v, sqljson.Path(k)))
-				}
-			}).
+			Where(pools.HasAlternativeId(alternativeId)).
 			Paginate(ctx, after, first, before, last)
 
 		if err != nil {
@@ -209,6 +201,129 @@ func QueryResourcesByAltId(ctx context.Context, client *ent.Client, alternativeI
 }
 
 func ClaimResource(pool pools.Pool, userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (*ent.Resource, error) {
+	input, err := normalizeUserInput(userInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if res, err := pool.ClaimResource(input, description, alternativeId); err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resource: %v", err)
+	} else {
+		return res, nil
+	}
+}
+
+func ClaimResourceIdempotent(pool pools.Pool, userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (*ent.Resource, error) {
+	input, err := normalizeUserInput(userInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if res, err := pools.ClaimResourceIdempotent(pool, input, description, alternativeId); err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resource: %v", err)
+	} else {
+		return res, nil
+	}
+}
+
+func ClaimResources(pool pools.Pool, count int, userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (ent.Resources, error) {
+	input, err := normalizeUserInput(userInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if res, err := pool.ClaimResources(count, input, description, alternativeId); err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resources: %v", err)
+	} else {
+		return res, nil
+	}
+}
+
+// freeResourcesError converts FreeResources failure into a graphql error, listing every failed resource in extensions
+func freeResourcesError(err error) *gqlerror.Error {
+	var freeErr *pools.FreeResourcesError
+	if !errors.As(err, &freeErr) {
+		return gqlerror.Errorf("Unable to free resources: %v", err)
+	}
+
+	failures := make([]map[string]interface{}, 0, len(freeErr.Failures))
+	for _, failure := range freeErr.Failures {
+		item := map[string]interface{}{"error": failure.Err.Error()}
+		if failure.ResourceID != nil {
+			item["resourceId"] = *failure.ResourceID
+		} else {
+			item["input"] = failure.Properties
+		}
+		failures = append(failures, item)
+	}
+
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf("Unable to free resources: %v", err),
+		Extensions: map[string]interface{}{"failures": failures},
+	}
+}
+
+// updateResourcePoolError converts UpdateResourcePool failure into a graphql error,
+// listing every resource not fitting into new pool properties in extensions
+func updateResourcePoolError(err error) *gqlerror.Error {
+	var conflictErr *pools.PoolPropertiesConflictError
+	if !errors.As(err, &conflictErr) {
+		return gqlerror.Errorf("Unable to update pool: %v", err)
+	}
+
+	conflicts := make([]map[string]interface{}, 0, len(conflictErr.Conflicts))
+	for _, conflict := range conflictErr.Conflicts {
+		conflicts = append(conflicts, map[string]interface{}{
+			"resourceId": conflict.ResourceID,
+			"properties": conflict.Properties,
+		})
+	}
+
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf("Unable to update pool: %v", err),
+		Extensions: map[string]interface{}{"conflicts": conflicts},
+	}
+}
+
+// existingSetPool loads a pool and makes sure it is a set pool, its values can be changed after creation
+func existingSetPool(ctx context.Context, client *ent.Client, poolId int) (*pools.SetPool, error) {
+	pool, err := pools.ExistingPoolFromId(ctx, client, poolId)
+	if err != nil {
+		return nil, err
+	}
+	setPool, ok := pool.(*pools.SetPool)
+	if !ok {
+		return nil, errors.Errorf("Pool #%d is not a set pool", poolId)
+	}
+	return setPool, nil
+}
+
+func poolOfResource(ctx context.Context, client *ent.Client, resourceId int) (pools.Pool, error) {
+	pool, err := client.Resource.Query().
+		Where(resource.ID(resourceId)).
+		QueryPool().
+		Only(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find pool of resource ID %d", resourceId)
+		return nil, errors.Wrapf(err, "Unable to find pool of resource #%d", resourceId)
+	}
+	return pools.ExistingPoolFromId(ctx, client, pool.ID)
+}
+
+// leaseResource sets the lease of a claimed resource, nil leaseSeconds keeps the current lease of the resource
+// so that retrying an idempotent claim without a lease does not remove it
+func leaseResource(ctx context.Context, client *ent.Client, res *ent.Resource, leaseSeconds *int) (*ent.Resource, error) {
+	if leaseSeconds == nil {
+		return res, nil
+	}
+	leased, err := pools.SetResourceLease(ctx, client, res, leaseSeconds)
+	if err != nil {
+		return nil, gqlerror.Errorf("Unable to claim resource: %v", err)
+	}
+	return leased, nil
+}
+
+func normalizeUserInput(userInput map[string]interface{}) (map[string]interface{}, error) {
 	input := make(map[string]interface{})
 
 	for key, value := range userInput {
@@ -238,11 +353,7 @@ func ClaimResource(pool pools.Pool, userInput map[string]interface{}, descriptio
 		}
 	}
 
-	if res, err := pool.ClaimResource(input, description, alternativeId); err != nil {
-		return nil, gqlerror.Errorf("Unable to claim resource: %v", err)
-	} else {
-		return res, nil
-	}
+	return input, nil
 }
 
 func FilterResourcePoolByAllocatedResources(ctx context.Context, query *ent.ResourcePoolQuery, filter map[string]interface{}) ([]int, error) {
@@ -330,3 +441,143 @@ func filterByJsonNumberVal(ctx context.Context, query *ent.PropertyTypeQuery, js
 		return nil, gqlerror.Errorf("Unable to filter by json number value: %v", err)
 	}
 }
+
+func poolCapacityPayload(capacity *pools.PoolCapacity) *model.PoolCapacityPayload {
+	return &model.PoolCapacityPayload{
+		FreeCapacity:          capacity.Free.String(),
+		UtilizedCapacity:      capacity.Utilized.String(),
+		Free:                  model.BigInt{Int: capacity.Free},
+		Utilized:              model.BigInt{Int: capacity.Utilized},
+		Total:                 model.BigInt{Int: capacity.Total()},
+		UtilizationPercentage: capacity.UtilizationPercentage(),
+	}
+}
+
+func poolTreeCapacityPayload(tree *pools.PoolTreeCapacity) *model.PoolTreeCapacityPayload {
+	payload := &model.PoolTreeCapacityPayload{
+		Pool:                  tree.Pool,
+		Free:                  model.BigInt{Int: tree.Capacity.Free},
+		Utilized:              model.BigInt{Int: tree.Capacity.Utilized},
+		Total:                 model.BigInt{Int: tree.Capacity.Total()},
+		UtilizationPercentage: tree.Capacity.UtilizationPercentage(),
+		Levels:                make([]*model.PoolLevelCapacity, 0, len(tree.Levels)),
+		Pools:                 make([]*model.PoolNodeCapacity, 0, len(tree.Pools)),
+	}
+	for _, level := range tree.Levels {
+		payload.Levels = append(payload.Levels, &model.PoolLevelCapacity{
+			Depth:           level.Depth,
+			PoolCount:       level.PoolCount,
+			Free:            model.BigInt{Int: level.Capacity.Free},
+			Utilized:        model.BigInt{Int: level.Capacity.Utilized},
+			CarvedOut:       model.BigInt{Int: level.CarvedOut},
+			DirectlyClaimed: model.BigInt{Int: level.DirectlyClaimed},
+		})
+	}
+	for _, node := range tree.Pools {
+		payload.Pools = append(payload.Pools, &model.PoolNodeCapacity{
+			Pool:            node.Pool,
+			ParentPoolID:    node.ParentPoolID,
+			Depth:           node.Depth,
+			Free:            model.BigInt{Int: node.Capacity.Free},
+			Utilized:        model.BigInt{Int: node.Capacity.Utilized},
+			CarvedOut:       model.BigInt{Int: node.CarvedOut},
+			DirectlyClaimed: model.BigInt{Int: node.DirectlyClaimed},
+		})
+	}
+	return payload
+}
+
+func freeRangesPayload(freeRanges *strategies.FreeRanges) *model.FreeRangesPayload {
+	payload := &model.FreeRangesPayload{
+		Ranges:    make([]*model.FreeRange, 0, len(freeRanges.Ranges)),
+		Blocks:    make([]*model.FreeBlock, 0, len(freeRanges.Blocks)),
+		Histogram: make([]*model.FreeRangesBucket, 0, len(freeRanges.Histogram)),
+	}
+	for _, free := range freeRanges.Ranges {
+		payload.Ranges = append(payload.Ranges, &model.FreeRange{From: free.From, To: free.To, Size: model.BigInt{Int: free.Size}})
+	}
+	for _, block := range freeRanges.Blocks {
+		payload.Blocks = append(payload.Blocks, &model.FreeBlock{
+			Address: block.Address, Prefix: block.Prefix, Size: model.BigInt{Int: block.Size}})
+	}
+	for _, bucket := range freeRanges.Histogram {
+		payload.Histogram = append(payload.Histogram, &model.FreeRangesBucket{
+			Prefix: bucket.Prefix, Size: model.BigInt{Int: bucket.Size}, Count: bucket.Count})
+	}
+	return payload
+}
+
+func poolTreeDeletionPayload(deletion *pools.PoolTreeDeletion, dryRun bool) (*model.DeleteResourcePoolTreePayload, error) {
+	payload := &model.DeleteResourcePoolTreePayload{
+		DryRun:           dryRun,
+		Pools:            make([]*model.DeletedResourcePool, 0, len(deletion.Pools)),
+		ClaimedResources: make([]*model.DeletedResource, 0, len(deletion.ClaimedResources)),
+	}
+	for _, pool := range deletion.Pools {
+		deleted := &model.DeletedResourcePool{ID: pool.ID, Name: pool.Name, PoolType: pool.PoolType}
+		if parentPoolID, ok := deletion.ParentPoolIDs[pool.ID]; ok {
+			deleted.ParentPoolID = &parentPoolID
+		}
+		payload.Pools = append(payload.Pools, deleted)
+	}
+	for _, res := range deletion.ClaimedResources {
+		properties, err := pools.PropertiesToMap(res.Edges.Properties)
+		if err != nil {
+			return nil, gqlerror.Errorf("Unable to serialize properties of resource ID %d: %v", res.ID, err)
+		}
+		payload.ClaimedResources = append(payload.ClaimedResources, &model.DeletedResource{
+			ID:            res.ID,
+			PoolID:        res.Edges.Pool.ID,
+			Properties:    properties,
+			Description:   res.Description,
+			AlternativeID: res.AlternateID,
+		})
+	}
+	return payload, nil
+}
+
+// parseTimeFilter parses an optional RFC3339 time used to filter event queries
+func parseTimeFilter(ctx context.Context, name string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		log.Error(ctx, err, "Unable to parse date %s: %s. Must be in RFC3339 format.", name, *value)
+		return nil, gqlerror.Errorf("Unable to parse date %s: %s. Must be in RFC3339 format. Error: %v", name, *value, err)
+	}
+	return &parsed, nil
+}
+
+func upgradePoolStrategyPayload(upgrade *pools.StrategyUpgrade) *model.UpgradePoolStrategyPayload {
+	payload := &model.UpgradePoolStrategyPayload{
+		Pool:         upgrade.Pool,
+		FromRevision: upgrade.FromRevision,
+		ToRevision:   upgrade.ToRevision,
+		DryRunError:  upgrade.DryRunError,
+		Upgraded:     upgrade.Upgraded,
+	}
+	if upgrade.Capacity != nil {
+		payload.Capacity = poolCapacityPayload(upgrade.Capacity)
+	}
+	if upgrade.Claim != nil {
+		payload.Claim = upgrade.Claim
+	}
+	return payload
+}
+
+func runStrategyTestsPayload(results []pools.StrategyTestResult) *model.RunStrategyTestsPayload {
+	payload := &model.RunStrategyTestsPayload{Passed: true, Results: []*model.StrategyTestResult{}}
+	for _, result := range results {
+		payload.Passed = payload.Passed && result.Passed
+		payload.Results = append(payload.Results, &model.StrategyTestResult{
+			TestCase: result.TestCase,
+			Passed:   result.Passed,
+			Output:   result.Output,
+			Error:    result.Error,
+			Diff:     result.Diff,
+			Stderr:   result.StdErr,
+		})
+	}
+	return payload
+}
@@ -11,6 +11,7 @@ import (
 	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
 	"github.com/net-auto/resourceManager/ent/resourcetype"
 	"github.com/net-auto/resourceManager/ent/schema"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
 	"github.com/net-auto/resourceManager/graph/graphql/resolver"
 	pools2 "github.com/net-auto/resourceManager/pools"
 	pools "github.com/net-auto/resourceManager/pools/allocating_strategies"
@@ -42,6 +43,17 @@ func setup(t *testing.T) testSetup {
 	return testSetup{ctx: ctx, client: client}
 }
 
+// mutationContext opens a transaction the way the GraphQL handler does for mutations
+func mutationContext(t *testing.T, s testSetup) (context.Context, *ent.Tx) {
+	tx, err := s.client.Tx(s.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ent.NewTxContext(s.ctx, tx)
+	ctx = ent.NewContext(ctx, tx.Client())
+	return context.WithValue(ctx, ent.TxCtxKey{}, tx), tx
+}
+
 func TestPool_Filtering_ByResources(t *testing.T) {
 	s := setup(t)
 	defer s.client.Close()
@@ -144,3 +156,43 @@ func TestPool_Filtering_ByResources(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, []int{}, ids3)
 }
+
+func TestClaimResourceWithAltIdRetryKeepsLease(t *testing.T) {
+	s := setup(t)
+	defer s.client.Close()
+	ctx := ent.NewContext(s.ctx, s.client)
+	if err := pools.LoadBuiltinTypes(s.ctx, s.client); err != nil {
+		t.Fatal(err)
+	}
+	vlanType := s.client.ResourceType.Query().Where(resourcetype.Name("vlan")).OnlyX(s.ctx)
+
+	mutation := resolver.New(resolver.Config{}).Mutation()
+	created, err := mutation.CreateSetPool(ctx, model.CreateSetPoolInput{
+		PoolName:       "vlans",
+		ResourceTypeID: vlanType.ID,
+		PoolValues:     []map[string]interface{}{{"vlan": 44}, {"vlan": 45}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idempotent := true
+	leaseSeconds := 3600
+	altId := map[string]interface{}{"order": "42"}
+	claimed, err := mutation.ClaimResourceWithAltID(ctx, created.Pool.ID, nil, map[string]interface{}{}, altId, &leaseSeconds, &idempotent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, claimed.LeaseExpiresAt)
+
+	// retry without a lease returns the same resource and keeps its lease
+	retried, err := mutation.ClaimResourceWithAltID(ctx, created.Pool.ID, nil, map[string]interface{}{}, altId, nil, &idempotent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, claimed.ID, retried.ID)
+	stored := s.client.Resource.GetX(s.ctx, claimed.ID)
+	if assert.NotNil(t, stored.LeaseExpiresAt) {
+		assert.True(t, stored.LeaseExpiresAt.Equal(*claimed.LeaseExpiresAt))
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/net-auto/resourceManager/ent/schema"
 	logger "github.com/net-auto/resourceManager/logging"
 	"github.com/net-auto/resourceManager/server"
+	"github.com/net-auto/resourceManager/server/lease"
 	"github.com/net-auto/resourceManager/server/metrics"
 	"github.com/net-auto/resourceManager/telemetry"
 	stdlog "log"
 	"net/url"
 	"os"
 	"syscall"
+	"time"
 
 	_ "github.com/net-auto/resourceManager/ent/runtime"
 	"github.com/net-auto/resourceManager/pkg/ctxgroup"
@@ -34,6 +36,7 @@ type cliFlags struct {
 	LogPath          string            `name:"logPath" env:"RM_LOG_PATH" default:"./rm.log" help:"Path to logfile." type:"path"`
 	LogLevel         string            `name:"loglevel" env:"RM_LOG_LEVEL" default:"info" help:"Logging level - fatal, error, warning, info, debug or trace." type:"string"`
 	LogWithColors    bool              `name:"logWithColors" default:"false" help:"Force colors in log." type:"bool"`
+	LeaseInterval    time.Duration     `name:"lease.reaper-interval" env:"RM_LEASE_REAPER_INTERVAL" default:"1m" help:"How often to free resources with expired lease, 0 disables it."`
 }
 
 func main() {
@@ -75,6 +78,7 @@ type application struct {
 	addr        string
 	metrics     *metrics.Metrics
 	metricsAddr metrics.Addr
+	reaper      *lease.Reaper
 }
 
 func (app *application) run(ctx context.Context) error {
@@ -88,6 +92,9 @@ func (app *application) run(ctx context.Context) error {
 	g.Go(func(ctx context.Context) error {
 		return app.metrics.Serve(ctx, app.metricsAddr)
 	})
+	g.Go(func(ctx context.Context) error {
+		return app.reaper.Serve(ctx)
+	})
 	g.Go(func(ctx context.Context) error {
 		defer cancel()
 		<-ctx.Done()
@@ -0,0 +1,142 @@
+package pools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/schema"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// immediateFreer is implemented by pools, it frees a resource bypassing the dealocation safety period
+type immediateFreer interface {
+	freeResourceImmediately(res *ent.Resource) error
+}
+
+// UnbenchResources frees benched resources before their dealocation safety period ends. Admin only.
+func UnbenchResources(ctx context.Context, client *ent.Client, resourceIds []int) error {
+	return adminFreeResources(ctx, client, "UnbenchResources", resourceIds, resource.StatusBench)
+}
+
+// UnretireResources returns retired resources back to their pools. Admin only.
+func UnretireResources(ctx context.Context, client *ent.Client, resourceIds []int) error {
+	return adminFreeResources(ctx, client, "UnretireResources", resourceIds, resource.StatusRetired)
+}
+
+// ForceFreeResources frees claimed or reserved resources immediately, regardless of the dealocation
+// safety period of their pools. Resources with nested pools are never freed. Admin only.
+func ForceFreeResources(ctx context.Context, client *ent.Client, resourceIds []int) error {
+	return adminFreeResources(ctx, client, "ForceFreeResources", resourceIds,
+		resource.StatusClaimed, resource.StatusReserved)
+}
+
+// adminFreeResources frees resources in one of the expected states, either all of them or none.
+// Every freed resource is recorded as a free event together with the acting identity.
+func adminFreeResources(ctx context.Context, client *ent.Client, operation string, resourceIds []int,
+	expectedStatuses ...resource.Status) error {
+	if err := schema.RBAC.EvalOperation(ctx, operation); err != nil {
+		log.Warn(ctx, "Unauthorized attempt to %s: %v", operation, err)
+		return err
+	}
+	if _, err := schema.GetIdentity(ctx); err != nil {
+		return err
+	}
+	if len(resourceIds) == 0 {
+		return errors.Errorf("Unable to %s, no resources specified", operation)
+	}
+
+	resources, err := client.Resource.Query().
+		Where(resource.IDIn(resourceIds...)).
+		WithPool().
+		WithProperties().
+		All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to load resources %v", resourceIds)
+		return errors.Wrapf(err, "Unable to %s", operation)
+	}
+
+	// Validate all resources before freeing any of them
+	found := make(map[int]*ent.Resource, len(resources))
+	for _, res := range resources {
+		found[res.ID] = res
+	}
+	var failures []string
+	for _, resourceId := range resourceIds {
+		res, ok := found[resourceId]
+		if !ok || res.Edges.Pool == nil {
+			failures = append(failures, fmt.Sprintf("resource #%d: not found", resourceId))
+			continue
+		}
+		if res.Edges.Pool.PoolType == resourcePool.PoolTypeSingleton {
+			failures = append(failures, fmt.Sprintf("resource #%d: singleton pools never free resources", resourceId))
+			continue
+		}
+		if res.Edges.Pool.LifecycleState == resourcePool.LifecycleStateFrozen {
+			failures = append(failures, fmt.Sprintf("resource #%d: pool #%d is frozen", resourceId, res.Edges.Pool.ID))
+			continue
+		}
+		if !hasStatus(res, expectedStatuses) {
+			failures = append(failures, fmt.Sprintf("resource #%d: unexpected status %s", resourceId, res.Status))
+			continue
+		}
+		if nested, err := res.QueryNestedPool().Exist(ctx); err != nil {
+			return errors.Wrapf(err, "Unable to check nested pools of resource #%d", resourceId)
+		} else if nested {
+			failures = append(failures, fmt.Sprintf("resource #%d: there is a nested pool attached to it", resourceId))
+		}
+	}
+	if len(failures) > 0 {
+		err := errors.Errorf("Unable to %s. %d resource(s) failed: %s",
+			operation, len(failures), strings.Join(failures, "; "))
+		log.Warn(ctx, "%s", err.Error())
+		return err
+	}
+
+	freedPools := make(map[int]Pool)
+	for _, resourceId := range resourceIds {
+		res := found[resourceId]
+		pool, ok := freedPools[res.Edges.Pool.ID]
+		if !ok {
+			if pool, err = existingPool(ctx, client, res.Edges.Pool); err != nil {
+				return errors.Wrapf(err, "Unable to %s", operation)
+			}
+			freedPools[res.Edges.Pool.ID] = pool
+		}
+		freer, ok := pool.(immediateFreer)
+		if !ok {
+			return errors.Errorf("Unable to %s, pool #%d does not support it", operation, res.Edges.Pool.ID)
+		}
+		before, err := ResourceState(ctx, res, res.Edges.Pool.ID)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to %s", operation)
+		}
+		if err := freer.freeResourceImmediately(res); err != nil {
+			log.Error(ctx, err, "Unable to free resource ID %d", res.ID)
+			return errors.Wrapf(err, "Unable to %s, unable to free resource #%d", operation, res.ID)
+		}
+		if err := RecordResourceEvent(ctx, client, resourceevent.EventFree, res.Edges.Pool.ID, res.ID, before); err != nil {
+			return errors.Wrapf(err, "Unable to %s", operation)
+		}
+	}
+	for _, pool := range freedPools {
+		if err := UpdatePoolCapacity(pool); err != nil {
+			return errors.Wrapf(err, "Unable to %s", operation)
+		}
+	}
+	return nil
+}
+
+func hasStatus(res *ent.Resource, statuses []resource.Status) bool {
+	for _, status := range statuses {
+		if res.Status == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestAdminUnbenchAndForceFree(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "pool", nil, 60)
+
+	benched, err := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.FreeResources(nil, []int{benched.ID}); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 0, 1, 1, 0)
+
+	if err := UnbenchResources(ctx, client, []int{benched.ID, claimed.ID}); err == nil {
+		t.Fatalf("Unbenching a claimed resource should fail")
+	}
+	assertDbResourceStates(ctx, client, t, 0, 1, 1, 0)
+
+	if err := UnbenchResources(ctx, client, []int{benched.ID}); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 1, 0, 0)
+
+	if err := ForceFreeResources(ctx, client, []int{claimed.ID}); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 2, 0, 0, 0)
+}
+
+func TestAdminUnretire(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+	}, "pool", nil, schema.ResourcePoolDealocationRetire)
+
+	retired, err := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.FreeResources(nil, []int{retired.ID}); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 0, 0, 0, 1)
+
+	if err := UnretireResources(getContextWithFailingRbac(), client, []int{retired.ID}); err == nil {
+		t.Fatalf("Unretiring without admin role should fail")
+	}
+	assertDbResourceStates(ctx, client, t, 0, 0, 0, 1)
+
+	ctx = getContext()
+	if err := UnretireResources(ctx, client, []int{retired.ID}); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 0, 0, 0)
+}
@@ -5,518 +5,108 @@ import (
 
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/allocationstrategy"
-	"github.com/net-auto/resourceManager/ent/propertytype"
+	"github.com/net-auto/resourceManager/ent/allocationstrategyrevision"
 	"github.com/net-auto/resourceManager/ent/resourcetype"
 	"github.com/net-auto/resourceManager/ent/schema"
+	p "github.com/net-auto/resourceManager/pools"
 	"github.com/pkg/errors"
 )
 
-func loadIpv4Prefix(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("ipv4_prefix")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		// TODO update if exists
-		// TODO prevent users from overriding these
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-	propPrefix, err := client.PropertyType.Create().
-		SetName("prefix").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-	propSubnet, err := client.PropertyType.Create().
-		SetName("subnet").
-		SetType(propertytype.TypeBool).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetStringVal("192.168.10.0").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-	expectedPoolPropPrefix, err := client.PropertyType.Create().
-		SetName("prefix").
-		SetIntVal(24).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropSubnet, err := client.PropertyType.Create().
-		SetName("subnet").
-		SetBoolVal(false).
-		SetType(propertytype.TypeBool).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("ipv4_prefix").
-		AddPropertyTypes(propAddr, propPrefix, propSubnet).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("ipv4_prefix").
-		SetLang(allocationstrategy.LangGo).
-		SetScript(IPV4_PREFIX).
-		AddPoolPropertyTypes(expectedPoolPropAddr, expectedPoolPropPrefix, expectedPoolPropSubnet).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// builtinScripts are js versions of builtin go strategies, stored with the strategies for reference
+var builtinScripts = map[string]string{
+	"ipv4_prefix":         IPV4_PREFIX,
+	"ipv4":                IPV4,
+	"vlan_range":          VLAN_RANGE,
+	"vlan":                VLAN,
+	"ipv6_prefix":         IPV6_PREFIX,
+	"ipv6":                IPV6,
+	"route_distinguisher": ROUTE_DISTINGUISHER,
+	"random_signed_int32": RANDOM_S_INT32,
+	"unique_id":           UNIQUE_ID,
 }
 
-func loadIpv6Prefix(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("ipv6_prefix")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		// TODO update if exists
-		// TODO prevent users from overriding these
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	propPrefix, err := client.PropertyType.Create().
-		SetName("prefix").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	propSubnet, err := client.PropertyType.Create().
-		SetName("subnet").
-		SetType(propertytype.TypeBool).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetStringVal("2001:db8::").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropPrefix, err := client.PropertyType.Create().
-		SetName("prefix").
-		SetIntVal(64).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropSubnet, err := client.PropertyType.Create().
-		SetName("subnet").
-		SetBoolVal(false).
-		SetType(propertytype.TypeBool).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("ipv6_prefix").
-		AddPropertyTypes(propAddr, propPrefix, propSubnet).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("ipv6_prefix").
-		SetLang(allocationstrategy.LangGo).
-		SetScript(IPV6_PREFIX).
-		AddPoolPropertyTypes(expectedPoolPropAddr, expectedPoolPropPrefix, expectedPoolPropSubnet).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
+// portedToGo are builtin strategies running as js in previous versions
+var portedToGo = map[string]bool{
+	"vlan_range":          true,
+	"route_distinguisher": true,
+	"random_signed_int32": true,
 }
 
-func loadIpv4(ctx context.Context, client *ent.Tx) error {
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("ipv4")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetStringVal("192.168.10.0").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
+func strategyScript(definition p.GoStrategyDefinition) string {
+	if definition.Script != "" {
+		return definition.Script
 	}
-
-	expectedPoolPropPrefix, err := client.PropertyType.Create().
-		SetName("prefix").
-		SetIntVal(24).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
+	if script, ok := builtinScripts[definition.Name]; ok {
+		return script
 	}
-
-	expectedPoolPropSubnet, err := client.PropertyType.Create().
-		SetName("subnet").
-		SetBoolVal(false).
-		SetType(propertytype.TypeBool).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("ipv4").
-		AddPropertyTypes(propAddr).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("ipv4").
-		SetLang(allocationstrategy.LangGo).
-		SetScript(IPV4).
-		AddPoolPropertyTypes(expectedPoolPropAddr, expectedPoolPropPrefix, expectedPoolPropSubnet).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return "// " + definition.Name + " is a compiled in go strategy"
 }
 
-func loadIpv6(ctx context.Context, client *ent.Tx) error {
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("ipv6")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropAddr, err := client.PropertyType.Create().
-		SetName("address").
-		SetStringVal("2001:db8::").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropPrefix, err := client.PropertyType.Create().
-		SetName("prefix").
-		SetIntVal(64).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropSubnet, err := client.PropertyType.Create().
-		SetName("subnet").
-		SetBoolVal(false).
-		SetType(propertytype.TypeBool).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("ipv6").
-		AddPropertyTypes(propAddr).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("ipv6").
-		SetLang(allocationstrategy.LangGo).
-		SetScript(IPV6).
-		AddPoolPropertyTypes(expectedPoolPropAddr, expectedPoolPropPrefix, expectedPoolPropSubnet).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func createPropertyType(ctx context.Context, client *ent.Tx, property p.GoStrategyProperty) (*ent.PropertyType, error) {
+	create := client.PropertyType.Create().
+		SetName(property.Name).
+		SetType(property.Type)
+	switch value := property.Default.(type) {
+	case nil:
+	case int:
+		create.SetIntVal(value)
+	case float64:
+		create.SetFloatVal(value)
+	case string:
+		create.SetStringVal(value)
+	case bool:
+		create.SetBoolVal(value)
+	default:
+		return nil, errors.Errorf("Unsupported default value %v of property %s", value, property.Name)
+	}
+	return create.Save(ctx)
 }
 
-func loadVlanRange(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("vlan_range")).Exist(ctx)
+func loadGoStrategy(ctx context.Context, client *ent.Tx, definition p.GoStrategyDefinition) error {
+	exists, err := client.ResourceType.Query().Where(resourcetype.Name(definition.ResourceType)).Exist(ctx)
 	if err != nil {
 		return err
 	}
 	if exists {
 		// TODO update if exists
 		// TODO prevent users from overriding these
+		if portedToGo[definition.Name] {
+			return migrateToGoStrategy(ctx, client, definition.Name, strategyScript(definition))
+		}
 		return nil
 	}
 
-	propFrom, err := client.PropertyType.Create().
-		SetName("from").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	propTo, err := client.PropertyType.Create().
-		SetName("to").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropFrom, err := client.PropertyType.Create().
-		SetName("from").
-		SetIntVal(0).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropTo, err := client.PropertyType.Create().
-		SetName("to").
-		SetIntVal(4095).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("vlan_range").
-		AddPropertyTypes(propFrom, propTo).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("vlan_range").
-		SetLang(allocationstrategy.LangJs).
-		SetScript(VLAN_RANGE).
-		AddPoolPropertyTypes(expectedPoolPropFrom, expectedPoolPropTo).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func loadVlan(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("vlan")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("vlan").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropFrom, err := client.PropertyType.Create().
-		SetName("from").
-		SetIntVal(0).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
+	var resourcePropertyTypes []*ent.PropertyType
+	for _, property := range definition.ResourcePropertyTypes {
+		propertyType, err := createPropertyType(ctx, client, property)
+		if err != nil {
+			return err
+		}
+		resourcePropertyTypes = append(resourcePropertyTypes, propertyType)
 	}
 
-	expectedPoolPropTo, err := client.PropertyType.Create().
-		SetName("to").
-		SetIntVal(4095).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
+	var poolPropertyTypes []*ent.PropertyType
+	for _, property := range definition.PoolPropertyTypes {
+		propertyType, err := createPropertyType(ctx, client, property)
+		if err != nil {
+			return err
+		}
+		poolPropertyTypes = append(poolPropertyTypes, propertyType)
 	}
 
 	_, err = client.ResourceType.Create().
-		SetName("vlan").
-		AddPropertyTypes(propAddr).
+		SetName(definition.ResourceType).
+		AddPropertyTypes(resourcePropertyTypes...).
 		Save(ctx)
 	if err != nil {
 		return err
 	}
 
 	_, err = client.AllocationStrategy.Create().
-		SetName("vlan").
+		SetName(definition.Name).
 		SetLang(allocationstrategy.LangGo).
-		SetScript(VLAN).
-		AddPoolPropertyTypes(expectedPoolPropFrom, expectedPoolPropTo).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func loadRd(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("route_distinguisher")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("rd").
-		SetType(propertytype.TypeString).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("route_distinguisher").
-		AddPropertyTypes(propAddr).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("route_distinguisher").
-		SetLang(allocationstrategy.LangJs).
-		SetScript(ROUTE_DISTINGUISHER).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func loadRandomSignedInt32(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("random_signed_int32")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-
-	propAddr, err := client.PropertyType.Create().
-		SetName("int").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropFrom, err := client.PropertyType.Create().
-		SetName("from").
-		SetIntVal(-2147483648).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropTo, err := client.PropertyType.Create().
-		SetName("to").
-		SetIntVal(2147483648).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.ResourceType.Create().
-		SetName("random_signed_int32").
-		AddPropertyTypes(propAddr).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("random_signed_int32").
-		SetLang(allocationstrategy.LangJs).
-		SetScript(RANDOM_S_INT32).
-		AddPoolPropertyTypes(expectedPoolPropFrom, expectedPoolPropTo).
+		SetScript(strategyScript(definition)).
+		AddPoolPropertyTypes(poolPropertyTypes...).
 		Save(ctx)
 	if err != nil {
 		return err
@@ -525,117 +115,49 @@ func loadRandomSignedInt32(ctx context.Context, client *ent.Tx) error {
 	return nil
 }
 
-func loadUniqueIdStrategy(ctx context.Context, client *ent.Tx) error {
-
-	exists, err := client.ResourceType.Query().Where(resourcetype.Name("unique_id")).Exist(ctx)
-	if err != nil {
-		return err
-	}
-	if exists {
+// migrateToGoStrategy switches a builtin strategy loaded before its go implementation existed from js to go.
+// A new revision is stored, so that pools pinned to an older revision keep running the js script until upgraded.
+func migrateToGoStrategy(ctx context.Context, client *ent.Tx, name string, script string) error {
+	strat, err := client.AllocationStrategy.Query().
+		Where(allocationstrategy.Name(name), allocationstrategy.LangEQ(allocationstrategy.LangJs)).
+		Only(ctx)
+	if ent.IsNotFound(err) {
 		return nil
-	}
-
-	propCounter, err := client.PropertyType.Create().
-		SetName("counter").
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
+	} else if err != nil {
 		return err
 	}
 
-	propText, err := client.PropertyType.Create().
-		SetName("text").
-		SetType(propertytype.TypeString).
-		Save(ctx)
+	// strategies stored before revisions were introduced get their js script stored as the first revision
+	latest, err := p.LatestStrategyRevision(ctx, client.Client(), strat)
 	if err != nil {
 		return err
 	}
-
-	expectedPoolPropFrom, err := client.PropertyType.Create().
-		SetName("from").
-		SetIntVal(1).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	expectedPoolPropTo, err := client.PropertyType.Create().
-		SetName("to").
-		SetIntVal(4094).
-		SetType(propertytype.TypeInt).
-		Save(ctx)
-	if err != nil {
+	if err := client.AllocationStrategyRevision.Create().
+		SetRevision(latest.Revision + 1).
+		SetLang(allocationstrategyrevision.LangGo).
+		SetScript(script).
+		SetAllocationStrategy(strat).
+		Exec(ctx); err != nil {
 		return err
 	}
 
-	_, err = client.ResourceType.Create().
-		SetName("unique_id").
-		AddPropertyTypes(propCounter, propText).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = client.AllocationStrategy.Create().
-		SetName("unique_id").
+	return client.AllocationStrategy.UpdateOne(strat).
 		SetLang(allocationstrategy.LangGo).
-		SetScript(UNIQUE_ID).
-		AddPoolPropertyTypes(expectedPoolPropFrom, expectedPoolPropTo).
-		Save(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
+		SetScript(script).
+		Exec(ctx)
 }
 
 func loadInner(ctx context.Context, client *ent.Tx) error {
-	err := loadIpv4Prefix(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load ipv4_prefix resource type")
-	}
-	err = loadIpv4(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load ipv4 resource type")
-	}
-
-	err = loadVlanRange(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load vlan_range resource type")
-	}
-	err = loadVlan(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load vlan resource type")
-	}
-
-	err = loadIpv6Prefix(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load ipv6_prefix resource type")
-	}
-	err = loadIpv6(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load ipv6 resource type")
-	}
-
-	err = loadRd(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load route distinguisher resource type")
-	}
-	err = loadRandomSignedInt32(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load random signed int32 resource type")
-	}
-
-	err = loadUniqueIdStrategy(ctx, client)
-	if err != nil {
-		return errors.Wrapf(err, "Unable to load unique id resource type")
+	for _, definition := range p.GoStrategyDefinitions() {
+		if err := loadGoStrategy(ctx, client, definition); err != nil {
+			return errors.Wrapf(err, "Unable to load %s resource type", definition.ResourceType)
+		}
 	}
-
 	return nil
 }
 
-// LoadBuiltinTypes loads IP, VLAN etc. resource types and allocation strategies into DB
+// LoadBuiltinTypes loads resource types and allocation strategies of all registered go strategies
+// (IP, VLAN etc. and the ones registered by pools.RegisterGoStrategy) into DB
 //
 //	does not overwrite existing resources and strategies
 func LoadBuiltinTypes(ctx context.Context, client *ent.Client) error {
@@ -661,4 +183,4 @@ func LoadBuiltinTypes(ctx context.Context, client *ent.Client) error {
 		return errors.Wrapf(err, "committing transaction: %v", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
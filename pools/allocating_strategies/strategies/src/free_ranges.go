@@ -0,0 +1,161 @@
+package src
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// FreeRange is a maximal contiguous range of free values in a pool, both bounds are inclusive
+type FreeRange struct {
+	From string
+	To   string
+	Size *big.Int
+}
+
+// FreeBlock is a maximal aligned CIDR block of free addresses in a prefix pool
+type FreeBlock struct {
+	Address string
+	Prefix  int
+	Size    *big.Int
+}
+
+// FreeRangesBucket counts free CIDR blocks of the same prefix length (prefix pools)
+// or free ranges of the same size (other pools, Prefix is nil)
+type FreeRangesBucket struct {
+	Prefix *int
+	Size   *big.Int
+	Count  int
+}
+
+// FreeRanges describes free space left in a pool. The histogram is ordered from the largest
+// to the smallest blocks (ranges), a claim of desiredSize succeeds only if some block (range) is large enough.
+type FreeRanges struct {
+	Ranges    []FreeRange
+	Blocks    []FreeBlock
+	Histogram []FreeRangesBucket
+}
+
+type valueInterval struct {
+	from *big.Int
+	to   *big.Int
+}
+
+// freeIntervals returns maximal parts of root not covered by any of the allocated intervals, in ascending order
+func freeIntervals(root valueInterval, allocated []valueInterval) []valueInterval {
+	sort.Slice(allocated, func(i, j int) bool {
+		return allocated[i].from.Cmp(allocated[j].from) < 0
+	})
+
+	var free []valueInterval
+	next := new(big.Int).Set(root.from)
+	for _, interval := range allocated {
+		if interval.from.Cmp(root.to) > 0 {
+			break
+		}
+		if interval.from.Cmp(next) > 0 {
+			free = append(free, valueInterval{new(big.Int).Set(next), new(big.Int).Sub(interval.from, big.NewInt(1))})
+		}
+		if interval.to.Cmp(next) >= 0 {
+			next.Add(interval.to, big.NewInt(1))
+		}
+	}
+	if next.Cmp(root.to) <= 0 {
+		free = append(free, valueInterval{next, new(big.Int).Set(root.to)})
+	}
+	return free
+}
+
+// intervalSize is the number of values in an inclusive interval
+func intervalSize(interval valueInterval) *big.Int {
+	size := new(big.Int).Sub(interval.to, interval.from)
+	return size.Add(size, big.NewInt(1))
+}
+
+// cidrBlocks splits an interval of an address space with bits long addresses into maximal aligned blocks
+func cidrBlocks(interval valueInterval, bits int) []valueInterval {
+	var blocks []valueInterval
+	start := new(big.Int).Set(interval.from)
+	for start.Cmp(interval.to) <= 0 {
+		hostBits := 0
+		for hostBits < bits && start.Bit(hostBits) == 0 {
+			end := new(big.Int).Lsh(big.NewInt(1), uint(hostBits+1))
+			end.Add(end, start).Sub(end, big.NewInt(1))
+			if end.Cmp(interval.to) > 0 {
+				break
+			}
+			hostBits++
+		}
+		size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+		end := new(big.Int).Add(start, size)
+		blocks = append(blocks, valueInterval{start, end.Sub(end, big.NewInt(1))})
+		start = new(big.Int).Add(start, size)
+	}
+	return blocks
+}
+
+// newFreeRanges lists free ranges of a pool, addresses of prefix pools (bits > 0) are also split into CIDR blocks
+func newFreeRanges(root valueInterval, allocated []valueInterval, bits int, format func(*big.Int) string) *FreeRanges {
+	result := &FreeRanges{Ranges: []FreeRange{}, Blocks: []FreeBlock{}, Histogram: []FreeRangesBucket{}}
+	buckets := map[string]*FreeRangesBucket{}
+
+	for _, free := range freeIntervals(root, allocated) {
+		size := intervalSize(free)
+		result.Ranges = append(result.Ranges, FreeRange{From: format(free.from), To: format(free.to), Size: size})
+		if bits == 0 {
+			addToBucket(buckets, size.String(), FreeRangesBucket{Size: size})
+			continue
+		}
+
+		for _, block := range cidrBlocks(free, bits) {
+			blockSize := intervalSize(block)
+			prefix := bits - (blockSize.BitLen() - 1)
+			result.Blocks = append(result.Blocks, FreeBlock{Address: format(block.from), Prefix: prefix, Size: blockSize})
+			addToBucket(buckets, strconv.Itoa(prefix), FreeRangesBucket{Prefix: &prefix, Size: blockSize})
+		}
+	}
+
+	for _, bucket := range buckets {
+		result.Histogram = append(result.Histogram, *bucket)
+	}
+	sort.Slice(result.Histogram, func(i, j int) bool {
+		return result.Histogram[i].Size.Cmp(result.Histogram[j].Size) > 0
+	})
+	return result
+}
+
+func addToBucket(buckets map[string]*FreeRangesBucket, key string, bucket FreeRangesBucket) {
+	if existing, ok := buckets[key]; ok {
+		existing.Count++
+		return
+	}
+	bucket.Count = 1
+	buckets[key] = &bucket
+}
+
+// numberInterval reads inclusive bounds of a range stored as integer properties
+func numberInterval(properties map[string]interface{}, fromKey string, toKey string) (valueInterval, error) {
+	from, ok := properties[fromKey]
+	if !ok {
+		return valueInterval{}, errors.New("Missing " + fromKey + " in range")
+	}
+	to, ok := properties[toKey]
+	if !ok {
+		return valueInterval{}, errors.New("Missing " + toKey + " in range")
+	}
+	from, err := NumberToInt(from)
+	if err != nil {
+		return valueInterval{}, err
+	}
+	to, err = NumberToInt(to)
+	if err != nil {
+		return valueInterval{}, err
+	}
+	return valueInterval{big.NewInt(int64(from.(int))), big.NewInt(int64(to.(int)))}, nil
+}
+
+func formatNumber(value *big.Int) string {
+	return value.String()
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 	"math"
+	"math/big"
 	"net"
 	"reflect"
 	"sort"
@@ -116,7 +117,7 @@ func (ipv4prefix *Ipv4Prefix) Capacity() (map[string]interface{}, error) {
 	}
 
 	var result = make(map[string]interface{})
-	result["freeCapacity"] = strconv.FormatFloat(freeCapacity, 'g', 30, 64)
+	result["freeCapacity"] = strconv.FormatInt(int64(freeCapacity), 10)
 	result["utilizedCapacity"] = strconv.Itoa(allocatedCapacity)
 
 	return result, nil
@@ -409,3 +410,75 @@ func (ipv4prefix *Ipv4Prefix) Invoke() (map[string]interface{}, error) {
 		"Insufficient capacity to allocate a new prefix of size: " + desiredSizeStr + "\n" +
 		"Currently allocated addresses: " + addressesToStr(ipv4prefix.currentResources))
 }
+
+// Contains checks whether an existing prefix resource lies entirely within the root prefix of the pool
+func (ipv4prefix *Ipv4Prefix) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	rootAddressStr, ok := ipv4prefix.resourcePoolProperties["address"]
+	if !ok {
+		return false, errors.New("Unable to extract address resource")
+	}
+	rootMask, ok := ipv4prefix.resourcePoolProperties["prefix"]
+	if !ok {
+		return false, errors.New("Unable to extract prefix resources")
+	}
+	rootMask, err := NumberToInt(rootMask)
+	if err != nil {
+		return false, err
+	}
+	rootAddressNum, err := InetAton(rootAddressStr.(string))
+	if err != nil {
+		return false, err
+	}
+	address, prefix, err := getAddressAndPrefixFromCurrentResource(
+		map[string]interface{}{"Properties": resourceProperties})
+	if err != nil {
+		return false, err
+	}
+	addressNum, err := InetAton(address)
+	if err != nil {
+		return false, err
+	}
+
+	return addressNum >= rootAddressNum &&
+		addressNum+subnetAddresses(prefix) <= rootAddressNum+subnetAddresses(rootMask.(int)), nil
+}
+
+// FreeRanges lists free address ranges of the root prefix, split into maximal CIDR blocks
+func (ipv4prefix *Ipv4Prefix) FreeRanges() (*FreeRanges, error) {
+	rootAddressStr, ok := ipv4prefix.resourcePoolProperties["address"]
+	if !ok {
+		return nil, errors.New("Unable to extract address resource")
+	}
+	rootMask, ok := ipv4prefix.resourcePoolProperties["prefix"]
+	if !ok {
+		return nil, errors.New("Unable to extract prefix resources")
+	}
+	rootMask, err := NumberToInt(rootMask)
+	if err != nil {
+		return nil, err
+	}
+	rootAddressNum, err := InetAton(rootAddressStr.(string))
+	if err != nil {
+		return nil, err
+	}
+	root := valueInterval{big.NewInt(int64(rootAddressNum)),
+		big.NewInt(int64(subnetLastAddress(rootAddressNum, rootMask.(int))))}
+
+	var allocated []valueInterval
+	for _, resource := range ipv4prefix.currentResources {
+		address, prefix, err := getAddressAndPrefixFromCurrentResource(resource)
+		if err != nil {
+			return nil, err
+		}
+		addressNum, err := InetAton(address)
+		if err != nil {
+			return nil, err
+		}
+		allocated = append(allocated, valueInterval{big.NewInt(int64(addressNum)),
+			big.NewInt(int64(subnetLastAddress(addressNum, prefix)))})
+	}
+
+	return newFreeRanges(root, allocated, 32, func(address *big.Int) string {
+		return inetNtoa(int(address.Int64()))
+	}), nil
+}
@@ -45,7 +45,7 @@ func (ipv4 *Ipv4) Capacity() (map[string]interface{}, error) {
 		subnetItself = 0
 	}
 	freeCapacity := ipv4.FreeCapacity(rootAddressStr.(string), rootMask.(int), float64(len(ipv4.currentResources)), subnetItself)
-	result["freeCapacity"] = strconv.FormatFloat(freeCapacity, 'g', 30, 64)
+	result["freeCapacity"] = strconv.FormatInt(int64(freeCapacity), 10)
 	result["utilizedCapacity"] = strconv.Itoa(len(ipv4.currentResources))
 	return result, nil
 }
@@ -129,3 +129,43 @@ func (ipv4 *Ipv4) Invoke() (map[string]interface{}, error) {
 		"Insufficient capacity to allocate a new address.\n" +
 		"Currently allocated addresses: " + addressesToStr(ipv4.currentResources))
 }
+
+// Contains checks whether an existing address resource lies within the root prefix of the pool
+func (ipv4 *Ipv4) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	rootAddressStr, ok := ipv4.resourcePoolProperties["address"]
+	if !ok {
+		return false, errors.New("Unable to extract address resource")
+	}
+	rootMask, ok := ipv4.resourcePoolProperties["prefix"]
+	if !ok {
+		return false, errors.New("Unable to extract prefix resources")
+	}
+	isSubnet, ok := ipv4.resourcePoolProperties["subnet"]
+	if !ok {
+		return false, errors.New("Unable to extract subnet property")
+	}
+	address, ok := resourceProperties["address"]
+	if !ok {
+		return false, errors.New("Unable to extract address from resource properties")
+	}
+	rootMask, err := NumberToInt(rootMask)
+	if err != nil {
+		return false, err
+	}
+	rootAddressNum, err := InetAton(rootAddressStr.(string))
+	if err != nil {
+		return false, err
+	}
+	addressNum, err := InetAton(address.(string))
+	if err != nil {
+		return false, err
+	}
+
+	firstPossibleAddr := rootAddressNum
+	lastPossibleAddr := rootAddressNum + subnetAddresses(rootMask.(int))
+	if isSubnet.(bool) == true {
+		firstPossibleAddr++
+		lastPossibleAddr--
+	}
+	return addressNum >= firstPossibleAddr && addressNum < lastPossibleAddr, nil
+}
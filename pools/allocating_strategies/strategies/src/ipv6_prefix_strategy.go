@@ -352,3 +352,75 @@ func getIPv6AddressAndPrefixFromCurrentResource(currentResource map[string]inter
 	}
 	return address.(string), prefix.(int), nil
 }
+
+// Contains checks whether an existing prefix resource lies entirely within the root prefix of the pool
+func (ipv6Prefix *Ipv6Prefix) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	rootAddressStr, ok := ipv6Prefix.resourcePoolProperties["address"]
+	if !ok {
+		return false, errors.New("Unable to extract address resource")
+	}
+	rootMask, ok := ipv6Prefix.resourcePoolProperties["prefix"]
+	if !ok {
+		return false, errors.New("Unable to extract prefix resources")
+	}
+	rootMask, err := NumberToInt(rootMask)
+	if err != nil {
+		return false, err
+	}
+	rootAddressNum, err := Ipv6InetAton(rootAddressStr.(string))
+	if err != nil {
+		return false, err
+	}
+	address, prefix, err := getIPv6AddressAndPrefixFromCurrentResource(
+		map[string]interface{}{"Properties": resourceProperties})
+	if err != nil {
+		return false, err
+	}
+	addressNum, err := Ipv6InetAton(address)
+	if err != nil {
+		return false, err
+	}
+
+	rootLastAddr := new(big.Int).Add(rootAddressNum, ipv6SubnetAddresses(rootMask.(int)))
+	lastAddr := new(big.Int).Add(addressNum, ipv6SubnetAddresses(prefix))
+	return addressNum.Cmp(rootAddressNum) >= 0 && lastAddr.Cmp(rootLastAddr) <= 0, nil
+}
+
+// FreeRanges lists free address ranges of the root prefix, split into maximal CIDR blocks
+func (ipv6Prefix *Ipv6Prefix) FreeRanges() (*FreeRanges, error) {
+	rootAddressStr, ok := ipv6Prefix.resourcePoolProperties["address"]
+	if !ok {
+		return nil, errors.New("Unable to extract address resource")
+	}
+	rootMask, ok := ipv6Prefix.resourcePoolProperties["prefix"]
+	if !ok {
+		return nil, errors.New("Unable to extract prefix resources")
+	}
+	rootMask, err := NumberToInt(rootMask)
+	if err != nil {
+		return nil, err
+	}
+	rootAddressNum, err := Ipv6InetAton(rootAddressStr.(string))
+	if err != nil {
+		return nil, err
+	}
+	root := valueInterval{rootAddressNum, ipv6SubnetLastAddress(rootAddressNum, rootMask.(int))}
+
+	var allocated []valueInterval
+	for _, resource := range ipv6Prefix.currentResources {
+		address, prefix, err := getIPv6AddressAndPrefixFromCurrentResource(resource)
+		if err != nil {
+			return nil, err
+		}
+		addressNum, err := Ipv6InetAton(address)
+		if err != nil {
+			return nil, err
+		}
+		allocated = append(allocated, valueInterval{addressNum, ipv6SubnetLastAddress(addressNum, prefix)})
+	}
+
+	return newFreeRanges(root, allocated, 128, func(address *big.Int) string {
+		// Ipv6InetNtoa modifies its argument
+		return Ipv6InetNtoa(new(big.Int).Set(address))
+	}), nil
+}
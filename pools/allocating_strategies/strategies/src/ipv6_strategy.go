@@ -169,3 +169,43 @@ func NumberToBigInt(number interface{}) (*big.Int, error) {
 	}
 	return big.NewInt(1), errors.New("Unable to convert number: " + number.(string) + " to a known type")
 }
+
+// Contains checks whether an existing address resource lies within the root prefix of the pool
+func (ipv6 *Ipv6) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	rootAddressStr, ok := ipv6.resourcePoolProperties["address"]
+	if !ok {
+		return false, errors.New("Unable to extract address resource")
+	}
+	rootMask, ok := ipv6.resourcePoolProperties["prefix"]
+	if !ok {
+		return false, errors.New("Unable to extract prefix resources")
+	}
+	isSubnet, ok := ipv6.resourcePoolProperties["subnet"]
+	if !ok {
+		return false, errors.New("Unable to extract subnet property")
+	}
+	address, ok := resourceProperties["address"]
+	if !ok {
+		return false, errors.New("Unable to extract address from resource properties")
+	}
+	rootMask, err := NumberToInt(rootMask)
+	if err != nil {
+		return false, err
+	}
+	rootAddressNum, err := Ipv6InetAton(rootAddressStr.(string))
+	if err != nil {
+		return false, err
+	}
+	addressNum, err := Ipv6InetAton(address.(string))
+	if err != nil {
+		return false, err
+	}
+
+	firstPossibleAddr := new(big.Int).Set(rootAddressNum)
+	lastPossibleAddr := new(big.Int).Add(rootAddressNum, ipv6SubnetAddresses(rootMask.(int)))
+	if isSubnet.(bool) == true {
+		firstPossibleAddr.Add(firstPossibleAddr, big.NewInt(1))
+		lastPossibleAddr.Sub(lastPossibleAddr, big.NewInt(1))
+	}
+	return addressNum.Cmp(firstPossibleAddr) >= 0 && addressNum.Cmp(lastPossibleAddr) < 0, nil
+}
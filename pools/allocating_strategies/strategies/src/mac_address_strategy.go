@@ -0,0 +1,254 @@
+package src
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const macAddressBytes = 6
+
+// MacAddress allocates MAC addresses starting with the prefix (e.g. an OUI) of the pool, suffixes of the allocated
+// addresses are taken from the from - to range of the pool. The pool can enforce locally administered
+// and unicast addresses by its locally_administered and unicast properties.
+type MacAddress struct {
+	currentResources       []map[string]interface{}
+	resourcePoolProperties map[string]interface{}
+	userInput              map[string]interface{}
+}
+
+func NewMacAddress(currentResources []map[string]interface{},
+	resourcePoolProperties map[string]interface{},
+	userInput map[string]interface{}) MacAddress {
+	return MacAddress{currentResources, resourcePoolProperties, userInput}
+}
+
+// macPool is the parsed range of addresses of a pool
+type macPool struct {
+	prefix     []byte
+	suffixBits uint
+	from       uint64
+	to         uint64
+}
+
+func (pool macPool) address(suffix uint64) uint64 {
+	var address uint64
+	for _, b := range pool.prefix {
+		address = address<<8 | uint64(b)
+	}
+	return address<<pool.suffixBits | suffix
+}
+
+func (pool macPool) suffix(address uint64) (uint64, bool) {
+	if address>>pool.suffixBits != pool.address(0)>>pool.suffixBits {
+		return 0, false
+	}
+	suffix := address & (1<<pool.suffixBits - 1)
+	return suffix, suffix >= pool.from && suffix <= pool.to
+}
+
+func (pool macPool) rangeToStr() string {
+	return "[" + formatMacAddress(pool.address(pool.from)) + "-" + formatMacAddress(pool.address(pool.to)) + "]"
+}
+
+func formatMacAddress(address uint64) string {
+	octets := make([]string, macAddressBytes)
+	for i := macAddressBytes - 1; i >= 0; i-- {
+		octets[i] = fmt.Sprintf("%02x", address&0xff)
+		address >>= 8
+	}
+	return strings.Join(octets, ":")
+}
+
+func parseMacAddress(address interface{}) (uint64, error) {
+	text, ok := address.(string)
+	if !ok {
+		return 0, errors.Errorf("MAC address must be a string. Received: %v", address)
+	}
+	hardwareAddr, err := net.ParseMAC(text)
+	if err != nil || len(hardwareAddr) != macAddressBytes {
+		return 0, errors.Errorf("Invalid MAC address: %s", text)
+	}
+	var value uint64
+	for _, b := range hardwareAddr {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
+func parseMacPrefix(prefix interface{}) ([]byte, error) {
+	text, ok := prefix.(string)
+	if !ok {
+		return nil, errors.Errorf("MAC address prefix must be a string. Received: %v", prefix)
+	}
+	octets := strings.FieldsFunc(text, func(r rune) bool { return r == ':' || r == '-' })
+	if len(octets) < 1 || len(octets) >= macAddressBytes {
+		return nil, errors.Errorf("Invalid MAC address prefix: %s. Use 1 to 5 octets e.g. 02:00:5e", text)
+	}
+	var parsed []byte
+	for _, octet := range octets {
+		value, err := strconv.ParseUint(octet, 16, 8)
+		if err != nil || len(octet) != 2 {
+			return nil, errors.Errorf("Invalid MAC address prefix: %s. Use 1 to 5 octets e.g. 02:00:5e", text)
+		}
+		parsed = append(parsed, byte(value))
+	}
+	return parsed, nil
+}
+
+func (mac *MacAddress) pool() (macPool, error) {
+	prefix, ok := mac.resourcePoolProperties["prefix"]
+	if !ok {
+		return macPool{}, errors.New("Missing prefix in pool properties")
+	}
+	parsedPrefix, err := parseMacPrefix(prefix)
+	if err != nil {
+		return macPool{}, err
+	}
+	pool := macPool{prefix: parsedPrefix, suffixBits: uint(8 * (macAddressBytes - len(parsedPrefix)))}
+
+	if enforced, _ := mac.resourcePoolProperties["locally_administered"].(bool); enforced && parsedPrefix[0]&0x02 == 0 {
+		return macPool{}, errors.Errorf("MAC address prefix %v is not locally administered", prefix)
+	}
+	if enforced, _ := mac.resourcePoolProperties["unicast"].(bool); enforced && parsedPrefix[0]&0x01 != 0 {
+		return macPool{}, errors.Errorf("MAC address prefix %v is not unicast", prefix)
+	}
+
+	maxSuffix := uint64(1)<<pool.suffixBits - 1
+	pool.from, pool.to = 0, maxSuffix
+	if from, ok := mac.resourcePoolProperties["from"]; ok {
+		number, err := NumberToInt(from)
+		if err != nil {
+			return macPool{}, err
+		}
+		pool.from = uint64(number.(int))
+	}
+	if to, ok := mac.resourcePoolProperties["to"]; ok {
+		number, err := NumberToInt(to)
+		if err != nil {
+			return macPool{}, err
+		}
+		pool.to = uint64(number.(int))
+	}
+	if pool.from > pool.to || pool.to > maxSuffix {
+		return macPool{}, errors.Errorf("Invalid range of MAC address suffixes: %d - %d, use values 0 - %d",
+			pool.from, pool.to, maxSuffix)
+	}
+	return pool, nil
+}
+
+func (mac *MacAddress) allocatedSuffixes(pool macPool) (map[uint64]bool, error) {
+	allocated := make(map[uint64]bool, len(mac.currentResources))
+	for _, resource := range mac.currentResources {
+		properties, ok := resource["Properties"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Unable to extract properties from resource")
+		}
+		address, err := parseMacAddress(properties["address"])
+		if err != nil {
+			return nil, err
+		}
+		if suffix, ok := pool.suffix(address); ok {
+			allocated[suffix] = true
+		}
+	}
+	return allocated, nil
+}
+
+func (mac *MacAddress) Invoke() (map[string]interface{}, error) {
+	if mac.resourcePoolProperties == nil {
+		return nil, errors.New("Unable to extract parent MAC address range from pool properties")
+	}
+	pool, err := mac.pool()
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := mac.allocatedSuffixes(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := mac.userInput["desiredValue"]; ok {
+		address, err := parseMacAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		suffix, ok := pool.suffix(address)
+		if !ok {
+			return nil, errors.Errorf("MAC address %v is out of range: %s", value, pool.rangeToStr())
+		}
+		if allocated[suffix] {
+			return nil, errors.Errorf("MAC address %v was already claimed.", value)
+		}
+		return map[string]interface{}{"address": formatMacAddress(address)}, nil
+	}
+
+	for suffix := pool.from; ; suffix++ {
+		if !allocated[suffix] {
+			return map[string]interface{}{"address": formatMacAddress(pool.address(suffix))}, nil
+		}
+		if suffix == pool.to {
+			break
+		}
+	}
+	return nil, errors.New("Unable to allocate MAC address from: " + pool.rangeToStr() +
+		". Insufficient capacity to allocate a new MAC address")
+}
+
+func (mac *MacAddress) Capacity() (map[string]interface{}, error) {
+	pool, err := mac.pool()
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := mac.allocatedSuffixes(pool)
+	if err != nil {
+		return nil, err
+	}
+	freeCapacity := new(big.Int).SetUint64(pool.to - pool.from)
+	freeCapacity.Add(freeCapacity, big.NewInt(1-int64(len(allocated))))
+
+	var result = make(map[string]interface{})
+	result["freeCapacity"] = freeCapacity.String()
+	result["utilizedCapacity"] = strconv.Itoa(len(allocated))
+	return result, nil
+}
+
+// Contains checks whether an existing MAC address lies within the range of the pool
+func (mac *MacAddress) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	pool, err := mac.pool()
+	if err != nil {
+		return false, err
+	}
+	address, err := parseMacAddress(resourceProperties["address"])
+	if err != nil {
+		return false, err
+	}
+	_, contains := pool.suffix(address)
+	return contains, nil
+}
+
+// FreeRanges lists ranges of free MAC addresses within the range of the pool
+func (mac *MacAddress) FreeRanges() (*FreeRanges, error) {
+	pool, err := mac.pool()
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := mac.allocatedSuffixes(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	root := valueInterval{new(big.Int).SetUint64(pool.address(pool.from)), new(big.Int).SetUint64(pool.address(pool.to))}
+	var allocatedIntervals []valueInterval
+	for suffix := range allocated {
+		address := new(big.Int).SetUint64(pool.address(suffix))
+		allocatedIntervals = append(allocatedIntervals, valueInterval{address, address})
+	}
+	return newFreeRanges(root, allocatedIntervals, 0, func(value *big.Int) string {
+		return formatMacAddress(value.Uint64())
+	}), nil
+}
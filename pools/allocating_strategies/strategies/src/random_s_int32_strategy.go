@@ -0,0 +1,104 @@
+package src
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// RandomSignedInt32 allocates random ints from the parent range of the pool, previously freed ints are reused
+type RandomSignedInt32 struct {
+	currentResources       []map[string]interface{}
+	resourcePoolProperties map[string]interface{}
+	userInput              map[string]interface{}
+}
+
+func NewRandomSignedInt32(currentResources []map[string]interface{},
+	resourcePoolProperties map[string]interface{},
+	userInput map[string]interface{}) RandomSignedInt32 {
+	return RandomSignedInt32{currentResources, resourcePoolProperties, userInput}
+}
+
+func (randomInt *RandomSignedInt32) allocatedInts() (map[int64]bool, error) {
+	allocated := make(map[int64]bool, len(randomInt.currentResources))
+	for _, resource := range randomInt.currentResources {
+		properties, ok := resource["Properties"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Unable to extract properties from resource")
+		}
+		value, ok := properties["int"]
+		if !ok {
+			return nil, errors.New("Missing int in resource properties")
+		}
+		number, err := NumberToInt(value)
+		if err != nil {
+			return nil, err
+		}
+		allocated[int64(number.(int))] = true
+	}
+	return allocated, nil
+}
+
+func (randomInt *RandomSignedInt32) Invoke() (map[string]interface{}, error) {
+	if randomInt.resourcePoolProperties == nil {
+		return nil, errors.New("Unable to allocate random s_int32. Unable to extract parent int range from pool name")
+	}
+	parentRange, err := numberInterval(randomInt.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := randomInt.allocatedInts()
+	if err != nil {
+		return nil, err
+	}
+
+	from, size := parentRange.from.Int64(), intervalSize(parentRange).Int64()
+	if size > int64(len(allocated)) {
+		// random attempts are bound by the size of the range, the range is scanned from a random int afterwards
+		// so that the last free ints are found as well
+		for i := int64(0); i < size && i < int64(len(allocated))+1000; i++ {
+			if newInt := from + rand.Int63n(size); !allocated[newInt] {
+				return map[string]interface{}{"int": float64(newInt)}, nil
+			}
+		}
+		start := rand.Int63n(size)
+		for i := int64(0); i < size; i++ {
+			if newInt := from + (start+i)%size; !allocated[newInt] {
+				return map[string]interface{}{"int": float64(newInt)}, nil
+			}
+		}
+	}
+
+	return nil, errors.New("Unable to allocate random s_int32 from: " + rangeToStr(parentRange) +
+		". Insufficient capacity to allocate a new s_int32")
+}
+
+func (randomInt *RandomSignedInt32) Capacity() (map[string]interface{}, error) {
+	parentRange, err := numberInterval(randomInt.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return nil, err
+	}
+	var result = make(map[string]interface{})
+	freeCapacity := intervalSize(parentRange).Int64() - int64(len(randomInt.currentResources))
+	result["freeCapacity"] = strconv.FormatInt(freeCapacity, 10)
+	result["utilizedCapacity"] = strconv.Itoa(len(randomInt.currentResources))
+	return result, nil
+}
+
+// Contains checks whether an existing int lies within the parent range of the pool
+func (randomInt *RandomSignedInt32) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	parentRange, err := numberInterval(randomInt.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return false, err
+	}
+	value, ok := resourceProperties["int"]
+	if !ok {
+		return false, errors.New("Missing int in resource properties")
+	}
+	number, err := NumberToInt(value)
+	if err != nil {
+		return false, err
+	}
+	return int64(number.(int)) >= parentRange.from.Int64() && int64(number.(int)) <= parentRange.to.Int64(), nil
+}
@@ -0,0 +1,151 @@
+package src
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	byte2Max = 65536
+	byte4Max = 4294967296
+	// theoretical max capacity of 6 bytes route distinguishers
+	rdRangeCapacity = "281474976710656"
+)
+
+// RouteDistinguisher validates and formats route distinguishers from userInput.asNumber or userInput.ipv4
+// together with userInput.assignedNumber. Valid combinations are ipv4 + 2 byte assigned number,
+// 4 byte AS + 2 byte assigned number and 2 byte AS + 4 byte assigned number.
+type RouteDistinguisher struct {
+	currentResources       []map[string]interface{}
+	resourcePoolProperties map[string]interface{}
+	userInput              map[string]interface{}
+}
+
+func NewRouteDistinguisher(currentResources []map[string]interface{},
+	resourcePoolProperties map[string]interface{},
+	userInput map[string]interface{}) RouteDistinguisher {
+	return RouteDistinguisher{currentResources, resourcePoolProperties, userInput}
+}
+
+// rdNumber parses a positive number of at most 4 bytes from user input, ok is false for missing or zero values
+func rdNumber(userInput map[string]interface{}, key string, description string) (number int64, ok bool, err error) {
+	value, exists := userInput[key]
+	if !exists || value == nil {
+		return 0, false, nil
+	}
+
+	var parsed float64
+	switch typed := value.(type) {
+	case float64:
+		parsed = typed
+	case int:
+		parsed = float64(typed)
+	case int64:
+		parsed = float64(typed)
+	case json.Number:
+		if parsed, err = typed.Float64(); err != nil {
+			parsed = math.NaN()
+		}
+	case string:
+		if typed == "" {
+			return 0, false, nil
+		}
+		parsed = math.NaN()
+	default:
+		parsed = math.NaN()
+	}
+	if parsed == 0 {
+		return 0, false, nil
+	}
+	if !(parsed > 0 && parsed < byte4Max) || parsed != math.Trunc(parsed) {
+		return 0, false, errors.Errorf("Unable to allocate RD for %s: %v. Number is invalid", description, value)
+	}
+	return int64(parsed), true, nil
+}
+
+var ipv4Regexp = regexp.MustCompile(`^([0-9]{1,3})\.([0-9]{1,3})\.([0-9]{1,3})\.([0-9]{1,3})$`)
+
+func isIpv4Address(address string) bool {
+	octets := ipv4Regexp.FindStringSubmatch(address)
+	if octets == nil {
+		return false
+	}
+	for _, octet := range octets[1:] {
+		if value, err := strconv.Atoi(octet); err != nil || value > 255 {
+			return false
+		}
+	}
+	return true
+}
+
+func (rd *RouteDistinguisher) Invoke() (map[string]interface{}, error) {
+	currentResourcesSet := make(map[string]bool)
+	for _, resource := range rd.currentResources {
+		if properties, ok := resource["Properties"].(map[string]interface{}); ok {
+			if value, ok := properties["rd"].(string); ok {
+				currentResourcesSet[value] = true
+			}
+		}
+	}
+
+	assignedNumber, hasAssignedNumber, err := rdNumber(rd.userInput, "assignedNumber", "assigned number")
+	if err != nil {
+		return nil, err
+	}
+	is2ByteAssignedNumber := hasAssignedNumber && assignedNumber < byte2Max
+	asNumber, hasAsNumber, err := rdNumber(rd.userInput, "asNumber", "AS number")
+	if err != nil {
+		return nil, err
+	}
+	is2ByteAs := hasAsNumber && asNumber < byte2Max
+
+	ipv4 := ""
+	if value, ok := rd.userInput["ipv4"]; ok && value != nil && value != "" {
+		ipv4, ok = value.(string)
+		if !ok || !isIpv4Address(ipv4) {
+			return nil, errors.Errorf("Unable to allocate RD, invalid IPv4: %v provided", value)
+		}
+	}
+
+	if ipv4 != "" && hasAsNumber {
+		return nil, errors.Errorf("Unable to allocate RD, both AS: %d number and IPv4: %s provided", asNumber, ipv4)
+	}
+	if hasAsNumber && !is2ByteAs && hasAssignedNumber && !is2ByteAssignedNumber {
+		return nil, errors.Errorf("Unable to allocate RD, 4 byte AS: %d and 4 byte assigned number: %d provided",
+			asNumber, assignedNumber)
+	}
+	if ipv4 != "" && hasAssignedNumber && !is2ByteAssignedNumber {
+		return nil, errors.Errorf("Unable to allocate RD, 4 byte assigned number: %d provided with an IP address",
+			assignedNumber)
+	}
+
+	var newRd string
+	switch {
+	// TYPE 0 and TYPE 2
+	case hasAsNumber && hasAssignedNumber:
+		newRd = strconv.FormatInt(asNumber, 10) + ":" + strconv.FormatInt(assignedNumber, 10)
+	// TYPE 1
+	case ipv4 != "" && hasAssignedNumber:
+		newRd = ipv4 + ":" + strconv.FormatInt(assignedNumber, 10)
+	default:
+		serialized, _ := json.Marshal(rd.userInput)
+		return nil, errors.New("Unable to allocate RD, check the input parameters. User provided input: " +
+			string(serialized))
+	}
+
+	if currentResourcesSet[newRd] {
+		return nil, errors.New("Unable to allocate RD, duplicate RD created: " + newRd)
+	}
+	return map[string]interface{}{"rd": newRd}, nil
+}
+
+func (rd *RouteDistinguisher) Capacity() (map[string]interface{}, error) {
+	var result = make(map[string]interface{})
+	result["freeCapacity"] = rdRangeCapacity
+	result["utilizedCapacity"] = strconv.Itoa(len(rd.currentResources))
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/src"
+)
+
+func assertFreeRanges(t *testing.T, freeRanges *src.FreeRanges, expected [][2]string) {
+	if len(freeRanges.Ranges) != len(expected) {
+		t.Fatalf("Expected free ranges %v, got %v", expected, freeRanges.Ranges)
+	}
+	for i, free := range freeRanges.Ranges {
+		if free.From != expected[i][0] || free.To != expected[i][1] {
+			t.Fatalf("Expected free ranges %v, got %v", expected, freeRanges.Ranges)
+		}
+	}
+}
+
+func assertHistogram(t *testing.T, freeRanges *src.FreeRanges, sizes []int64, counts []int) {
+	if len(freeRanges.Histogram) != len(sizes) {
+		t.Fatalf("Expected histogram of sizes %v, got %v", sizes, freeRanges.Histogram)
+	}
+	for i, bucket := range freeRanges.Histogram {
+		if bucket.Size.Cmp(big.NewInt(sizes[i])) != 0 || bucket.Count != counts[i] {
+			t.Fatalf("Expected histogram of sizes %v with counts %v, got %v", sizes, counts, freeRanges.Histogram)
+		}
+	}
+}
+
+func TestFreeRangesIpv4Prefix(t *testing.T) {
+	allocated := []map[string]interface{}{
+		ipv4Prefix("10.0.0.128", 27, false),
+		ipv4Prefix("10.0.0.0", 26, false),
+	}
+	resourcePool := map[string]interface{}{"address": "10.0.0.0", "prefix": 24, "subnet": false}
+	ipv4PrefixStruct := src.NewIpv4Prefix(allocated, resourcePool, map[string]interface{}{})
+
+	freeRanges, err := ipv4PrefixStruct.FreeRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFreeRanges(t, freeRanges, [][2]string{{"10.0.0.64", "10.0.0.127"}, {"10.0.0.160", "10.0.0.255"}})
+
+	expectedBlocks := []src.FreeBlock{
+		{Address: "10.0.0.64", Prefix: 26}, {Address: "10.0.0.160", Prefix: 27}, {Address: "10.0.0.192", Prefix: 26},
+	}
+	if len(freeRanges.Blocks) != len(expectedBlocks) {
+		t.Fatalf("Expected free blocks %v, got %v", expectedBlocks, freeRanges.Blocks)
+	}
+	for i, block := range freeRanges.Blocks {
+		if block.Address != expectedBlocks[i].Address || block.Prefix != expectedBlocks[i].Prefix {
+			t.Fatalf("Expected free blocks %v, got %v", expectedBlocks, freeRanges.Blocks)
+		}
+	}
+
+	assertHistogram(t, freeRanges, []int64{64, 32}, []int{2, 1})
+	if *freeRanges.Histogram[0].Prefix != 26 || *freeRanges.Histogram[1].Prefix != 27 {
+		t.Fatalf("Expected histogram of prefixes 26 and 27, got %v", freeRanges.Histogram)
+	}
+}
+
+func TestFreeRangesIpv6Prefix(t *testing.T) {
+	allocated := []map[string]interface{}{ipv6Prefix("dead::", 121)}
+	resourcePool := map[string]interface{}{"address": "dead::", "prefix": 120, "subnet": false}
+	ipv6PrefixStruct := src.NewIpv6Prefix(allocated, resourcePool, map[string]interface{}{})
+
+	freeRanges, err := ipv6PrefixStruct.FreeRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFreeRanges(t, freeRanges, [][2]string{{"dead::80", "dead::ff"}})
+	if len(freeRanges.Blocks) != 1 || freeRanges.Blocks[0].Address != "dead::80" || freeRanges.Blocks[0].Prefix != 121 {
+		t.Fatalf("Expected a single free block dead::80/121, got %v", freeRanges.Blocks)
+	}
+	assertHistogram(t, freeRanges, []int64{128}, []int{1})
+}
+
+func TestFreeRangesVlan(t *testing.T) {
+	allocated := []map[string]interface{}{vlan(5), vlan(0), vlan(1)}
+	resourcePool := map[string]interface{}{"from": 0, "to": 10}
+	vlanStruct := src.NewVlan(allocated, resourcePool, map[string]interface{}{})
+
+	freeRanges, err := vlanStruct.FreeRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFreeRanges(t, freeRanges, [][2]string{{"2", "4"}, {"6", "10"}})
+	if len(freeRanges.Blocks) != 0 {
+		t.Fatalf("Vlan pools should not report CIDR blocks, got %v", freeRanges.Blocks)
+	}
+	assertHistogram(t, freeRanges, []int64{5, 3}, []int{1, 1})
+	if freeRanges.Histogram[0].Prefix != nil {
+		t.Fatalf("Vlan pools should not report prefixes, got %v", freeRanges.Histogram)
+	}
+}
+
+func TestFreeRangesVlanRange(t *testing.T) {
+	allocated := []map[string]interface{}{vlanRange(50, 59), vlanRange(10, 19), vlanRange(90, 100)}
+	resourcePool := map[string]interface{}{"from": 0, "to": 100}
+	vlanRangeStruct := src.NewVlanRange(allocated, resourcePool, map[string]interface{}{})
+
+	freeRanges, err := vlanRangeStruct.FreeRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFreeRanges(t, freeRanges, [][2]string{{"0", "9"}, {"20", "49"}, {"60", "89"}})
+	assertHistogram(t, freeRanges, []int64{30, 10}, []int{2, 1})
+
+	full := src.NewVlanRange([]map[string]interface{}{vlanRange(0, 100)}, resourcePool, map[string]interface{}{})
+	if freeRanges, err = full.FreeRanges(); err != nil {
+		t.Fatal(err)
+	}
+	assertFreeRanges(t, freeRanges, [][2]string{})
+	assertHistogram(t, freeRanges, []int64{}, []int{})
+}
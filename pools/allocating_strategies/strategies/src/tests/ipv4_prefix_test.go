@@ -627,3 +627,28 @@ func TestAllocationWhenZeroDesiredSizeAndSubnetTrue(t *testing.T) {
 		t.Fatalf("expected error to be: %s , instead got: %s", expectedError, err)
 	}
 }
+
+func TestIpv4PrefixContains(t *testing.T) {
+	var resourcePool = map[string]interface{}{"prefix": 24, "address": "192.168.1.0", "subnet": false}
+	ipv4PrefixStruct := src.NewIpv4Prefix(nil, resourcePool, nil)
+
+	for _, tc := range []struct {
+		address  string
+		prefix   int
+		expected bool
+	}{
+		{"192.168.1.0", 24, true},
+		{"192.168.1.128", 25, true},
+		{"192.168.1.0", 23, false},
+		{"192.168.2.0", 30, false},
+		{"192.168.0.252", 30, false},
+	} {
+		contains, err := ipv4PrefixStruct.Contains(map[string]interface{}{"address": tc.address, "prefix": tc.prefix})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains != tc.expected {
+			t.Fatalf("different output of %v expected for %s/%d, got: %v", tc.expected, tc.address, tc.prefix, contains)
+		}
+	}
+}
@@ -171,6 +171,24 @@ func TestCapacity(t *testing.T) {
 	}
 }
 
+func TestCapacity32MaskIsExact(t *testing.T) {
+	resourcePool := map[string]interface{}{"prefix": 32, "address": "dead::", "subnet": false}
+	ipv6PrefixStruct := src.NewIpv6Prefix([]map[string]interface{}{
+		ipv6PrefixWithSubnet("dead::", 64, false)}, resourcePool, map[string]interface{}{})
+	output, err := ipv6PrefixStruct.Capacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2^96 - 2^64
+	expectedOutput := map[string]interface{}{
+		"freeCapacity":     "79228162495817593519834398720",
+		"utilizedCapacity": "18446744073709551616",
+	}
+	if eq := reflect.DeepEqual(output, expectedOutput); !eq {
+		t.Fatalf("different output of %s expected, got: %s", expectedOutput, output)
+	}
+}
+
 func TestAllocateAllAddresses104Ipv6Range(t *testing.T) {
 	var allocated []map[string]interface{}
 	var resourcePool = map[string]interface{}{"prefix": 104, "address": "abcd:ef01:2345:6789::", "subnet": false}
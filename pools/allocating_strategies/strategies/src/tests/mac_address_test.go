@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/src"
+)
+
+func macAddress(address string) map[string]interface{} {
+	return map[string]interface{}{"Properties": map[string]interface{}{"address": address}}
+}
+
+func macPool(prefix string, from int, to int) map[string]interface{} {
+	return map[string]interface{}{"prefix": prefix, "from": from, "to": to,
+		"locally_administered": true, "unicast": true}
+}
+
+func invokeMacAddress(t *testing.T, allocated []map[string]interface{}, resourcePool map[string]interface{},
+	userInput map[string]interface{}, expectedAddress string) {
+	macStruct := src.NewMacAddress(allocated, resourcePool, userInput)
+	output, err := macStruct.Invoke()
+	if expectedAddress == "" {
+		if err == nil {
+			t.Fatalf("error expected for pool %v and input %v, got: %v", resourcePool, userInput, output)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("different output of nil expected, got: %s", err)
+	}
+	expectedOutput := map[string]interface{}{"address": expectedAddress}
+	if eq := reflect.DeepEqual(output, expectedOutput); !eq {
+		t.Fatalf("different output of %v expected, got: %v", expectedOutput, output)
+	}
+}
+
+func TestAllocateMacAddress(t *testing.T) {
+	resourcePool := macPool("02:00:5e", 0, 16777215)
+	invokeMacAddress(t, nil, resourcePool, map[string]interface{}{}, "02:00:5e:00:00:00")
+	invokeMacAddress(t, []map[string]interface{}{macAddress("02:00:5e:00:00:00"), macAddress("02:00:5e:00:00:01")},
+		resourcePool, map[string]interface{}{}, "02:00:5e:00:00:02")
+	invokeMacAddress(t, nil, macPool("02-aa-bb-cc-dd", 16, 255), map[string]interface{}{}, "02:aa:bb:cc:dd:10")
+}
+
+func TestAllocateFreedMacAddress(t *testing.T) {
+	allocated := []map[string]interface{}{macAddress("02:00:5e:00:00:00"), macAddress("02:00:5e:00:00:02")}
+	invokeMacAddress(t, allocated, macPool("02:00:5e", 0, 16777215), map[string]interface{}{}, "02:00:5e:00:00:01")
+}
+
+func TestAllocateDesiredMacAddress(t *testing.T) {
+	resourcePool := macPool("02:00:5e", 256, 511)
+	allocated := []map[string]interface{}{macAddress("02:00:5e:00:01:05")}
+	invokeMacAddress(t, allocated, resourcePool, map[string]interface{}{"desiredValue": "02:00:5E:00:01:FF"},
+		"02:00:5e:00:01:ff")
+	invokeMacAddress(t, allocated, resourcePool, map[string]interface{}{"desiredValue": "02:00:5e:00:01:05"}, "")
+	invokeMacAddress(t, allocated, resourcePool, map[string]interface{}{"desiredValue": "02:00:5e:00:02:00"}, "")
+	invokeMacAddress(t, allocated, resourcePool, map[string]interface{}{"desiredValue": "06:00:5e:00:01:06"}, "")
+	invokeMacAddress(t, allocated, resourcePool, map[string]interface{}{"desiredValue": "not a mac"}, "")
+}
+
+func TestMacAddressPoolFull(t *testing.T) {
+	allocated := []map[string]interface{}{macAddress("02:00:00:00:00:fe"), macAddress("02:00:00:00:00:ff")}
+	invokeMacAddress(t, allocated, macPool("02:00:00:00:00", 254, 255), map[string]interface{}{}, "")
+}
+
+func TestMacAddressPoolProperties(t *testing.T) {
+	invokeMacAddress(t, nil, map[string]interface{}{}, map[string]interface{}{}, "")
+	invokeMacAddress(t, nil, macPool("00:00:5e", 0, 10), map[string]interface{}{}, "")
+	invokeMacAddress(t, nil, macPool("03:00:5e", 0, 10), map[string]interface{}{}, "")
+	invokeMacAddress(t, nil, macPool("02:00:5e:00:00:00", 0, 10), map[string]interface{}{}, "")
+	invokeMacAddress(t, nil, macPool("02:00:5e", 10, 0), map[string]interface{}{}, "")
+	invokeMacAddress(t, nil, macPool("02:00:5e", 0, 16777216), map[string]interface{}{}, "")
+	invokeMacAddress(t, nil, macPool("2:0:5e", 0, 10), map[string]interface{}{}, "")
+
+	// universally administered multicast addresses are allowed unless enforced
+	invokeMacAddress(t, nil, map[string]interface{}{"prefix": "01:00:5e", "from": 0, "to": 10},
+		map[string]interface{}{}, "01:00:5e:00:00:00")
+}
+
+func TestMacAddressCapacity(t *testing.T) {
+	allocated := []map[string]interface{}{macAddress("02:00:5e:00:00:00"), macAddress("02:00:5e:00:00:02")}
+	macStruct := src.NewMacAddress(allocated, macPool("02:00:5e", 0, 16777215), nil)
+	output, err := macStruct.Capacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := map[string]interface{}{"freeCapacity": "16777214", "utilizedCapacity": "2"}
+	if eq := reflect.DeepEqual(output, expectedOutput); !eq {
+		t.Fatalf("different output of %v expected, got: %v", expectedOutput, output)
+	}
+}
+
+func TestMacAddressContains(t *testing.T) {
+	macStruct := src.NewMacAddress(nil, macPool("02:00:5e", 0, 255), nil)
+	if contains, err := macStruct.Contains(map[string]interface{}{"address": "02:00:5e:00:00:ff"}); err != nil || !contains {
+		t.Fatalf("02:00:5e:00:00:ff should be contained, got %v %v", contains, err)
+	}
+	if contains, err := macStruct.Contains(map[string]interface{}{"address": "02:00:5e:00:01:00"}); err != nil || contains {
+		t.Fatalf("02:00:5e:00:01:00 should not be contained, got %v %v", contains, err)
+	}
+}
+
+func TestFreeRangesMacAddress(t *testing.T) {
+	allocated := []map[string]interface{}{macAddress("02:00:5e:00:00:05"), macAddress("02:00:5e:00:00:06")}
+	macStruct := src.NewMacAddress(allocated, macPool("02:00:5e", 0, 15), nil)
+	freeRanges, err := macStruct.FreeRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFreeRanges(t, freeRanges, [][2]string{
+		{"02:00:5e:00:00:00", "02:00:5e:00:00:04"}, {"02:00:5e:00:00:07", "02:00:5e:00:00:0f"}})
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/src"
+)
+
+func randomInt(value int) map[string]interface{} {
+	return map[string]interface{}{"Properties": map[string]interface{}{"int": float64(value)}}
+}
+
+func randomInts(from int, to int) []map[string]interface{} {
+	var ints []map[string]interface{}
+	for i := from; i <= to; i++ {
+		ints = append(ints, randomInt(i))
+	}
+	return ints
+}
+
+func TestAllocateRandomInt(t *testing.T) {
+	resourcePool := map[string]interface{}{"from": -10, "to": 10}
+	for i := 0; i < 1000; i++ {
+		randomIntStruct := src.NewRandomSignedInt32(nil, resourcePool, nil)
+		output, err := randomIntStruct.Invoke()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := output["int"].(float64); value < -10 || value > 10 {
+			t.Fatalf("int within [-10-10] expected, got: %v", value)
+		}
+	}
+}
+
+func TestAllocateLastRandomInt(t *testing.T) {
+	allocated := append(randomInts(-8000, 999), randomInts(1001, 44000)...)
+	randomIntStruct := src.NewRandomSignedInt32(allocated, map[string]interface{}{"from": -8000, "to": 44000}, nil)
+	output, err := randomIntStruct.Invoke()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq := reflect.DeepEqual(output, map[string]interface{}{"int": float64(1000)}); !eq {
+		t.Fatalf("the last free int expected, got: %v", output)
+	}
+}
+
+func TestAllocateRandomIntFull(t *testing.T) {
+	allocated := randomInts(-8000, 44000)
+	randomIntStruct := src.NewRandomSignedInt32(allocated, map[string]interface{}{"from": -8000, "to": 44000}, nil)
+	if output, err := randomIntStruct.Invoke(); err == nil {
+		t.Fatalf("error expected for a full pool, got: %v", output)
+	}
+	randomIntStruct = src.NewRandomSignedInt32(nil, map[string]interface{}{}, nil)
+	if output, err := randomIntStruct.Invoke(); err == nil {
+		t.Fatalf("error expected for missing parent range, got: %v", output)
+	}
+}
+
+func TestRandomIntCapacity(t *testing.T) {
+	randomIntStruct := src.NewRandomSignedInt32(randomInts(1, 10), map[string]interface{}{"from": -10, "to": 10}, nil)
+	output, err := randomIntStruct.Capacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := map[string]interface{}{"freeCapacity": "11", "utilizedCapacity": "10"}
+	if eq := reflect.DeepEqual(output, expectedOutput); !eq {
+		t.Fatalf("different output of %v expected, got: %v", expectedOutput, output)
+	}
+}
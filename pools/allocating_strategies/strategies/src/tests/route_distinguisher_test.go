@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/src"
+)
+
+func invokeRd(userInput map[string]interface{}, allocated ...string) (map[string]interface{}, error) {
+	var currentResources []map[string]interface{}
+	for _, rd := range allocated {
+		currentResources = append(currentResources, map[string]interface{}{"Properties": map[string]interface{}{"rd": rd}})
+	}
+	rdStruct := src.NewRouteDistinguisher(currentResources, map[string]interface{}{}, userInput)
+	return rdStruct.Invoke()
+}
+
+func assertRd(t *testing.T, userInput map[string]interface{}, expectedRd string) {
+	output, err := invokeRd(userInput)
+	if err != nil {
+		t.Fatalf("different output of nil expected for %v, got: %s", userInput, err)
+	}
+	if eq := reflect.DeepEqual(output, map[string]interface{}{"rd": expectedRd}); !eq {
+		t.Fatalf("different output of %s expected, got: %v", expectedRd, output)
+	}
+}
+
+func TestAllocateRdAs2(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		as := 1 + rand.Int63n(65000)
+		number := 1 + rand.Int63n(4294967295)
+		assertRd(t, map[string]interface{}{"asNumber": float64(as), "assignedNumber": float64(number)},
+			strconv.FormatInt(as, 10)+":"+strconv.FormatInt(number, 10))
+	}
+}
+
+func TestAllocateRdAs4(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		as := 1 + rand.Int63n(4294967295)
+		number := 1 + rand.Int63n(65000)
+		assertRd(t, map[string]interface{}{"asNumber": float64(as), "assignedNumber": float64(number)},
+			strconv.FormatInt(as, 10)+":"+strconv.FormatInt(number, 10))
+	}
+}
+
+func TestAllocateRdIpv4(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		ipv4 := "1.2." + strconv.Itoa(rand.Intn(256)) + "." + strconv.Itoa(rand.Intn(256))
+		number := 1 + rand.Intn(65000)
+		assertRd(t, map[string]interface{}{"ipv4": ipv4, "assignedNumber": number}, ipv4+":"+strconv.Itoa(number))
+	}
+}
+
+func TestAllocateRdWrongInput(t *testing.T) {
+	for _, userInput := range []map[string]interface{}{
+		{"assignedNumber": 1},
+		{"ipv4": "1.2.3.4"},
+		{"ipv4": "abcd", "assignedNumber": 1},
+		{"ipv4": "1.2.3.4", "assignedNumber": "asdasd"},
+		{"ipv4": "256.2.2.2", "assignedNumber": 1},
+		{"asNumber": 650000, "assignedNumber": 6500000},
+		{"ipv4": "22.2.2.2", "assignedNumber": 6500000},
+		{"ipv4": "22.2.2.2", "asNumber": 1, "assignedNumber": 1},
+		{},
+	} {
+		if output, err := invokeRd(userInput); err == nil {
+			t.Fatalf("error expected for %v, got: %v", userInput, output)
+		}
+	}
+}
+
+func TestAllocateRdDuplicate(t *testing.T) {
+	if output, err := invokeRd(map[string]interface{}{"asNumber": 100, "assignedNumber": 1}, "100:1"); err == nil {
+		t.Fatalf("error expected for a duplicate rd, got: %v", output)
+	}
+}
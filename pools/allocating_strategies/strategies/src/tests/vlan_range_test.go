@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/src"
+)
+
+func invokeVlanRange(t *testing.T, allocated []map[string]interface{}, resourcePool map[string]interface{},
+	userInput map[string]interface{}, expectedOutput map[string]interface{}) {
+	vlanRangeStruct := src.NewVlanRange(allocated, resourcePool, userInput)
+	output, err := vlanRangeStruct.Invoke()
+	if expectedOutput == nil {
+		if err == nil {
+			t.Fatalf("error expected for %v, got: %v", userInput, output)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("different output of nil expected, got: %s", err)
+	}
+	if eq := reflect.DeepEqual(output, expectedOutput); !eq {
+		t.Fatalf("different output of %v expected, got: %v", expectedOutput, output)
+	}
+}
+
+func allocatedVlanRange(from int, to int) map[string]interface{} {
+	return map[string]interface{}{"from": float64(from), "to": float64(to)}
+}
+
+func TestVlanRangeWrongInput(t *testing.T) {
+	parentRange := map[string]interface{}{"from": 0, "to": 4095}
+	invokeVlanRange(t, nil, map[string]interface{}{}, map[string]interface{}{}, nil)
+	invokeVlanRange(t, nil, parentRange, map[string]interface{}{}, nil)
+	invokeVlanRange(t, nil, parentRange, map[string]interface{}{"desiredSize": 0}, nil)
+	invokeVlanRange(t, nil, parentRange, map[string]interface{}{"desiredSize": 4097}, nil)
+	invokeVlanRange(t, []map[string]interface{}{vlanRange(0, 2000), vlanRange(2001, 4090)}, parentRange,
+		map[string]interface{}{"desiredSize": 100}, nil)
+}
+
+func TestAllocateVlanRange(t *testing.T) {
+	parentRange := map[string]interface{}{"from": 0, "to": 4095}
+	invokeVlanRange(t, nil, parentRange, map[string]interface{}{"desiredSize": 4096}, allocatedVlanRange(0, 4095))
+	invokeVlanRange(t, nil, map[string]interface{}{"from": 0, "to": 33}, map[string]interface{}{"desiredSize": 1},
+		allocatedVlanRange(0, 0))
+	invokeVlanRange(t, nil, parentRange, map[string]interface{}{"desiredSize": float64(784)}, allocatedVlanRange(0, 783))
+}
+
+func TestAllocateReleasedVlanRange(t *testing.T) {
+	parentRange := map[string]interface{}{"from": 0, "to": 4095}
+	invokeVlanRange(t, []map[string]interface{}{vlanRange(0, 100), vlanRange(200, 300)}, parentRange,
+		map[string]interface{}{"desiredSize": 10}, allocatedVlanRange(101, 110))
+	invokeVlanRange(t, []map[string]interface{}{vlanRange(200, 300), vlanRange(0, 100)}, parentRange,
+		map[string]interface{}{"desiredSize": 1000}, allocatedVlanRange(301, 1300))
+	invokeVlanRange(t, []map[string]interface{}{vlanRange(100, 200)}, parentRange,
+		map[string]interface{}{"desiredSize": 10}, allocatedVlanRange(0, 9))
+}
+
+func TestAllocateVlanRangeAtTheEnd(t *testing.T) {
+	parentRange := map[string]interface{}{"from": 0, "to": 4095}
+	allocated := []map[string]interface{}{vlanRange(0, 1000), vlanRange(1001, 3000), vlanRange(3001, 4090)}
+	invokeVlanRange(t, allocated, parentRange, map[string]interface{}{"desiredSize": 1}, allocatedVlanRange(4091, 4091))
+
+	allocated = append(allocated, vlanRange(4091, 4091))
+	invokeVlanRange(t, allocated, parentRange, map[string]interface{}{"desiredSize": 4}, allocatedVlanRange(4092, 4095))
+}
+
+func TestVlanRangeCapacity(t *testing.T) {
+	allocated := []map[string]interface{}{vlanRange(0, 2000), vlanRange(2001, 4090)}
+	vlanRangeStruct := src.NewVlanRange(allocated, map[string]interface{}{"from": 0, "to": 4095},
+		map[string]interface{}{"desiredSize": 100})
+	output, err := vlanRangeStruct.Capacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := map[string]interface{}{"freeCapacity": "5", "utilizedCapacity": "4091"}
+	if eq := reflect.DeepEqual(output, expectedOutput); !eq {
+		t.Fatalf("different output of %v expected, got: %v", expectedOutput, output)
+	}
+}
+
+func TestVlanRangeContains(t *testing.T) {
+	vlanRangeStruct := src.NewVlanRange(nil, map[string]interface{}{"from": 100, "to": 200}, nil)
+	if contains, err := vlanRangeStruct.Contains(map[string]interface{}{"from": 100, "to": 200}); err != nil || !contains {
+		t.Fatalf("range [100-200] should be contained, got %v %v", contains, err)
+	}
+	if contains, err := vlanRangeStruct.Contains(map[string]interface{}{"from": 150, "to": 201}); err != nil || contains {
+		t.Fatalf("range [150-201] should not be contained, got %v %v", contains, err)
+	}
+}
@@ -207,3 +207,18 @@ func TestVlanDesireValue(t *testing.T) {
 		t.Fatalf("different output of %s expected, got: %s", expectedOutputError, err)
 	}
 }
+
+func TestVlanContains(t *testing.T) {
+	var resourcePool = map[string]interface{}{"from": 10, "to": 20}
+	vlanStruct := src.NewVlan(nil, resourcePool, nil)
+
+	for value, expected := range map[float64]bool{9: false, 10: true, 15: true, 20: true, 21: false} {
+		contains, err := vlanStruct.Contains(map[string]interface{}{"vlan": value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains != expected {
+			t.Fatalf("different output of %v expected for vlan %v, got: %v", expected, value, contains)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/net-auto/resourceManager/ent"
 	log "github.com/net-auto/resourceManager/logging"
 	"github.com/pkg/errors"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -163,19 +164,13 @@ func (uniqueId *UniqueId) Capacity() (map[string]interface{}, error) {
 	tx := transaction.(*ent.Tx)
 
 	var result = make(map[string]interface{})
-	var fromValue float64
-	var toValue float64
-	to, ok := uniqueId.resourcePoolProperties["to"]
-	if ok {
-		toValue = float64(to.(int))
-	} else {
-		toValue = float64(^uint(0) >> 1)
+	toValue := big.NewInt(int64(^uint(0) >> 1))
+	if to, ok := uniqueId.resourcePoolProperties["to"]; ok {
+		toValue = big.NewInt(int64(to.(int)))
 	}
-	from, ok := uniqueId.resourcePoolProperties["from"]
-	if ok {
-		fromValue = float64(from.(int))
-	} else {
-		fromValue = float64(0)
+	fromValue := big.NewInt(0)
+	if from, ok := uniqueId.resourcePoolProperties["from"]; ok {
+		fromValue = big.NewInt(int64(from.(int)))
 	}
 	query := "SELECT COUNT(properties.int_val) FROM properties JOIN resources " +
 		"ON properties.resource_properties = resources.id WHERE resources.resource_pool_claims = " +
@@ -185,8 +180,10 @@ func (uniqueId *UniqueId) Capacity() (map[string]interface{}, error) {
 		return nil, err
 	}
 	if valueExist == true {
-		freeCapacity := toValue - float64(value) - fromValue + 1
-		result["freeCapacity"] = fmt.Sprintf("%v", freeCapacity)
+		// to - from + 1 - claimed, computed exactly even for the default range up to max int
+		freeCapacity := new(big.Int).Sub(toValue, fromValue)
+		freeCapacity.Add(freeCapacity, big.NewInt(1-value))
+		result["freeCapacity"] = freeCapacity.String()
 		result["utilizedCapacity"] = strconv.Itoa(int(value))
 	}
 	return result, nil
@@ -0,0 +1,145 @@
+package src
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// VlanRange allocates non-overlapping inclusive ranges of vlans of userInput.desiredSize from the parent range of the pool
+type VlanRange struct {
+	currentResources       []map[string]interface{}
+	resourcePoolProperties map[string]interface{}
+	userInput              map[string]interface{}
+}
+
+func NewVlanRange(currentResources []map[string]interface{},
+	resourcePoolProperties map[string]interface{},
+	userInput map[string]interface{}) VlanRange {
+	return VlanRange{currentResources, resourcePoolProperties, userInput}
+}
+
+func rangeToStr(vlanRange valueInterval) string {
+	return "[" + vlanRange.from.String() + "-" + vlanRange.to.String() + "]"
+}
+
+func (vlanRange *VlanRange) allocatedRanges() ([]valueInterval, error) {
+	var allocated []valueInterval
+	for _, resource := range vlanRange.currentResources {
+		properties, ok := resource["Properties"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Unable to extract properties from resource")
+		}
+		allocatedRange, err := numberInterval(properties, "from", "to")
+		if err != nil {
+			return nil, err
+		}
+		allocated = append(allocated, allocatedRange)
+	}
+	return allocated, nil
+}
+
+func (vlanRange *VlanRange) Invoke() (map[string]interface{}, error) {
+	if vlanRange.resourcePoolProperties == nil {
+		return nil, errors.New("Unable to extract parent vlan range from pool name")
+	}
+	parentRange, err := numberInterval(vlanRange.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return nil, err
+	}
+
+	desiredSizeValue, ok := vlanRange.userInput["desiredSize"]
+	if !ok || desiredSizeValue == nil {
+		return nil, errors.New("Unable to allocate VLAN range from: " + rangeToStr(parentRange) +
+			". Desired size of a new vlan range not provided as userInput.desiredSize")
+	}
+	desiredSizeNum, err := NumberToInt(desiredSizeValue)
+	if err != nil {
+		return nil, err
+	}
+	desiredSize := int64(desiredSizeNum.(int))
+	if desiredSize < 1 {
+		return nil, errors.New("Unable to allocate VLAN range from: " + rangeToStr(parentRange) +
+			". Desired size is invalid: " + strconv.FormatInt(desiredSize, 10) + ". Use values >= 1")
+	}
+
+	allocated, err := vlanRange.allocatedRanges()
+	if err != nil {
+		return nil, err
+	}
+	// ranges do not overlap, so that sorting by upper bound sorts them entirely
+	sort.Slice(allocated, func(i, j int) bool {
+		return allocated[i].to.Cmp(allocated[j].to) < 0
+	})
+
+	from := parentRange.from.Int64()
+	newRange := func(from int64) map[string]interface{} {
+		return map[string]interface{}{
+			"from": float64(from),
+			"to":   float64(from + desiredSize - 1),
+		}
+	}
+	// squeeze the new range into the first gap large enough
+	for _, allocatedRange := range allocated {
+		if allocatedRange.from.Int64()-from >= desiredSize {
+			return newRange(from), nil
+		}
+		from = allocatedRange.to.Int64() + 1
+	}
+	if parentRange.to.Int64()-from+1 >= desiredSize {
+		return newRange(from), nil
+	}
+
+	return nil, errors.New("Unable to allocate VLAN range from: " + rangeToStr(parentRange) +
+		". Insufficient capacity to allocate a new range of size: " + strconv.FormatInt(desiredSize, 10))
+}
+
+func (vlanRange *VlanRange) Capacity() (map[string]interface{}, error) {
+	parentRange, err := numberInterval(vlanRange.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := vlanRange.allocatedRanges()
+	if err != nil {
+		return nil, err
+	}
+
+	var utilizedCapacity int64
+	for _, allocatedRange := range allocated {
+		utilizedCapacity += intervalSize(allocatedRange).Int64()
+	}
+	freeCapacity := intervalSize(parentRange).Int64() - utilizedCapacity
+
+	var result = make(map[string]interface{})
+	result["freeCapacity"] = strconv.FormatInt(freeCapacity, 10)
+	result["utilizedCapacity"] = strconv.FormatInt(utilizedCapacity, 10)
+	return result, nil
+}
+
+// Contains checks whether an existing vlan range lies within the parent range of the pool
+func (vlanRange *VlanRange) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	parentRange, err := numberInterval(vlanRange.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return false, err
+	}
+	resourceRange, err := numberInterval(resourceProperties, "from", "to")
+	if err != nil {
+		return false, err
+	}
+	return resourceRange.from.Cmp(parentRange.from) >= 0 && resourceRange.to.Cmp(parentRange.to) <= 0 &&
+		resourceRange.from.Cmp(resourceRange.to) <= 0, nil
+}
+
+// FreeRanges lists ranges of free vlans within the parent range of the pool
+func (vlanRange *VlanRange) FreeRanges() (*FreeRanges, error) {
+	root, err := numberInterval(vlanRange.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return nil, err
+	}
+	allocated, err := vlanRange.allocatedRanges()
+	if err != nil {
+		return nil, err
+	}
+	return newFreeRanges(root, allocated, 0, formatNumber), nil
+}
@@ -2,7 +2,6 @@ package src
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/pkg/errors"
 	"math/big"
 	"regexp"
@@ -141,7 +140,60 @@ func (vlan *Vlan) Invoke() (map[string]interface{}, error) {
 func (vlan *Vlan) Capacity() (map[string]interface{}, error) {
 	var result = make(map[string]interface{})
 	freeCapacity := vlan.FreeCapacity(vlan.resourcePoolProperties, float64(len(vlan.currentResources)))
-	result["freeCapacity"] = fmt.Sprintf("%v", freeCapacity)
+	result["freeCapacity"] = strconv.FormatInt(int64(freeCapacity), 10)
 	result["utilizedCapacity"] = strconv.Itoa(len(vlan.currentResources))
 	return result, nil
 }
+
+// Contains checks whether an existing vlan resource lies within the parent range of the pool
+func (vlan *Vlan) Contains(resourceProperties map[string]interface{}) (bool, error) {
+	from, ok := vlan.resourcePoolProperties["from"]
+	if !ok {
+		return false, errors.New("Missing from in parentRange")
+	}
+	to, ok := vlan.resourcePoolProperties["to"]
+	if !ok {
+		return false, errors.New("Missing to in parentRange")
+	}
+	value, ok := resourceProperties["vlan"]
+	if !ok {
+		return false, errors.New("Missing vlan in resource properties")
+	}
+
+	from, err := NumberToInt(from)
+	if err != nil {
+		return false, err
+	}
+	to, err = NumberToInt(to)
+	if err != nil {
+		return false, err
+	}
+	value, err = NumberToInt(value)
+	if err != nil {
+		return false, err
+	}
+	return value.(int) >= from.(int) && value.(int) <= to.(int), nil
+}
+
+// FreeRanges lists ranges of free vlans within the parent range of the pool
+func (vlan *Vlan) FreeRanges() (*FreeRanges, error) {
+	root, err := numberInterval(vlan.resourcePoolProperties, "from", "to")
+	if err != nil {
+		return nil, err
+	}
+
+	var allocated []valueInterval
+	for _, resource := range vlan.currentResources {
+		properties, ok := resource["Properties"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Unable to extract properties from resource")
+		}
+		allocatedVlan, err := numberInterval(properties, "vlan", "vlan")
+		if err != nil {
+			return nil, err
+		}
+		allocated = append(allocated, allocatedVlan)
+	}
+
+	return newFreeRanges(root, allocated, 0, formatNumber), nil
+}
@@ -8,18 +8,16 @@ import (
 	"github.com/net-auto/resourceManager/ent/property"
 	"github.com/net-auto/resourceManager/graph/graphql/model"
 	log "github.com/net-auto/resourceManager/logging"
+	"math/big"
 	"time"
 
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
 	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
 	"github.com/pkg/errors"
 )
 
-var manualSqlExecutionStrategies = map[string]bool{
-	"unique_id": true,
-}
-
 func DeletePoolProperties(ctx context.Context, client *ent.Client, poolId int) error {
 	poolProperties, err1 := client.PoolProperties.Query().Where(poolproperties.HasPoolWith(resourcePool.ID(poolId))).WithProperties().Only(ctx)
 
@@ -138,13 +136,17 @@ func newAllocatingPoolWithMetaInternal(
 	}
 
 	return &AllocatingPool{
-			SetPool{poolBase{pool, ctx, client}},
+			SetPool{newPoolBase(pool, ctx, client)},
 			invoker},
 		pool, nil
 }
 
 // Destroy removes the pool from DB if there are no more claims
 func (pool AllocatingPool) Destroy() error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
 	// Check if there are no more claims
 	claims, err := pool.QueryResources()
 	if err != nil {
@@ -173,20 +175,145 @@ func (pool AllocatingPool) Destroy() error {
 	return nil
 }
 
+// AllocationStrategy loads the strategy of the pool running the script of the revision the pool is pinned to
 func (pool AllocatingPool) AllocationStrategy() (*ent.AllocationStrategy, error) {
-	return pool.ResourcePool.QueryAllocationStrategy().Only(pool.ctx)
+	strat, err := pool.ResourcePool.QueryAllocationStrategy().Only(pool.ctx)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := pool.ResourcePool.QueryStrategyRevision().Only(pool.ctx)
+	if ent.IsNotFound(err) {
+		// pools which are not pinned run the latest revision
+		return strat, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return StrategyAtRevision(strat, revision), nil
 }
 
 func (pool AllocatingPool) PoolProperties() ([]*ent.Property, error) {
 	return pool.QueryPoolProperties().QueryProperties().WithType().All(pool.ctx)
 }
 
-func (pool AllocatingPool) Capacity() (string, string, error) {
+// UpdatePoolProperties changes values of pool properties. The allocation strategy decides whether every
+// claimed and reserved resource still fits into the updated properties, the update is rejected otherwise.
+func (pool AllocatingPool) UpdatePoolProperties(poolProperties map[string]interface{}) error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
+	nested, err := pool.QueryParentResource().Exist(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve parent resource of pool %d", pool.ID)
+		return errors.Wrapf(err, "Unable to update pool properties of pool #%d", pool.ID)
+	}
+	if nested {
+		return errors.Errorf("Unable to update pool properties of nested pool #%d, "+
+			"they are inherited from its parent resource", pool.ID)
+	}
+
+	ps, err := pool.PoolProperties()
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve pool-properties for pool %d", pool.ID)
+		return errors.Wrapf(err, "Unable to update pool properties of pool #%d", pool.ID)
+	}
+
+	propMap, err := convertProperties(ps)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to convert value from property")
+		return errors.Wrapf(err, "Unable to convert value from property")
+	}
+	propsByName := make(map[string]*ent.Property)
+	for _, p := range ps {
+		propsByName[p.Edges.Type.Name] = p
+	}
+	for name, rawValue := range poolProperties {
+		p, ok := propsByName[name]
+		if !ok {
+			return errors.Errorf("Unable to update pool properties of pool #%d, unknown property \"%s\"", pool.ID, name)
+		}
+		value, err := parsePropertyValue(pool.ctx, p.Edges.Type, rawValue)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to update pool properties of pool #%d", pool.ID)
+		}
+		propMap[name] = value
+	}
+
+	if err := pool.checkResourcesFitProperties(propMap); err != nil {
+		return err
+	}
+
+	for name := range poolProperties {
+		update := pool.client.Property.UpdateOne(propsByName[name])
+		switch v := propMap[name].(type) {
+		case int:
+			update.SetIntVal(v)
+		case string:
+			update.SetStringVal(v)
+		case float64:
+			update.SetFloatVal(v)
+		case bool:
+			update.SetBoolVal(v)
+		}
+		if err := update.Exec(pool.ctx); err != nil {
+			log.Error(pool.ctx, err, "Unable to update pool property %s of pool %d", name, pool.ID)
+			return errors.Wrapf(err, "Unable to update pool property \"%s\" of pool #%d", name, pool.ID)
+		}
+	}
+	return nil
+}
+
+// checkResourcesFitProperties asks the allocation strategy whether claimed and reserved resources
+// would still be allocatable from the pool with propMap as its pool properties
+func (pool AllocatingPool) checkResourcesFitProperties(propMap map[string]interface{}) error {
+	resources, err := pool.QueryClaims().
+		Where(resource.StatusIn(resource.StatusClaimed, resource.StatusReserved)).
+		WithProperties(func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).
+		All(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to get claimed resources from pool %d", pool.ID)
+		return errors.Wrapf(err, "Unable to get claimed resources from pool #%d", pool.ID)
+	}
+	if len(resources) == 0 {
+		return nil
+	}
 
 	strat, err := pool.AllocationStrategy()
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable to retrieve allocation-strategy for pool %d", pool.ID)
-		return "0", "0", errors.Wrapf(err,
+		return errors.Wrapf(err, "Unable to retrieve allocation-strategy for pool #%d", pool.ID)
+	}
+
+	outside, err := ResourcesOutsideOfPoolProperties(pool.ctx, strat, model.ResourcePoolInput{
+		ResourcePoolID:   pool.ID,
+		PoolProperties:   propMap,
+		ResourcePoolName: pool.Name,
+	}, propMap, resources)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to update pool properties of pool #%d with %d claimed resource(s)",
+			pool.ID, len(resources))
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+
+	conflicts := make([]PoolPropertiesConflict, 0, len(outside))
+	for _, res := range outside {
+		props, err := PropertiesToMap(res.Edges.Properties)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to serialize properties of resource #%d", res.ID)
+		}
+		conflicts = append(conflicts, PoolPropertiesConflict{ResourceID: res.ID, Properties: props})
+	}
+	return &PoolPropertiesConflictError{PoolName: pool.Name, Conflicts: conflicts}
+}
+
+func (pool AllocatingPool) Capacity() (*PoolCapacity, error) {
+
+	strat, err := pool.AllocationStrategy()
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve allocation-strategy for pool %d", pool.ID)
+		return nil, errors.Wrapf(err,
 			"Unable to retrieve allocation-strategy for pool %d, allocation strategy loading error", pool.ID)
 	}
 	var currentResources []*model.ResourceInput
@@ -195,7 +322,7 @@ func (pool AllocatingPool) Capacity() (string, string, error) {
 
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable to load resources for pool %d", pool.ID)
-		return "0", "0", errors.Wrapf(err,
+		return nil, errors.Wrapf(err,
 			"Unable to get properties from pool #%d, resource type loading error ", pool.ID)
 	}
 
@@ -203,32 +330,63 @@ func (pool AllocatingPool) Capacity() (string, string, error) {
 
 	if propErr != nil {
 		log.Error(pool.ctx, propErr, "Unable to convert value from property")
-		return "0", "0", errors.Wrapf(propErr, "Unable to convert value from property")
+		return nil, errors.Wrapf(propErr, "Unable to convert value from property")
 	}
 
-	if !manualSqlExecutionStrategies[strat.Name] {
+	if !isManualSqlExecutionStrategy(strat) {
 		currentResources, err = getFullListOfResources(pool)
 		if err != nil {
 			log.Error(pool.ctx, err, "Unable to load resources for pool %d", pool.ID)
-			return "0", "0", errors.Wrapf(err,
+			return nil, errors.Wrapf(err,
 				"Unable to load resources for pool %d, resource loading error", pool.ID)
 		}
 	} else {
-		// The query call doesn't create a transaction, so we have to create it manually
-		tx, err := pool.client.Tx(pool.ctx)
+		// These strategies query the DB themselves, queries don't run in a transaction so one is created manually.
+		// Claims and frees already run in one, nested transactions are not supported.
+		txCtx, finish, err := withStrategyTransaction(pool.ctx, pool.client)
 		if err != nil {
 			log.Error(pool.ctx, err, "Unable to open new read transaction for pool %d", pool.ID)
-			return "0", "0", errors.Wrapf(err, "Unable to open new read transaction for pool %d", pool.ID)
+			return nil, errors.Wrapf(err, "Unable to open new read transaction for pool %d", pool.ID)
 		}
-		pool.ctx = context.WithValue(pool.ctx, ent.TxCtxKey{}, tx)
-		defer func(tx *ent.Tx) {
-			err := tx.Commit()
-			if err != nil {
-				log.Error(pool.ctx, err, "Unable to commit transaction for pool %d", pool.ID)
-			}
-		}(tx)
+		defer finish()
+		pool.ctx = txCtx
+	}
+
+	return pool.strategyCapacity(strat, propMap, currentResources)
+}
+
+// carvedOutCapacity is the utilized capacity of resources backing nested pools, as measured by the strategy
+func (pool AllocatingPool) carvedOutCapacity() (*big.Int, error) {
+	strat, propMap, _, err := pool.loadStrategyInput()
+	if err != nil {
+		return nil, err
+	}
+	if isManualSqlExecutionStrategy(strat) {
+		return pool.SetPool.carvedOutCapacity()
+	}
+
+	carvedOut, err := pool.findResources().Where(resource.HasNestedPool()).WithProperties(
+		func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).All(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to get resources with nested pools from pool %d", pool.ID)
+		return nil, errors.Wrapf(err, "Unable to get resources with nested pools from pool #%d", pool.ID)
+	}
+	if len(carvedOut) == 0 {
+		return big.NewInt(0), nil
+	}
+	currentResources, err := toResourceInputs(pool.ctx, carvedOut)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := pool.strategyCapacity(strat, propMap, currentResources)
+	if err != nil {
+		return nil, err
 	}
+	return capacity.Utilized, nil
+}
 
+func (pool AllocatingPool) strategyCapacity(strat *ent.AllocationStrategy, propMap map[string]interface{},
+	currentResources []*model.ResourceInput) (*PoolCapacity, error) {
 	var emptyMap = map[string]interface{}{}
 	result, _, err := InvokeAllocationStrategy(pool.ctx, pool.invoker, strat, emptyMap, model.ResourcePoolInput{
 		ResourcePoolID:   pool.ID,
@@ -237,54 +395,28 @@ func (pool AllocatingPool) Capacity() (string, string, error) {
 	}, currentResources, propMap, "capacity()")
 	if err != nil || result == nil {
 		log.Error(pool.ctx, err, "Invoking allocation strategy failed")
-		return "0", "0", errors.Wrapf(err,
+		return nil, errors.Wrapf(err,
 			"Unable to compute capacity pool #%d, allocation strategy \"%s\" failed", pool.ID, strat.Name)
 	}
 
-	var resultFreeCapacity = "0"
-	var resultUtilizedCapacity = "0"
-
-	if result["freeCapacity"] != nil {
-		resultFreeCapacity = result["freeCapacity"].(string)
-	}
-
-	if result["utilizedCapacity"] != nil {
-		resultUtilizedCapacity = result["utilizedCapacity"].(string)
+	capacity, err := NewPoolCapacity(result["freeCapacity"], result["utilizedCapacity"])
+	if err != nil {
+		log.Error(pool.ctx, err, "Allocation strategy returned invalid capacity for pool %d", pool.ID)
+		return nil, errors.Wrapf(err,
+			"Unable to compute capacity pool #%d, allocation strategy \"%s\" returned invalid capacity", pool.ID, strat.Name)
 	}
-
-	return resultFreeCapacity, resultUtilizedCapacity, nil
+	return capacity, nil
 }
 
 // ClaimResource allocates the next available resource
 func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (*ent.Resource, error) {
-
-	strat, err := pool.AllocationStrategy()
-	if err != nil {
-		log.Error(pool.ctx, err, "Unable to retrieve allocation-strategy for pool %d", pool.ID)
-		return nil, errors.Wrapf(err,
-			"Unable to claim resource from pool #%d, allocation strategy loading error ", pool.ID)
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
 	}
 
-	ps, err := pool.PoolProperties()
-
+	strat, propMap, resourceType, err := pool.loadStrategyInput()
 	if err != nil {
-		log.Error(pool.ctx, err, "Unable to retrieve pool-properties for pool %d", pool.ID)
-		return nil, errors.Wrapf(err,
-			"Unable to claim resource from pool #%d, resource type loading error ", pool.ID)
-	}
-
-	propMap, propErr := convertProperties(ps)
-
-	if propErr != nil {
-		log.Error(pool.ctx, propErr, "Unable to convert value from property")
-		return nil, errors.Wrapf(propErr, "Unable to convert value from property")
-	}
-
-	resourceType, err := pool.ResourceType()
-	if err != nil {
-		log.Error(pool.ctx, err, "Unable retrieve resource type for pool with ID: %d", pool.ID)
-		return nil, errors.Wrapf(err,
-			"Unable to claim resource from pool #%d, resource type loading error ", pool.ID)
+		return nil, err
 	}
 
 	var resourcePool model.ResourcePoolInput
@@ -292,7 +424,7 @@ func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, descr
 	resourcePool.ResourcePoolID = pool.ID
 	var currentResources []*model.ResourceInput
 
-	if !manualSqlExecutionStrategies[strat.Name] {
+	if !isManualSqlExecutionStrategy(strat) {
 		currentResources, err = getFullListOfResources(pool)
 		if err != nil {
 			log.Error(pool.ctx, err, "Unable retrieve already claimed resources for pool with ID: %d", pool.ID)
@@ -307,14 +439,120 @@ func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, descr
 	} else {
 		functionName = "invoke()"
 	}
-	resourceProperties, _ /*TODO do something with logs */, err := InvokeAllocationStrategy(
+	resourceProperties, stdErr, err := InvokeAllocationStrategy(
 		pool.ctx, pool.invoker, strat, userInput, resourcePool, currentResources, propMap, functionName)
+	appendStrategyLog(pool.ctx, stdErr)
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable to claim resource with pool with ID: %d, invoking strategy failed", pool.ID)
 		return nil, errors.Wrapf(err,
 			"Unable to claim resource from pool #%d, allocation strategy \"%s\" failed", pool.ID, strat.Name)
 	}
 
+	return pool.claimResourceWithProperties(resourceProperties, resourceType, description, alternativeId)
+}
+
+// ClaimResources allocates count next available resources, the strategy computes all of them in a single run
+func (pool AllocatingPool) ClaimResources(count int, userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (ent.Resources, error) {
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
+	}
+
+	if count < 1 {
+		return nil, errors.Errorf("Unable to claim resources from pool #%d, count must be positive, got %d", pool.ID, count)
+	}
+
+	strat, propMap, resourceType, err := pool.loadStrategyInput()
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed ent.Resources
+
+	if isManualSqlExecutionStrategy(strat) {
+		// these strategies read claims directly from DB, each claim has to be stored before computing the next one
+		for i := 0; i < count; i++ {
+			res, err := pool.ClaimResource(userInput, description, alternativeId)
+			if err != nil {
+				return nil, err
+			}
+			claimed = append(claimed, res)
+		}
+		return claimed, nil
+	}
+
+	currentResources, err := getFullListOfResources(pool)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable retrieve already claimed resources for pool with ID: %d", pool.ID)
+		return nil, errors.Wrapf(err,
+			"Unable to claim resources from pool #%d, resource loading error ", pool.ID)
+	}
+
+	var resourcePool model.ResourcePoolInput
+	resourcePool.ResourcePoolName = pool.Name
+	resourcePool.ResourcePoolID = pool.ID
+
+	allocated, stdErr, err := InvokeAllocationStrategyBatch(
+		pool.ctx, pool.invoker, strat, userInput, resourcePool, currentResources, propMap, count)
+	appendStrategyLog(pool.ctx, stdErr)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to claim resources with pool with ID: %d, invoking strategy failed", pool.ID)
+		return nil, errors.Wrapf(err,
+			"Unable to claim resources from pool #%d, allocation strategy \"%s\" failed", pool.ID, strat.Name)
+	}
+
+	for _, resourceProperties := range allocated {
+		res, err := pool.claimResourceWithProperties(resourceProperties, resourceType, description, alternativeId)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, res)
+	}
+
+	return claimed, nil
+}
+
+// loadStrategyInput loads the allocation strategy, pool properties and resource type needed to run the strategy
+func (pool AllocatingPool) loadStrategyInput() (*ent.AllocationStrategy, map[string]interface{}, *ent.ResourceType, error) {
+	strat, err := pool.AllocationStrategy()
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve allocation-strategy for pool %d", pool.ID)
+		return nil, nil, nil, errors.Wrapf(err,
+			"Unable to claim resource from pool #%d, allocation strategy loading error ", pool.ID)
+	}
+
+	ps, err := pool.PoolProperties()
+
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve pool-properties for pool %d", pool.ID)
+		return nil, nil, nil, errors.Wrapf(err,
+			"Unable to claim resource from pool #%d, resource type loading error ", pool.ID)
+	}
+
+	propMap, propErr := convertProperties(ps)
+
+	if propErr != nil {
+		log.Error(pool.ctx, propErr, "Unable to convert value from property")
+		return nil, nil, nil, errors.Wrapf(propErr, "Unable to convert value from property")
+	}
+
+	resourceType, err := pool.ResourceType()
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable retrieve resource type for pool with ID: %d", pool.ID)
+		return nil, nil, nil, errors.Wrapf(err,
+			"Unable to claim resource from pool #%d, resource type loading error ", pool.ID)
+	}
+
+	return strat, propMap, resourceType, nil
+}
+
+// claimResourceWithProperties claims a resource computed by the allocation strategy,
+// creating it in DB unless it already exists (freed or benched)
+func (pool AllocatingPool) claimResourceWithProperties(
+	resourceProperties map[string]interface{},
+	resourceType *ent.ResourceType,
+	description *string,
+	alternativeId map[string]interface{}) (*ent.Resource, error) {
+
 	// Query to check whether this resource already exists.
 	// 1. construct query
 	query, err := pool.findResource(RawResourceProps(resourceProperties))
@@ -347,6 +585,12 @@ func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, descr
 				"Unexpected error creating resource in pool #%d, properties \"%s\" . "+
 					"Created %d resources instead of one.", pool.ID, resourceProperties, len(created))
 		}
+		if err := pool.trackCapacity(created[0], &pool.capacityChange.allocated); err != nil {
+			return nil, err
+		}
+		if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventClaim, pool.ID, created[0].ID, nil); err != nil {
+			return nil, err
+		}
 		return created[0], nil
 	} else if len(foundResources) > 1 {
 		log.Error(pool.ctx, err, "Unable to claim resource for pool ID %d, database contains more than one result", pool.ID)
@@ -355,7 +599,7 @@ func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, descr
 	}
 	res := foundResources[0]
 	// 3b. Claim found resource if possible
-	if res.Status == resource.StatusClaimed || res.Status == resource.StatusRetired {
+	if res.Status == resource.StatusClaimed || res.Status == resource.StatusRetired || res.Status == resource.StatusReserved {
 		log.Error(pool.ctx, err, "Resource with ID %d is in an incorrect state %+v", res.ID, res.Status)
 		return nil, errors.Errorf("Resource #%d is in incorrect state \"%s\"", res.ID, res.Status)
 	} else if res.Status == resource.StatusBench {
@@ -366,6 +610,10 @@ func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, descr
 				"Unable to claim resource #%d from pool #%d, resource cannot be claimed before %s", res.ID, pool.ID, cutoff)
 		}
 	}
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot update resource #%d", res.ID)
+	}
 	res.Status = resource.StatusClaimed
 	err = pool.client.Resource.
 		UpdateOne(res).
@@ -379,9 +627,43 @@ func (pool AllocatingPool) ClaimResource(userInput map[string]interface{}, descr
 		log.Error(pool.ctx, err, "Cannot update resource %d", res.ID)
 		return nil, errors.Wrapf(err, "Cannot update resource #%d", res.ID)
 	}
+	if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventClaim, pool.ID, res.ID, before); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
+// acceptMovedResource claims the value of a resource moved from another pool,
+// the allocation strategy has to confirm that the value belongs to this pool
+func (pool AllocatingPool) acceptMovedResource(moved *ent.Resource) (*ent.Resource, error) {
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
+	}
+
+	strat, propMap, resourceType, err := pool.loadStrategyInput()
+	if err != nil {
+		return nil, err
+	}
+
+	outside, err := ResourcesOutsideOfPoolProperties(pool.ctx, strat, model.ResourcePoolInput{
+		ResourcePoolID:   pool.ID,
+		PoolProperties:   propMap,
+		ResourcePoolName: pool.Name,
+	}, propMap, []*ent.Resource{moved})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to check whether pool \"%s\" accepts resource #%d", pool.Name, moved.ID)
+	}
+	if len(outside) > 0 {
+		return nil, errors.Errorf("Resource #%d does not belong to pool \"%s\"", moved.ID, pool.Name)
+	}
+
+	raw, err := PropertiesToMap(moved.Edges.Properties)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to serialize properties of resource #%d", moved.ID)
+	}
+	return pool.claimResourceWithProperties(raw, resourceType, moved.Description, moved.AlternateID)
+}
+
 func getFullListOfResources(pool AllocatingPool) ([]*model.ResourceInput, error) {
 	return pool.loadClaimedResources()
 }
@@ -402,7 +684,6 @@ func convertProperties(ps []*ent.Property) (map[string]interface{}, error) {
 }
 
 func (pool AllocatingPool) loadClaimedResources() ([]*model.ResourceInput, error) {
-	var currentResources []*model.ResourceInput
 	claimedResources, err := pool.findResources().WithProperties(
 		func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).All(pool.ctx)
 	if err != nil {
@@ -410,12 +691,18 @@ func (pool AllocatingPool) loadClaimedResources() ([]*model.ResourceInput, error
 		return nil, errors.Wrapf(err,
 			"Unable to get claimed resources from pool #%d, resource loading error", pool.ID)
 	}
-	for _, claimedResource := range claimedResources {
+	return toResourceInputs(pool.ctx, claimedResources)
+}
+
+// toResourceInputs converts resources with loaded properties into the strategy input format
+func toResourceInputs(ctx context.Context, resources []*ent.Resource) ([]*model.ResourceInput, error) {
+	var currentResources []*model.ResourceInput
+	for _, claimedResource := range resources {
 		var r model.ResourceInput
 		r.UpdatedAt = claimedResource.UpdatedAt.String()
 		r.Status = claimedResource.Status.String()
 		if propsToMap, err := PropertiesToMap(claimedResource.Edges.Properties); err != nil {
-			log.Error(pool.ctx, err, "Unable to serialize resource properties")
+			log.Error(ctx, err, "Unable to serialize resource properties")
 			return nil, errors.Wrapf(err, "Unable to serialize resource properties")
 		} else {
 			r.Properties = propsToMap
@@ -426,12 +713,50 @@ func (pool AllocatingPool) loadClaimedResources() ([]*model.ResourceInput, error
 	return currentResources, nil
 }
 
+// ReserveResource holds a newly allocated resource for ttlSeconds until it is committed or cancelled
+func (pool AllocatingPool) ReserveResource(userInput map[string]interface{}, description *string,
+	alternativeId map[string]interface{}, ttlSeconds int) (*ent.Resource, error) {
+	if err := pool.checkReservationTtl(ttlSeconds); err != nil {
+		return nil, err
+	}
+
+	res, err := pool.ClaimResource(userInput, description, alternativeId)
+	if err != nil {
+		return nil, err
+	}
+	return pool.reserveResource(res, ttlSeconds)
+}
+
+// CancelReservation removes a reserved resource from the pool, the safety period does not apply
+func (pool AllocatingPool) CancelReservation(resourceId int) error {
+	return pool.cancelReservationInner(resourceId, pool.freeResourceImmediately)
+}
+
 // FreeResource deallocates the resource identified by its properties
 func (pool AllocatingPool) FreeResource(raw RawResourceProps) error {
 	return pool.freeResourceInner(raw, pool.retireResource, pool.freeResourceImmediately, pool.benchResource)
 }
 
+// FreeResources deallocates all resources identified by their properties or IDs, all or nothing
+func (pool AllocatingPool) FreeResources(raws []RawResourceProps, resourceIds []int) error {
+	return pool.freeResourcesInner(raws, resourceIds, pool.retireResource, pool.freeResourceImmediately, pool.benchResource)
+}
+
+// benchResource keeps capacity utilized, benched resources are passed to the allocation strategy
+func (pool AllocatingPool) benchResource(res *ent.Resource) error {
+	return pool.setUnclaimedStatus(res, resource.StatusBench)
+}
+
+// retireResource keeps capacity utilized, retired resources are passed to the allocation strategy
+func (pool AllocatingPool) retireResource(res *ent.Resource) error {
+	return pool.setUnclaimedStatus(res, resource.StatusRetired)
+}
+
 func (pool AllocatingPool) freeResourceImmediately(res *ent.Resource) error {
+	if err := pool.trackCapacity(res, &pool.capacityChange.released); err != nil {
+		return err
+	}
+
 	// Delete props
 	for _, prop := range res.Edges.Properties {
 		if err := pool.client.Property.DeleteOne(prop).Exec(pool.ctx); err != nil {
@@ -449,3 +774,61 @@ func (pool AllocatingPool) freeResourceImmediately(res *ent.Resource) error {
 
 	return nil
 }
+
+// trackCapacity records a resource created in or deleted from the pool, capacity it uses is measured
+// by the allocation strategy once the change is stored. Deleted resources have to be recorded before deletion.
+func (pool AllocatingPool) trackCapacity(res *ent.Resource, resources *[]*model.ResourceInput) error {
+	withProperties, err := pool.client.Resource.Query().
+		Where(resource.ID(res.ID)).
+		WithProperties(func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).
+		Only(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to load properties of resource ID %d", res.ID)
+		return errors.Wrapf(err, "Unable to load properties of resource #%d", res.ID)
+	}
+	inputs, err := toResourceInputs(pool.ctx, []*ent.Resource{withProperties})
+	if err != nil {
+		return err
+	}
+	*resources = append(*resources, inputs...)
+	return nil
+}
+
+// measureCapacityChange measures capacity of resources created and deleted by the pool using its strategy
+func (pool AllocatingPool) measureCapacityChange(change *capacityChange) error {
+	if len(change.allocated) == 0 && len(change.released) == 0 {
+		return nil
+	}
+	strat, propMap, _, err := pool.loadStrategyInput()
+	if err != nil {
+		return err
+	}
+	allocated, err := pool.utilizedCapacityOf(strat, propMap, change.allocated)
+	if err != nil {
+		return err
+	}
+	released, err := pool.utilizedCapacityOf(strat, propMap, change.released)
+	if err != nil {
+		return err
+	}
+	change.use(allocated.Sub(allocated, released))
+	change.allocated, change.released = nil, nil
+	return nil
+}
+
+// utilizedCapacityOf is the capacity used by the resources, as measured by the strategy
+func (pool AllocatingPool) utilizedCapacityOf(strat *ent.AllocationStrategy, propMap map[string]interface{},
+	resources []*model.ResourceInput) (*big.Int, error) {
+	if len(resources) == 0 {
+		return big.NewInt(0), nil
+	}
+	if isManualSqlExecutionStrategy(strat) {
+		// these strategies count resources stored in DB, each resource uses a single unit
+		return big.NewInt(int64(len(resources))), nil
+	}
+	capacity, err := pool.strategyCapacity(strat, propMap, resources)
+	if err != nil {
+		return nil, err
+	}
+	return capacity.Utilized, nil
+}
@@ -2,6 +2,7 @@ package pools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/net-auto/resourceManager/graph/graphql/model"
 	"reflect"
@@ -222,3 +223,150 @@ func TestAllocatingPool_WithSafetyPeriod(t *testing.T) {
 	assertInstancesInDb(ts.client.Resource.Query().AllX(ts.ctx), 1, t)
 	assertInstancesInDb(ts.client.Resource.Query().Where(resource.StatusEQ(resource.StatusClaimed)).AllX(ts.ctx), 1, t)
 }
+
+func TestAllocatingPool_ClaimResources(t *testing.T) {
+	mockInvoker := mockInvoker{map[string]interface{}{
+		batchResultKey: []interface{}{
+			map[string]interface{}{"vlan": 1},
+			map[string]interface{}{"vlan": 2},
+		},
+	}, nil}
+	ts := CreateTestSetup(t, mockInvoker, schema.ResourcePoolDealocationImmediately)
+	defer ts.Close()
+
+	userInput := make(map[string]interface{})
+	if _, err := ts.pool.ClaimResources(3, userInput, nil, nil); err == nil {
+		t.Fatalf("Claim should have failed, strategy returned less resources than requested")
+	}
+	assertInstancesInDb(ts.client.Resource.Query().AllX(ts.ctx), 0, t)
+
+	claims, err := ts.pool.ClaimResources(2, userInput, nil, nil)
+	if err != nil {
+		t.Fatalf("Unable to claim resources: %s", err)
+	}
+	if len(claims) != 2 {
+		t.Fatalf("Expected 2 claims, got: %d", len(claims))
+	}
+	assertInstancesInDb(ts.client.Resource.Query().AllX(ts.ctx), 2, t)
+	assertInstancesInDb(ts.client.Property.Query().AllX(ts.ctx), 2, t)
+}
+
+func TestAllocatingPool_ReserveResource(t *testing.T) {
+	mockInvoker := mockInvoker{RawResourceProps{"vlan": 1}, nil}
+	ts := CreateTestSetup(t, mockInvoker, schema.ResourcePoolDealocationRetire)
+	defer ts.Close()
+
+	userInput := make(map[string]interface{})
+	reserved, err := ts.pool.ReserveResource(userInput, nil, nil, 60)
+	if err != nil {
+		t.Fatalf("Unable to reserve resource: %s", err)
+	}
+
+	// strategy returning the reserved resource again must not claim it
+	if _, err := ts.pool.ClaimResource(userInput, nil, nil); err == nil {
+		t.Fatalf("Claiming reserved resource should return error")
+	}
+
+	ts.client.Resource.UpdateOneID(reserved.ID).SetLeaseExpiresAt(time.Now().Add(-time.Second)).ExecX(ts.ctx)
+	if _, err := ts.pool.CommitReservation(reserved.ID); err == nil {
+		t.Fatalf("Committing expired reservation should return error")
+	}
+
+	// cancelled reservation is removed regardless of the safety period
+	if err := ts.pool.CancelReservation(reserved.ID); err != nil {
+		t.Fatalf("Unable to cancel reservation: %s", err)
+	}
+	assertInstancesInDb(ts.client.Resource.Query().AllX(ts.ctx), 0, t)
+	assertInstancesInDb(ts.client.Property.Query().AllX(ts.ctx), 0, t)
+}
+
+func TestAllocatingPool_ClaimResourceIdempotent(t *testing.T) {
+	mockInvoker := mockInvoker{RawResourceProps{"vlan": 1}, nil}
+	ts := CreateTestSetup(t, mockInvoker, schema.ResourcePoolDealocationImmediately)
+	defer ts.Close()
+
+	userInput := make(map[string]interface{})
+	altId := map[string]interface{}{"workflow": "abc"}
+	claimed, err := ClaimResourceIdempotent(ts.pool, userInput, nil, altId)
+	if err != nil {
+		t.Fatalf("Unable to claim resource: %s", err)
+	}
+
+	// strategy would return the same, already claimed, resource so a non-idempotent retry fails
+	retried, err := ClaimResourceIdempotent(ts.pool, userInput, nil, altId)
+	if err != nil {
+		t.Fatalf("Retried idempotent claim should not fail: %s", err)
+	}
+	if retried.ID != claimed.ID {
+		t.Fatalf("Retried claim should return resource %d, got %d", claimed.ID, retried.ID)
+	}
+	assertInstancesInDb(ts.client.Resource.Query().AllX(ts.ctx), 1, t)
+}
+
+func TestAllocatingPool_UpdatePoolProperties(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+	fromType := client.PropertyType.Create().SetName("from").SetType("int").SetMandatory(true).SaveX(ctx)
+	toType := client.PropertyType.Create().SetName("to").SetType("int").SetMandatory(true).SaveX(ctx)
+	propsType := client.ResourceType.Create().SetName("vlanPool-ResourceType").
+		AddPropertyTypes(fromType, toType).SaveX(ctx)
+	poolProperties, err := CreatePoolProperties(ctx, client,
+		[]map[string]interface{}{{"from": 10, "to": 20}}, propsType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strat := client.AllocationStrategy.Create().
+		SetName("vlan").
+		SetLang(allocationstrategy.LangGo).
+		SetScript("vlan").
+		SaveX(ctx)
+
+	pool, poolEntity, err := NewAllocatingPoolWithMeta(ctx, client, resType, strat, "vlanPool", nil,
+		schema.ResourcePoolDealocationImmediately, poolProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := pool.ClaimResources(3, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = UpdateResourcePool(ctx, client, poolEntity.ID, nil, nil, map[string]interface{}{"from": 11})
+	var conflictErr *PoolPropertiesConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Narrowing pool properties past claimed resources should fail, got: %v", err)
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].ResourceID != claimed[0].ID {
+		t.Fatalf("Only resource #%d should be reported, got: %v", claimed[0].ID, conflictErr.Conflicts)
+	}
+
+	if _, err = UpdateResourcePool(ctx, client, poolEntity.ID, nil, nil, map[string]interface{}{"unknown": 1}); err == nil {
+		t.Fatalf("Updating unknown pool property should fail")
+	}
+
+	description := "narrowed"
+	period := 60
+	updated, err := UpdateResourcePool(ctx, client, poolEntity.ID, &description, &period,
+		map[string]interface{}{"from": 10, "to": 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updated.Description != description || updated.DealocationSafetyPeriod != period {
+		t.Fatalf("Description and safety period should be updated, got: %v", updated)
+	}
+
+	ps, _ := pool.(*AllocatingPool).PoolProperties()
+	props, _ := convertProperties(ps)
+	if props["from"] != 10 || props["to"] != 12 {
+		t.Fatalf("Pool properties should be updated, got: %v", props)
+	}
+	if _, err := pool.ClaimResource(map[string]interface{}{}, nil, nil); err == nil {
+		t.Fatalf("Pool should be exhausted after narrowing its properties")
+	}
+}
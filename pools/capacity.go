@@ -0,0 +1,252 @@
+package pools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// PoolCapacity is the exact capacity of a pool. Strategies report capacity in various formats
+// (integers, floats, float strings, big integer text), all of them are normalized into big integers.
+type PoolCapacity struct {
+	Free     *big.Int
+	Utilized *big.Int
+}
+
+// NewPoolCapacity normalizes free and utilized capacity as reported by a pool or an allocation strategy
+func NewPoolCapacity(free interface{}, utilized interface{}) (*PoolCapacity, error) {
+	freeValue, err := parseCapacityValue(free)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid free capacity")
+	}
+	utilizedValue, err := parseCapacityValue(utilized)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid utilized capacity")
+	}
+	return &PoolCapacity{Free: freeValue, Utilized: utilizedValue}, nil
+}
+
+func newPoolCapacityFromInts(free int, utilized int) *PoolCapacity {
+	return &PoolCapacity{Free: big.NewInt(int64(free)), Utilized: big.NewInt(int64(utilized))}
+}
+
+// Total is the sum of free and utilized capacity
+func (c *PoolCapacity) Total() *big.Int {
+	return new(big.Int).Add(c.Free, c.Utilized)
+}
+
+// UtilizationPercentage is the utilized capacity in percent of the total capacity, an empty pool is fully utilized
+func (c *PoolCapacity) UtilizationPercentage() float64 {
+	total := c.Total()
+	if total.Sign() <= 0 {
+		return 100
+	}
+	percent := new(big.Float).SetInt(c.Utilized)
+	percent.Mul(percent, big.NewFloat(100))
+	percent.Quo(percent, new(big.Float).SetInt(total))
+	value, _ := percent.Float64()
+	return value
+}
+
+// capacityChange collects changes of capacity made by claims, frees and other changes of resources in a pool
+// until UpdatePoolCapacity adds them to the stored counters of the pool
+type capacityChange struct {
+	free     *big.Int
+	utilized *big.Int
+	// resources created and deleted by allocating pools, their capacity is measured by the allocation strategy
+	allocated []*model.ResourceInput
+	released  []*model.ResourceInput
+}
+
+func newCapacityChange() *capacityChange {
+	return &capacityChange{free: big.NewInt(0), utilized: big.NewInt(0)}
+}
+
+func (c *capacityChange) add(free int64, utilized int64) {
+	c.free.Add(c.free, big.NewInt(free))
+	c.utilized.Add(c.utilized, big.NewInt(utilized))
+}
+
+// use moves capacity from free to utilized, negative utilized capacity moves it back
+func (c *capacityChange) use(utilized *big.Int) {
+	c.free.Sub(c.free, utilized)
+	c.utilized.Add(c.utilized, utilized)
+}
+
+func (c *capacityChange) isEmpty() bool {
+	return c.free.Sign() == 0 && c.utilized.Sign() == 0 && len(c.allocated) == 0 && len(c.released) == 0
+}
+
+func (c *capacityChange) reset() {
+	*c = *newCapacityChange()
+}
+
+// capacityMeasurer is implemented by pools measuring capacity of their resources by an allocation strategy
+type capacityMeasurer interface {
+	// measureCapacityChange turns allocated and released resources into a change of free and utilized capacity
+	measureCapacityChange(change *capacityChange) error
+}
+
+// UpdatePoolCapacity adds changes of capacity made by a claim, free, bench or retire to the free and utilized
+// counters of the pool. Only the changed resources are measured, resources of the pool are never loaded.
+// It has to use the same client (transaction) as the change itself so that the counters are committed or
+// rolled back together with it. Utilization thresholds are checked afterwards.
+func UpdatePoolCapacity(pool Pool) error {
+	b, ok := pool.(interface{ base() poolBase })
+	if !ok {
+		return errors.Errorf("Unable to update capacity of pool, unsupported pool %T", pool)
+	}
+	base := b.base()
+	change := base.capacityChange
+	defer change.reset()
+
+	entity, err := base.client.ResourcePool.Get(base.ctx, base.ID)
+	if err != nil {
+		log.Error(base.ctx, err, "Unable to load pool ID %d", base.ID)
+		return errors.Wrapf(err, "Unable to update capacity of pool #%d", base.ID)
+	}
+	if entity.FreeCapacity == nil || entity.UtilizedCapacity == nil {
+		// counters of pools which were never updated are computed once, the change is already included
+		if _, err := StoredPoolCapacity(base.ctx, base.client, entity); err != nil {
+			return err
+		}
+		CheckPoolUtilization(pool)
+		return nil
+	}
+	if change.isEmpty() {
+		CheckPoolUtilization(pool)
+		return nil
+	}
+
+	if measurer, ok := pool.(capacityMeasurer); ok {
+		if err := measurer.measureCapacityChange(change); err != nil {
+			log.Error(base.ctx, err, "Unable to measure capacity change of pool ID %d", base.ID)
+			return errors.Wrapf(err, "Unable to update capacity of pool #%d", base.ID)
+		}
+	}
+	capacity, err := NewPoolCapacity(*entity.FreeCapacity, *entity.UtilizedCapacity)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to update capacity of pool #%d, invalid stored capacity", base.ID)
+	}
+	capacity.Free.Add(capacity.Free, change.free)
+	capacity.Utilized.Add(capacity.Utilized, change.utilized)
+	if capacity.Free.Sign() < 0 || capacity.Utilized.Sign() < 0 {
+		log.Warn(base.ctx, "Capacity counters of pool ID %d are out of sync, recomputing", base.ID)
+		_, err := recomputePoolCapacity(pool)
+		return err
+	}
+	if err := storePoolCapacity(base.ctx, base.client, base.ID, capacity); err != nil {
+		return err
+	}
+	CheckPoolUtilization(pool)
+	return nil
+}
+
+// recomputePoolCapacity computes capacity of the pool from all its resources and overwrites the stored counters.
+// Only needed when the total capacity changes, e.g. after pool properties or the strategy revision changed.
+func recomputePoolCapacity(pool Pool) (*PoolCapacity, error) {
+	b, ok := pool.(interface{ base() poolBase })
+	if !ok {
+		return nil, errors.Errorf("Unable to update capacity of pool, unsupported pool %T", pool)
+	}
+	base := b.base()
+	base.capacityChange.reset()
+
+	capacity, err := pool.Capacity()
+	if err != nil {
+		log.Error(base.ctx, err, "Unable to compute capacity of pool ID %d", base.ID)
+		return nil, errors.Wrapf(err, "Unable to compute capacity of pool #%d", base.ID)
+	}
+	if err := storePoolCapacity(base.ctx, base.client, base.ID, capacity); err != nil {
+		return nil, err
+	}
+	CheckPoolUtilization(pool)
+	return capacity, nil
+}
+
+// StoredPoolCapacity returns capacity of a pool from its stored counters without loading any resources.
+// Counters of pools which were never updated are computed and stored.
+func StoredPoolCapacity(ctx context.Context, client *ent.Client, entity *ent.ResourcePool) (*PoolCapacity, error) {
+	if entity.FreeCapacity != nil && entity.UtilizedCapacity != nil {
+		capacity, err := NewPoolCapacity(*entity.FreeCapacity, *entity.UtilizedCapacity)
+		if err == nil {
+			return capacity, nil
+		}
+		log.Warn(ctx, "Invalid stored capacity of pool ID %d, recomputing: %v", entity.ID, err)
+	}
+
+	pool, err := existingPool(ctx, client, entity)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := pool.Capacity()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to compute capacity of pool #%d", entity.ID)
+	}
+	return capacity, storePoolCapacity(ctx, client, entity.ID, capacity)
+}
+
+// RecomputePoolCapacity computes capacity of a pool from its resources and overwrites the stored counters.
+// Used to repair counters which got out of sync, e.g. after resources were changed directly in the database.
+func RecomputePoolCapacity(ctx context.Context, client *ent.Client, poolId int) (*PoolCapacity, error) {
+	pool, err := ExistingPoolFromId(ctx, client, poolId)
+	if err != nil {
+		return nil, err
+	}
+	return recomputePoolCapacity(pool)
+}
+
+func storePoolCapacity(ctx context.Context, client *ent.Client, poolId int, capacity *PoolCapacity) error {
+	err := client.ResourcePool.UpdateOneID(poolId).
+		SetFreeCapacity(capacity.Free.String()).
+		SetUtilizedCapacity(capacity.Utilized.String()).
+		Exec(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to store capacity of pool ID %d", poolId)
+		return errors.Wrapf(err, "Unable to store capacity of pool #%d", poolId)
+	}
+	return nil
+}
+
+func parseCapacityValue(value interface{}) (*big.Int, error) {
+	var parsed *big.Int
+	switch v := value.(type) {
+	case nil:
+		parsed = big.NewInt(0)
+	case int:
+		parsed = big.NewInt(int64(v))
+	case int64:
+		parsed = big.NewInt(v)
+	case float64:
+		return parseCapacityValue(fmt.Sprintf("%f", v))
+	case json.Number:
+		return parseCapacityValue(v.String())
+	case *big.Int:
+		parsed = new(big.Int).Set(v)
+	case string:
+		text := strings.TrimSpace(v)
+		var ok bool
+		if parsed, ok = new(big.Int).SetString(text, 10); !ok {
+			// float text such as "4096.0" or "1e+21" produced by JS numbers and float formatting
+			floatValue, _, err := big.ParseFloat(text, 10, 256, big.ToNearestEven)
+			if err != nil || floatValue.IsInf() {
+				return nil, errors.Errorf("Unable to parse capacity \"%s\"", v)
+			}
+			parsed, _ = floatValue.Int(nil)
+		}
+	default:
+		return nil, errors.Errorf("Unsupported capacity type %T", value)
+	}
+
+	if parsed.Sign() < 0 {
+		return nil, errors.Errorf("Capacity cannot be negative, got %s", parsed)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,198 @@
+package pools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestNewPoolCapacity(t *testing.T) {
+	for _, value := range []interface{}{"4096", 4096, int64(4096), 4096.0, "4096.0", " 4096 ", json.Number("4096"), "4.096e+03"} {
+		capacity, err := NewPoolCapacity(value, "0")
+		if err != nil {
+			t.Fatalf("Unable to parse capacity %v: %s", value, err)
+		}
+		if capacity.Free.String() != "4096" {
+			t.Fatalf("Capacity %v should be normalized to 4096, got %s", value, capacity.Free)
+		}
+	}
+
+	// ipv6 /32 pool with a single /64 allocated
+	capacity, err := NewPoolCapacity("79228162495817593519834398720", "18446744073709551616")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.Total().String() != "79228162514264337593543950336" {
+		t.Fatalf("Total capacity should be exact, got %s", capacity.Total())
+	}
+	if percentage := capacity.UtilizationPercentage(); percentage < 2.3283064e-8 || percentage > 2.3283065e-8 {
+		t.Fatalf("Unexpected utilization percentage %v", percentage)
+	}
+
+	if capacity, _ := NewPoolCapacity(nil, nil); capacity.UtilizationPercentage() != 100 {
+		t.Fatalf("Empty pool should be fully utilized")
+	}
+	for _, value := range []interface{}{"-1", "abc", true} {
+		if _, err := NewPoolCapacity(value, "0"); err == nil {
+			t.Fatalf("Capacity %v should be rejected", value)
+		}
+	}
+}
+
+func TestStoredPoolCapacity(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, poolEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+		RawResourceProps{"vlan": 46},
+		RawResourceProps{"vlan": 47},
+	}, "pool", nil, schema.ResourcePoolDealocationImmediately)
+
+	// counters are computed on first read
+	assertStoredCapacity(ctx, t, client, poolEntity.ID, "4", "0")
+
+	claimed, err := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, poolEntity.ID, "3", "1")
+
+	if err := pool.FreeResources(nil, []int{claimed.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, poolEntity.ID, "4", "0")
+
+	// counters out of sync are served as they are until recomputed
+	client.ResourcePool.UpdateOneID(poolEntity.ID).SetFreeCapacity("1").SetUtilizedCapacity("7").ExecX(ctx)
+	assertStoredCapacity(ctx, t, client, poolEntity.ID, "1", "7")
+	if _, err := RecomputePoolCapacity(ctx, client, poolEntity.ID); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, poolEntity.ID, "4", "0")
+
+	singleton, singletonEntity, _ := NewSingletonPoolWithMeta(ctx, client, resType,
+		map[string]interface{}{"vlan": 1}, "singleton", nil)
+	if _, err := singleton.ClaimResource(map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(singleton); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, singletonEntity.ID, "0", "1")
+}
+
+func TestPoolCapacityChanges(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	setPool, setEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 1},
+		RawResourceProps{"vlan": 2},
+		RawResourceProps{"vlan": 3},
+	}, "set", nil, schema.ResourcePoolDealocationRetire)
+	assertStoredCapacity(ctx, t, client, setEntity.ID, "3", "0")
+
+	// claims and frees only change the stored counters, resources of the pool are not counted again
+	client.ResourcePool.UpdateOneID(setEntity.ID).SetFreeCapacity("100").ExecX(ctx)
+	claimed, err := setPool.ClaimResources(2, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(setPool); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, setEntity.ID, "98", "2")
+	if err := setPool.FreeResources(nil, []int{claimed[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(setPool); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, setEntity.ID, "99", "1")
+	if _, err := RecomputePoolCapacity(ctx, client, setEntity.ID); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, setEntity.ID, "2", "1")
+
+	fromType := client.PropertyType.Create().SetName("from").SetType("int").SetMandatory(true).SaveX(ctx)
+	toType := client.PropertyType.Create().SetName("to").SetType("int").SetMandatory(true).SaveX(ctx)
+	propsType := client.ResourceType.Create().SetName("vlanPool-ResourceType").
+		AddPropertyTypes(fromType, toType).SaveX(ctx)
+	poolProperties, err := CreatePoolProperties(ctx, client,
+		[]map[string]interface{}{{"from": 1, "to": 10}}, propsType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strat := client.AllocationStrategy.Create().
+		SetName("vlan").
+		SetLang(allocationstrategy.LangGo).
+		SetScript("vlan").
+		SaveX(ctx)
+	allocatingPool, allocatingEntity, err := NewAllocatingPoolWithMeta(ctx, client, resType, strat, "vlanPool", nil,
+		schema.ResourcePoolDealocationImmediately, poolProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, allocatingEntity.ID, "10", "0")
+
+	// capacity of claimed and freed resources is measured by the strategy
+	if _, err := allocatingPool.ClaimResources(3, map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(allocatingPool); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, allocatingEntity.ID, "7", "3")
+	if err := allocatingPool.FreeResource(RawResourceProps{"vlan": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(allocatingPool); err != nil {
+		t.Fatal(err)
+	}
+	assertStoredCapacity(ctx, t, client, allocatingEntity.ID, "8", "2")
+	capacity, err := allocatingPool.Capacity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.Free.String() != "8" || capacity.Utilized.String() != "2" {
+		t.Fatalf("Stored capacity should match computed capacity, got %s and %s", capacity.Free, capacity.Utilized)
+	}
+}
+
+func assertStoredCapacity(ctx context.Context, t *testing.T, client *ent.Client, poolId int, free string, utilized string) {
+	capacity, err := StoredPoolCapacity(ctx, client, client.ResourcePool.GetX(ctx, poolId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity.Free.String() != free || capacity.Utilized.String() != utilized {
+		t.Fatalf("Expected free %s and utilized %s capacity, got %s and %s", free, utilized, capacity.Free, capacity.Utilized)
+	}
+	if stored := client.ResourcePool.GetX(ctx, poolId); stored.FreeCapacity == nil || stored.UtilizedCapacity == nil {
+		t.Fatalf("Capacity counters of pool #%d should be stored", poolId)
+	}
+}
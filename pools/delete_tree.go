@@ -0,0 +1,127 @@
+package pools
+
+import (
+	"context"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/property"
+	"github.com/net-auto/resourceManager/ent/resource"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// PoolTreeDeletion lists pools and claimed resources removed by deleting a pool tree.
+// Pools are ordered bottom-up, nested pools always precede the pool they are nested in.
+type PoolTreeDeletion struct {
+	Pools []*ent.ResourcePool
+	// ParentPoolIDs maps IDs of nested pools to IDs of pools they are nested in
+	ParentPoolIDs map[int]int
+	// ClaimedResources are loaded with their pool and properties
+	ClaimedResources []*ent.Resource
+}
+
+// DeleteResourcePoolTree deletes a pool together with all pools nested in it, including their claimed resources.
+// A dry run only reports what would be deleted. Deletion is all or nothing, it has to run in a single transaction.
+func DeleteResourcePoolTree(ctx context.Context, client *ent.Client, poolId int, dryRun bool) (*PoolTreeDeletion, error) {
+	root, err := client.ResourcePool.Get(ctx, poolId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find pool #%d", poolId)
+	}
+
+	deletion := &PoolTreeDeletion{ParentPoolIDs: map[int]int{}}
+	if err := collectPoolTree(ctx, client, root, deletion, map[int]bool{}); err != nil {
+		return nil, err
+	}
+	for _, pool := range deletion.Pools {
+		if pool.LifecycleState == resourcePool.LifecycleStateFrozen {
+			return nil, errors.Errorf("Unable to delete pool tree of pool \"%s\", nested pool \"%s\" is frozen",
+				root.Name, pool.Name)
+		}
+	}
+	if dryRun {
+		return deletion, nil
+	}
+
+	for _, pool := range deletion.Pools {
+		if err := destroyPoolWithResources(ctx, client, pool); err != nil {
+			return nil, errors.Wrapf(err, "Unable to delete pool tree of pool \"%s\"", root.Name)
+		}
+	}
+	log.Info(ctx, "Deleted pool tree of pool \"%s\" (ID %d): %d pools, %d claimed resources",
+		root.Name, root.ID, len(deletion.Pools), len(deletion.ClaimedResources))
+	return deletion, nil
+}
+
+// collectPoolTree adds claimed resources and nested pools of a pool depth first, the pool itself goes last
+func collectPoolTree(ctx context.Context, client *ent.Client, pool *ent.ResourcePool,
+	deletion *PoolTreeDeletion, visited map[int]bool) error {
+	if visited[pool.ID] {
+		return errors.Errorf("Unable to delete pool tree, pool #%d is nested in itself", pool.ID)
+	}
+	visited[pool.ID] = true
+
+	nestedPools, err := client.ResourcePool.Query().
+		Where(resourcePool.HasParentResourceWith(resource.HasPoolWith(resourcePool.ID(pool.ID)))).
+		Order(ent.Asc(resourcePool.FieldID)).
+		All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to load nested pools of pool ID %d", pool.ID)
+		return errors.Wrapf(err, "Unable to load nested pools of pool #%d", pool.ID)
+	}
+	for _, nestedPool := range nestedPools {
+		deletion.ParentPoolIDs[nestedPool.ID] = pool.ID
+		if err := collectPoolTree(ctx, client, nestedPool, deletion, visited); err != nil {
+			return err
+		}
+	}
+
+	claimed, err := client.Resource.Query().
+		Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).
+		Where(resource.StatusIn(resource.StatusClaimed, resource.StatusReserved)).
+		WithPool().
+		WithProperties(func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).
+		Order(ent.Asc(resource.FieldID)).
+		All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to load claimed resources of pool ID %d", pool.ID)
+		return errors.Wrapf(err, "Unable to load claimed resources of pool #%d", pool.ID)
+	}
+	deletion.ClaimedResources = append(deletion.ClaimedResources, claimed...)
+	deletion.Pools = append(deletion.Pools, pool)
+	return nil
+}
+
+// destroyPoolWithResources deletes a pool regardless of its claims, nested pools have to be deleted beforehand
+func destroyPoolWithResources(ctx context.Context, client *ent.Client, pool *ent.ResourcePool) error {
+	resourceIds, err := client.Resource.Query().Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).IDs(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to load resources of pool ID %d", pool.ID)
+		return errors.Wrapf(err, "Unable to load resources of pool \"%s\"", pool.Name)
+	}
+
+	if len(resourceIds) > 0 {
+		if _, err := client.Property.Delete().
+			Where(property.HasResourcesWith(resource.IDIn(resourceIds...))).
+			Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to delete resource properties of pool ID %d", pool.ID)
+			return errors.Wrapf(err, "Unable to delete resource properties of pool \"%s\"", pool.Name)
+		}
+		if _, err := client.Resource.Delete().Where(resource.IDIn(resourceIds...)).Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to delete resources of pool ID %d", pool.ID)
+			return errors.Wrapf(err, "Unable to delete resources of pool \"%s\"", pool.Name)
+		}
+	}
+
+	if pool.PoolType == resourcePool.PoolTypeAllocating {
+		if err := DeletePoolProperties(ctx, client, pool.ID); err != nil {
+			return errors.Wrapf(err, "Unable to delete pool properties of pool \"%s\"", pool.Name)
+		}
+	}
+
+	if err := client.ResourcePool.DeleteOneID(pool.ID).Exec(ctx); err != nil {
+		log.Error(ctx, err, "Unable to delete pool ID %d", pool.ID)
+		return errors.Wrapf(err, "Unable to delete pool \"%s\"", pool.Name)
+	}
+	return nil
+}
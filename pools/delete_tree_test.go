@@ -0,0 +1,80 @@
+package pools
+
+import (
+	"testing"
+
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestDeleteResourcePoolTree(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	region, regionEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 1},
+		RawResourceProps{"vlan": 2},
+		RawResourceProps{"vlan": 3},
+	}, "region", nil, schema.ResourcePoolDealocationImmediately)
+	carved, err := region.ClaimResources(2, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site, siteEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 10},
+		RawResourceProps{"vlan": 11},
+	}, "site", nil, schema.ResourcePoolDealocationImmediately)
+	client.ResourcePool.UpdateOne(siteEntity).SetParentResource(carved[0]).ExecX(ctx)
+	rackParent, err := site.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, rackEntity, _ := NewSingletonPoolWithMeta(ctx, client, resType, RawResourceProps{"vlan": 100}, "rack", nil)
+	client.ResourcePool.UpdateOne(rackEntity).SetParentResource(rackParent).ExecX(ctx)
+
+	// unrelated pool is kept
+	NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 1},
+	}, "other", nil, schema.ResourcePoolDealocationImmediately)
+
+	deletion, err := DeleteResourcePoolTree(ctx, client, regionEntity.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deletion.Pools) != 3 || deletion.Pools[0].ID != rackEntity.ID || deletion.Pools[2].ID != regionEntity.ID {
+		t.Fatalf("Expected rack, site and region to be deleted bottom-up, got %v", deletion.Pools)
+	}
+	if deletion.ParentPoolIDs[rackEntity.ID] != siteEntity.ID || deletion.ParentPoolIDs[siteEntity.ID] != regionEntity.ID {
+		t.Fatalf("Unexpected parent pools %v", deletion.ParentPoolIDs)
+	}
+	if len(deletion.ClaimedResources) != 3 {
+		t.Fatalf("Expected 3 claimed resources to be deleted, got %d", len(deletion.ClaimedResources))
+	}
+	if client.ResourcePool.Query().CountX(ctx) != 4 {
+		t.Fatalf("Dry run should not delete any pool")
+	}
+
+	client.ResourcePool.UpdateOne(rackEntity).SetLifecycleState(resourcePool.LifecycleStateFrozen).ExecX(ctx)
+	if _, err := DeleteResourcePoolTree(ctx, client, regionEntity.ID, false); err == nil {
+		t.Fatalf("Pool tree with a frozen pool should not be deleted")
+	}
+	client.ResourcePool.UpdateOne(rackEntity).SetLifecycleState(resourcePool.LifecycleStateActive).ExecX(ctx)
+
+	if _, err := DeleteResourcePoolTree(ctx, client, regionEntity.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := client.ResourcePool.Query().AllX(ctx); len(remaining) != 1 || remaining[0].Name != "other" {
+		t.Fatalf("Only unrelated pool should remain, got %v", remaining)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 0, 0, 0)
+}
@@ -0,0 +1,48 @@
+package pools
+
+import (
+	"context"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	log "github.com/net-auto/resourceManager/logging"
+	strategies "github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/generated"
+	"github.com/pkg/errors"
+)
+
+// QueryFreeRanges lists free ranges of values left in an allocating pool, showing its fragmentation
+func QueryFreeRanges(ctx context.Context, client *ent.Client, poolId int) (*strategies.FreeRanges, error) {
+	pool, err := ExistingPoolFromId(ctx, client, poolId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find pool #%d", poolId)
+	}
+	allocatingPool, ok := pool.(*AllocatingPool)
+	if !ok {
+		return nil, errors.Errorf("Unable to list free ranges of pool #%d, only allocating pools have ranges", poolId)
+	}
+	return allocatingPool.FreeRanges()
+}
+
+// FreeRanges lists free ranges left in the pool using the optional free ranges function of its strategy
+func (pool AllocatingPool) FreeRanges() (*strategies.FreeRanges, error) {
+	strat, propMap, _, err := pool.loadStrategyInput()
+	if err != nil {
+		return nil, err
+	}
+
+	currentResources, err := getFullListOfResources(pool)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to load resources for pool %d", pool.ID)
+		return nil, errors.Wrapf(err, "Unable to load resources for pool #%d, resource loading error", pool.ID)
+	}
+
+	freeRanges, err := ListFreeRanges(pool.ctx, strat, model.ResourcePoolInput{
+		ResourcePoolID:   pool.ID,
+		ResourcePoolName: pool.Name,
+	}, currentResources, propMap)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to list free ranges of pool %d", pool.ID)
+		return nil, errors.Wrapf(err, "Unable to list free ranges of pool #%d", pool.ID)
+	}
+	return freeRanges, nil
+}
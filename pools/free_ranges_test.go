@@ -0,0 +1,91 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/schema"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestQueryFreeRanges(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+	fromType := client.PropertyType.Create().SetName("from").SetType("int").SetMandatory(true).SaveX(ctx)
+	toType := client.PropertyType.Create().SetName("to").SetType("int").SetMandatory(true).SaveX(ctx)
+	propsType := client.ResourceType.Create().SetName("vlanPool-ResourceType").
+		AddPropertyTypes(fromType, toType).SaveX(ctx)
+	poolProperties, err := CreatePoolProperties(ctx, client,
+		[]map[string]interface{}{{"from": 10, "to": 20}}, propsType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strat := client.AllocationStrategy.Create().
+		SetName("vlan").
+		SetLang(allocationstrategy.LangGo).
+		SetScript("vlan").
+		SaveX(ctx)
+
+	pool, poolEntity, err := NewAllocatingPoolWithMeta(ctx, client, resType, strat, "vlanPool", nil,
+		schema.ResourcePoolDealocationImmediately, poolProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := pool.ClaimResources(4, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.FreeResource(RawResourceProps{"vlan": 11}); err != nil {
+		t.Fatal(err)
+	}
+
+	freeRanges, err := QueryFreeRanges(ctx, client, poolEntity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(freeRanges.Ranges) != 2 ||
+		freeRanges.Ranges[0].From != "11" || freeRanges.Ranges[0].To != "11" ||
+		freeRanges.Ranges[1].From != "14" || freeRanges.Ranges[1].To != "20" {
+		t.Fatalf("Expected free vlans 11 and 14-20 after claiming %d vlans, got %v", len(claimed), freeRanges.Ranges)
+	}
+	if len(freeRanges.Histogram) != 2 || freeRanges.Histogram[0].Size.Int64() != 7 {
+		t.Fatalf("Expected the largest free range of 7 vlans first, got %v", freeRanges.Histogram)
+	}
+
+	_, setPoolEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 1},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+	if _, err := QueryFreeRanges(ctx, client, setPoolEntity.ID); err == nil {
+		t.Fatalf("Listing free ranges of a set pool should fail")
+	}
+}
+
+func TestListFreeRangesOfJsRevision(t *testing.T) {
+	ctx := getContext()
+	poolProperties := map[string]interface{}{"from": float64(10), "to": float64(20)}
+
+	// js revisions of registered go strategies list free ranges by the go strategy
+	freeRanges, err := ListFreeRanges(ctx, &ent.AllocationStrategy{Name: "vlan", Lang: allocationstrategy.LangJs},
+		model.ResourcePoolInput{PoolProperties: poolProperties}, nil, poolProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(freeRanges.Ranges) != 1 || freeRanges.Ranges[0].From != "10" || freeRanges.Ranges[0].To != "20" {
+		t.Fatalf("Expected free vlans 10-20, got %v", freeRanges.Ranges)
+	}
+
+	if _, err := ListFreeRanges(ctx, &ent.AllocationStrategy{Name: "custom", Lang: allocationstrategy.LangJs},
+		model.ResourcePoolInput{PoolProperties: poolProperties}, nil, poolProperties); err == nil {
+		t.Fatalf("Listing free ranges of an unregistered strategy should fail")
+	}
+}
@@ -0,0 +1,292 @@
+package pools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/propertytype"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	strategies "github.com/net-auto/resourceManager/pools/allocating_strategies/strategies/generated"
+	"github.com/pkg/errors"
+)
+
+// GoStrategyFactory creates a go strategy for a single invocation on a pool
+type GoStrategyFactory func(
+	ctx context.Context,
+	resourcePool model.ResourcePoolInput,
+	currentResources []map[string]interface{},
+	poolProperties map[string]interface{},
+	userInput map[string]interface{},
+) GoStrategy
+
+// GoStrategyProperty is a property type of resources allocated by a go strategy or of pools using it
+type GoStrategyProperty struct {
+	Name string
+	Type propertytype.Type
+	// Default value (int, float64, string or bool) of a pool property, resource properties have none
+	Default interface{}
+}
+
+// GoStrategyDefinition describes a go strategy compiled into the resource manager. LoadBuiltinTypes stores
+// the strategy together with the resource type it allocates for every registered definition.
+type GoStrategyDefinition struct {
+	// Name of the allocation strategy
+	Name string
+	// ResourceType is the name of the resource type allocated by the strategy, Name of the strategy by default
+	ResourceType string
+	// Script stored with the strategy for reference, the strategy itself always runs in go
+	Script                string
+	Factory               GoStrategyFactory
+	ResourcePropertyTypes []GoStrategyProperty
+	PoolPropertyTypes     []GoStrategyProperty
+	// ManualSqlExecution strategies query claimed resources themselves within the transaction exposed
+	// under ent.TxCtxKey{} instead of getting them loaded by the pool
+	ManualSqlExecution bool
+}
+
+var goStrategies = struct {
+	sync.RWMutex
+	definitions []GoStrategyDefinition
+	byName      map[string]int
+}{byName: map[string]int{}}
+
+// RegisterGoStrategy adds a compiled in go strategy. Strategies have to be registered before LoadBuiltinTypes runs,
+// typically from an init function of the package implementing them.
+func RegisterGoStrategy(definition GoStrategyDefinition) error {
+	if definition.Name == "" {
+		return errors.New("Unable to register go strategy without a name")
+	}
+	if definition.Factory == nil {
+		return errors.Errorf("Unable to register go strategy \"%s\" without a factory", definition.Name)
+	}
+	if definition.ResourceType == "" {
+		definition.ResourceType = definition.Name
+	}
+	for _, property := range append(definition.ResourcePropertyTypes, definition.PoolPropertyTypes...) {
+		if err := propertytype.TypeValidator(property.Type); err != nil {
+			return errors.Wrapf(err, "Unable to register go strategy \"%s\", invalid type of property %s",
+				definition.Name, property.Name)
+		}
+	}
+
+	goStrategies.Lock()
+	defer goStrategies.Unlock()
+	if _, exists := goStrategies.byName[definition.Name]; exists {
+		return errors.Errorf("Go strategy \"%s\" is already registered", definition.Name)
+	}
+	goStrategies.byName[definition.Name] = len(goStrategies.definitions)
+	goStrategies.definitions = append(goStrategies.definitions, definition)
+	return nil
+}
+
+// GoStrategyDefinitions lists registered go strategies in the order of registration
+func GoStrategyDefinitions() []GoStrategyDefinition {
+	goStrategies.RLock()
+	defer goStrategies.RUnlock()
+	return append([]GoStrategyDefinition{}, goStrategies.definitions...)
+}
+
+// IsGoStrategyRegistered tells whether a go strategy of the name is compiled in
+func IsGoStrategyRegistered(name string) bool {
+	_, ok := goStrategyDefinition(name)
+	return ok
+}
+
+func goStrategyDefinition(name string) (GoStrategyDefinition, bool) {
+	goStrategies.RLock()
+	defer goStrategies.RUnlock()
+	index, ok := goStrategies.byName[name]
+	if !ok {
+		return GoStrategyDefinition{}, false
+	}
+	return goStrategies.definitions[index], true
+}
+
+// isManualSqlExecutionStrategy tells whether a strategy loads claimed resources on its own,
+// js revisions of such strategies get claimed resources loaded by the pool
+func isManualSqlExecutionStrategy(strategy *ent.AllocationStrategy) bool {
+	if strategy.Lang != allocationstrategy.LangGo {
+		return false
+	}
+	definition, ok := goStrategyDefinition(strategy.Name)
+	return ok && definition.ManualSqlExecution
+}
+
+func mustRegisterGoStrategy(definition GoStrategyDefinition) {
+	if err := RegisterGoStrategy(definition); err != nil {
+		panic(err)
+	}
+}
+
+func resourceProperty(name string, propertyType propertytype.Type) GoStrategyProperty {
+	return GoStrategyProperty{Name: name, Type: propertyType}
+}
+
+func poolProperty(name string, propertyType propertytype.Type, defaultValue interface{}) GoStrategyProperty {
+	return GoStrategyProperty{Name: name, Type: propertyType, Default: defaultValue}
+}
+
+func init() {
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "ipv4_prefix",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			prefix := strategies.NewIpv4Prefix(currentResources, poolProperties, userInput)
+			return &prefix
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("address", propertytype.TypeString),
+			resourceProperty("prefix", propertytype.TypeInt),
+			resourceProperty("subnet", propertytype.TypeBool),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("address", propertytype.TypeString, "192.168.10.0"),
+			poolProperty("prefix", propertytype.TypeInt, 24),
+			poolProperty("subnet", propertytype.TypeBool, false),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "ipv4",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			ipv4 := strategies.NewIpv4(currentResources, poolProperties, userInput)
+			return &ipv4
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("address", propertytype.TypeString),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("address", propertytype.TypeString, "192.168.10.0"),
+			poolProperty("prefix", propertytype.TypeInt, 24),
+			poolProperty("subnet", propertytype.TypeBool, false),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "vlan_range",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			vlanRange := strategies.NewVlanRange(currentResources, poolProperties, userInput)
+			return &vlanRange
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("from", propertytype.TypeInt),
+			resourceProperty("to", propertytype.TypeInt),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("from", propertytype.TypeInt, 0),
+			poolProperty("to", propertytype.TypeInt, 4095),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "vlan",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			vlan := strategies.NewVlan(currentResources, poolProperties, userInput)
+			return &vlan
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("vlan", propertytype.TypeInt),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("from", propertytype.TypeInt, 0),
+			poolProperty("to", propertytype.TypeInt, 4095),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "ipv6_prefix",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			prefix := strategies.NewIpv6Prefix(currentResources, poolProperties, userInput)
+			return &prefix
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("address", propertytype.TypeString),
+			resourceProperty("prefix", propertytype.TypeInt),
+			resourceProperty("subnet", propertytype.TypeBool),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("address", propertytype.TypeString, "2001:db8::"),
+			poolProperty("prefix", propertytype.TypeInt, 64),
+			poolProperty("subnet", propertytype.TypeBool, false),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "ipv6",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			ipv6 := strategies.NewIpv6(currentResources, poolProperties, userInput)
+			return &ipv6
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("address", propertytype.TypeString),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("address", propertytype.TypeString, "2001:db8::"),
+			poolProperty("prefix", propertytype.TypeInt, 64),
+			poolProperty("subnet", propertytype.TypeBool, false),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "route_distinguisher",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			rd := strategies.NewRouteDistinguisher(currentResources, poolProperties, userInput)
+			return &rd
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("rd", propertytype.TypeString),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "random_signed_int32",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			randomInt := strategies.NewRandomSignedInt32(currentResources, poolProperties, userInput)
+			return &randomInt
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("int", propertytype.TypeInt),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("from", propertytype.TypeInt, -2147483648),
+			poolProperty("to", propertytype.TypeInt, 2147483648),
+		},
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "unique_id",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			id := strategies.NewUniqueId(ctx, resourcePool.ResourcePoolID, poolProperties, userInput)
+			return &id
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("counter", propertytype.TypeInt),
+			resourceProperty("text", propertytype.TypeString),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("from", propertytype.TypeInt, 1),
+			poolProperty("to", propertytype.TypeInt, 4094),
+		},
+		ManualSqlExecution: true,
+	})
+	mustRegisterGoStrategy(GoStrategyDefinition{
+		Name: "mac_address",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			mac := strategies.NewMacAddress(currentResources, poolProperties, userInput)
+			return &mac
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{
+			resourceProperty("address", propertytype.TypeString),
+		},
+		PoolPropertyTypes: []GoStrategyProperty{
+			poolProperty("prefix", propertytype.TypeString, "02:00:00"),
+			poolProperty("from", propertytype.TypeInt, 0),
+			poolProperty("to", propertytype.TypeInt, 16777215),
+			poolProperty("locally_administered", propertytype.TypeBool, true),
+			poolProperty("unicast", propertytype.TypeBool, true),
+		},
+	})
+}
@@ -0,0 +1,76 @@
+package pools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/propertytype"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+)
+
+type constantStrategy struct {
+	value float64
+}
+
+func (c *constantStrategy) Invoke() (map[string]interface{}, error) {
+	return map[string]interface{}{"value": c.value}, nil
+}
+
+func (c *constantStrategy) Capacity() (map[string]interface{}, error) {
+	return map[string]interface{}{"freeCapacity": "1", "utilizedCapacity": "0"}, nil
+}
+
+func TestRegisterGoStrategy(t *testing.T) {
+	definition := GoStrategyDefinition{
+		Name: "test_constant",
+		Factory: func(ctx context.Context, resourcePool model.ResourcePoolInput, currentResources []map[string]interface{},
+			poolProperties map[string]interface{}, userInput map[string]interface{}) GoStrategy {
+			return &constantStrategy{value: poolProperties["value"].(float64)}
+		},
+		ResourcePropertyTypes: []GoStrategyProperty{{Name: "value", Type: propertytype.TypeFloat}},
+	}
+	if !IsGoStrategyRegistered(definition.Name) {
+		if err := RegisterGoStrategy(definition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RegisterGoStrategy(definition); err == nil {
+		t.Fatalf("Registering a go strategy twice should fail")
+	}
+	if err := RegisterGoStrategy(GoStrategyDefinition{Name: "test_without_factory"}); err == nil {
+		t.Fatalf("Registering a go strategy without a factory should fail")
+	}
+	if err := RegisterGoStrategy(GoStrategyDefinition{Name: "test_invalid_property", Factory: definition.Factory,
+		PoolPropertyTypes: []GoStrategyProperty{{Name: "value", Type: "unknown"}}}); err == nil {
+		t.Fatalf("Registering a go strategy with an invalid property type should fail")
+	}
+	if !IsGoStrategyRegistered("vlan") || !IsGoStrategyRegistered("test_constant") || IsGoStrategyRegistered("test_without_factory") {
+		t.Fatalf("Expected builtin and test strategies registered")
+	}
+	if registered, _ := goStrategyDefinition("test_constant"); registered.ResourceType != "test_constant" {
+		t.Fatalf("Expected resource type named after the strategy, got %s", registered.ResourceType)
+	}
+	if !isManualSqlExecutionStrategy(&ent.AllocationStrategy{Name: "unique_id", Lang: allocationstrategy.LangGo}) ||
+		isManualSqlExecutionStrategy(&ent.AllocationStrategy{Name: "unique_id", Lang: allocationstrategy.LangJs}) ||
+		isManualSqlExecutionStrategy(&ent.AllocationStrategy{Name: "test_constant", Lang: allocationstrategy.LangGo}) ||
+		isManualSqlExecutionStrategy(&ent.AllocationStrategy{Name: "test_without_factory", Lang: allocationstrategy.LangGo}) {
+		t.Fatalf("Expected only the go unique_id strategy to execute its own SQL")
+	}
+
+	ctx := getContext()
+	pool := model.ResourcePoolInput{PoolProperties: map[string]interface{}{"value": float64(7)}}
+	output, _, err := InvokeAllocationStrategy(ctx, nil,
+		&ent.AllocationStrategy{Name: "test_constant", Lang: allocationstrategy.LangGo},
+		map[string]interface{}{}, pool, nil, pool.PoolProperties, "invoke()")
+	if err != nil || output["value"] != float64(7) {
+		t.Fatalf("Expected registered strategy invoked, got %v %v", output, err)
+	}
+
+	if _, _, err := InvokeAllocationStrategy(ctx, nil,
+		&ent.AllocationStrategy{Name: "custom_vlan", Lang: allocationstrategy.LangGo},
+		map[string]interface{}{}, pool, nil, pool.PoolProperties, "invoke()"); err == nil {
+		t.Fatalf("Invoking an unregistered go strategy should fail")
+	}
+}
@@ -0,0 +1,145 @@
+package pools
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resource"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// PoolNodeCapacity is capacity of a single pool within a pool hierarchy
+type PoolNodeCapacity struct {
+	Pool         *ent.ResourcePool
+	ParentPoolID *int
+	Depth        int
+	Capacity     *PoolCapacity
+	// CarvedOut is the part of utilized capacity backing nested pools
+	CarvedOut *big.Int
+	// DirectlyClaimed is the part of utilized capacity claimed by consumers of the pool
+	DirectlyClaimed *big.Int
+}
+
+// PoolLevelCapacity sums capacity of all pools at the same depth of a pool hierarchy
+type PoolLevelCapacity struct {
+	Depth           int
+	PoolCount       int
+	Capacity        *PoolCapacity
+	CarvedOut       *big.Int
+	DirectlyClaimed *big.Int
+}
+
+// PoolTreeCapacity is capacity of a pool together with all pools nested in it.
+// Carved out capacity of every pool is replaced by capacity of its nested pools, so that it is not counted twice.
+type PoolTreeCapacity struct {
+	Pool     *ent.ResourcePool
+	Capacity *PoolCapacity
+	Levels   []*PoolLevelCapacity
+	Pools    []*PoolNodeCapacity
+}
+
+// QueryPoolTreeCapacity walks nested pools of a pool recursively and aggregates their capacity
+func QueryPoolTreeCapacity(ctx context.Context, client *ent.Client, poolId int) (*PoolTreeCapacity, error) {
+	root, err := client.ResourcePool.Get(ctx, poolId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find pool #%d", poolId)
+	}
+
+	tree := &PoolTreeCapacity{Pool: root}
+	visited := map[int]bool{}
+	if tree.Capacity, err = collectPoolTreeCapacity(ctx, client, root, nil, 0, tree, visited); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// collectPoolTreeCapacity adds a pool and its nested pools into the tree and returns their aggregated capacity
+func collectPoolTreeCapacity(ctx context.Context, client *ent.Client, entity *ent.ResourcePool,
+	parentPoolId *int, depth int, tree *PoolTreeCapacity, visited map[int]bool) (*PoolCapacity, error) {
+	if visited[entity.ID] {
+		return nil, errors.Errorf("Unable to compute capacity of pool hierarchy, pool #%d is nested in itself", entity.ID)
+	}
+	visited[entity.ID] = true
+
+	node, err := poolNodeCapacity(ctx, client, entity)
+	if err != nil {
+		return nil, err
+	}
+	node.ParentPoolID = parentPoolId
+	node.Depth = depth
+	tree.Pools = append(tree.Pools, node)
+	tree.addToLevel(node)
+
+	aggregated := &PoolCapacity{
+		Free:     new(big.Int).Set(node.Capacity.Free),
+		Utilized: new(big.Int).Set(node.DirectlyClaimed),
+	}
+
+	nestedPools, err := client.ResourcePool.Query().
+		Where(resourcePool.HasParentResourceWith(resource.HasPoolWith(resourcePool.ID(entity.ID)))).
+		Order(ent.Asc(resourcePool.FieldID)).
+		All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to load nested pools of pool ID %d", entity.ID)
+		return nil, errors.Wrapf(err, "Unable to load nested pools of pool #%d", entity.ID)
+	}
+	for _, nestedPool := range nestedPools {
+		nested, err := collectPoolTreeCapacity(ctx, client, nestedPool, &entity.ID, depth+1, tree, visited)
+		if err != nil {
+			return nil, err
+		}
+		aggregated.Free.Add(aggregated.Free, nested.Free)
+		aggregated.Utilized.Add(aggregated.Utilized, nested.Utilized)
+	}
+	return aggregated, nil
+}
+
+func poolNodeCapacity(ctx context.Context, client *ent.Client, entity *ent.ResourcePool) (*PoolNodeCapacity, error) {
+	capacity, err := StoredPoolCapacity(ctx, client, entity)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := existingPool(ctx, client, entity)
+	if err != nil {
+		return nil, err
+	}
+	carver, ok := pool.(interface{ carvedOutCapacity() (*big.Int, error) })
+	if !ok {
+		return nil, errors.Errorf("Unable to compute carved out capacity of pool #%d, unsupported pool %T", entity.ID, pool)
+	}
+	carvedOut, err := carver.carvedOutCapacity()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to compute carved out capacity of pool #%d", entity.ID)
+	}
+
+	// stored counters might lag behind, never report more carved out than utilized capacity
+	if carvedOut.Cmp(capacity.Utilized) > 0 {
+		carvedOut = new(big.Int).Set(capacity.Utilized)
+	}
+	return &PoolNodeCapacity{
+		Pool:            entity,
+		Capacity:        capacity,
+		CarvedOut:       carvedOut,
+		DirectlyClaimed: new(big.Int).Sub(capacity.Utilized, carvedOut),
+	}, nil
+}
+
+func (tree *PoolTreeCapacity) addToLevel(node *PoolNodeCapacity) {
+	for len(tree.Levels) <= node.Depth {
+		tree.Levels = append(tree.Levels, &PoolLevelCapacity{
+			Depth:           len(tree.Levels),
+			Capacity:        newPoolCapacityFromInts(0, 0),
+			CarvedOut:       big.NewInt(0),
+			DirectlyClaimed: big.NewInt(0),
+		})
+	}
+	level := tree.Levels[node.Depth]
+	level.PoolCount++
+	level.Capacity.Free.Add(level.Capacity.Free, node.Capacity.Free)
+	level.Capacity.Utilized.Add(level.Capacity.Utilized, node.Capacity.Utilized)
+	level.CarvedOut.Add(level.CarvedOut, node.CarvedOut)
+	level.DirectlyClaimed.Add(level.DirectlyClaimed, node.DirectlyClaimed)
+}
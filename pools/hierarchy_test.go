@@ -0,0 +1,73 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestQueryPoolTreeCapacity(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	region, regionEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 1},
+		RawResourceProps{"vlan": 2},
+		RawResourceProps{"vlan": 3},
+		RawResourceProps{"vlan": 4},
+	}, "region", nil, schema.ResourcePoolDealocationImmediately)
+	carved, err := region.ClaimResources(2, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := region.ClaimResource(map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, values := range [][]RawResourceProps{
+		{RawResourceProps{"vlan": 10}, RawResourceProps{"vlan": 11}},
+		{RawResourceProps{"vlan": 20}, RawResourceProps{"vlan": 21}, RawResourceProps{"vlan": 22}},
+	} {
+		site, siteEntity, _ := NewSetPoolWithMeta(ctx, client, resType, values,
+			[]string{"site1", "site2"}[i], nil, schema.ResourcePoolDealocationImmediately)
+		client.ResourcePool.UpdateOne(siteEntity).SetParentResource(carved[i]).ExecX(ctx)
+		if _, err := site.ClaimResource(map[string]interface{}{}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tree, err := QueryPoolTreeCapacity(ctx, client, regionEntity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// region: 1 free, 1 directly claimed, sites: 1 + 2 free, 1 + 1 claimed
+	if tree.Capacity.Free.Int64() != 4 || tree.Capacity.Utilized.Int64() != 3 {
+		t.Fatalf("Expected 4 free and 3 utilized in hierarchy, got %s and %s", tree.Capacity.Free, tree.Capacity.Utilized)
+	}
+	if len(tree.Pools) != 3 || len(tree.Levels) != 2 {
+		t.Fatalf("Expected 3 pools on 2 levels, got %d pools on %d levels", len(tree.Pools), len(tree.Levels))
+	}
+
+	root := tree.Pools[0]
+	if root.Pool.ID != regionEntity.ID || root.ParentPoolID != nil ||
+		root.CarvedOut.Int64() != 2 || root.DirectlyClaimed.Int64() != 1 {
+		t.Fatalf("Expected 2 carved out and 1 directly claimed in region, got %v", root)
+	}
+	if *tree.Pools[1].ParentPoolID != regionEntity.ID || tree.Pools[1].Depth != 1 {
+		t.Fatalf("Site should be nested in region, got %v", tree.Pools[1])
+	}
+
+	sites := tree.Levels[1]
+	if sites.PoolCount != 2 || sites.Capacity.Free.Int64() != 3 || sites.Capacity.Utilized.Int64() != 2 ||
+		sites.CarvedOut.Int64() != 0 || sites.DirectlyClaimed.Int64() != 2 {
+		t.Fatalf("Unexpected capacity of site level %v", sites)
+	}
+}
@@ -0,0 +1,141 @@
+package pools
+
+import (
+	"context"
+	"time"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resource"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// SetResourceLease limits how long a claimed resource stays claimed, nil leaseSeconds removes the lease
+func SetResourceLease(ctx context.Context, client *ent.Client, res *ent.Resource, leaseSeconds *int) (*ent.Resource, error) {
+	if leaseSeconds == nil {
+		if res.LeaseExpiresAt == nil {
+			return res, nil
+		}
+		return client.Resource.UpdateOne(res).ClearLeaseExpiresAt().Save(ctx)
+	}
+
+	if *leaseSeconds <= 0 {
+		return nil, errors.Errorf("Unable to lease resource #%d, lease must be positive, got %d seconds", res.ID, *leaseSeconds)
+	}
+
+	expiresAt := time.Now().Add(time.Duration(*leaseSeconds) * time.Second)
+	updated, err := client.Resource.UpdateOne(res).SetLeaseExpiresAt(expiresAt).Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to set lease on resource ID %d", res.ID)
+		return nil, errors.Wrapf(err, "Unable to lease resource #%d", res.ID)
+	}
+	return updated, nil
+}
+
+// RenewResourceLease extends the lease of a claimed resource by leaseSeconds from now
+func RenewResourceLease(ctx context.Context, client *ent.Client, resourceId int, leaseSeconds int) (*ent.Resource, error) {
+	res, err := client.Resource.Get(ctx, resourceId)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find resource ID %d", resourceId)
+		return nil, errors.Wrapf(err, "Unable to renew lease of resource #%d", resourceId)
+	}
+
+	if res.Status != resource.StatusClaimed {
+		return nil, errors.Errorf("Unable to renew lease of resource #%d, it is not claimed", resourceId)
+	}
+	if res.LeaseExpiresAt == nil {
+		return nil, errors.Errorf("Unable to renew lease of resource #%d, it was claimed without a lease", resourceId)
+	}
+
+	return SetResourceLease(ctx, client, res, &leaseSeconds)
+}
+
+// PoolsWithExpiredLeases returns IDs of pools having claimed resources with an expired lease or expired reservations.
+// Frozen pools are skipped, their resources stay claimed until the pool is unfrozen.
+func PoolsWithExpiredLeases(ctx context.Context, client *ent.Client) ([]int, error) {
+	poolIds, err := client.ResourcePool.Query().
+		Where(resourcePool.LifecycleStateNEQ(resourcePool.LifecycleStateFrozen)).
+		Where(resourcePool.HasClaimsWith(
+			resource.StatusIn(resource.StatusClaimed, resource.StatusReserved),
+			resource.LeaseExpiresAtLT(time.Now()),
+			resource.Not(resource.HasNestedPool()))).
+		IDs(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find pools with expired leases")
+		return nil, errors.Wrapf(err, "Unable to find pools with expired leases")
+	}
+	return poolIds, nil
+}
+
+// FreeExpiredLeases frees claimed resources with an expired lease in a pool using the free logic of the pool.
+// Resources with nested pools are never freed, resources failing to free are skipped so that they do not
+// block the others. Returns the number of freed resources.
+func FreeExpiredLeases(ctx context.Context, client *ent.Client, poolId int) (int, error) {
+	ids, err := client.Resource.Query().
+		Where(resource.HasPoolWith(resourcePool.ID(poolId))).
+		Where(resource.StatusEQ(resource.StatusClaimed)).
+		Where(resource.LeaseExpiresAtLT(time.Now())).
+		Where(resource.Not(resource.HasNestedPool())).
+		IDs(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find resources with expired lease in pool ID %d", poolId)
+		return 0, errors.Wrapf(err, "Unable to find resources with expired lease in pool #%d", poolId)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	pool, err := ExistingPoolFromId(ctx, client, poolId)
+	if err != nil {
+		return 0, err
+	}
+	freed := 0
+	for _, id := range ids {
+		if err := pool.FreeResources(nil, []int{id}); err != nil {
+			log.Error(ctx, err, "Unable to free resource ID %d with expired lease in pool ID %d, skipping", id, poolId)
+			continue
+		}
+		freed++
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		return 0, err
+	}
+	return freed, nil
+}
+
+// CancelExpiredReservations returns reserved resources with an expired ttl back to their pool.
+// Resources with nested pools are never cancelled, reservations failing to cancel are skipped.
+// Returns the number of cancelled reservations.
+func CancelExpiredReservations(ctx context.Context, client *ent.Client, poolId int) (int, error) {
+	ids, err := client.Resource.Query().
+		Where(resource.HasPoolWith(resourcePool.ID(poolId))).
+		Where(resource.StatusEQ(resource.StatusReserved)).
+		Where(resource.LeaseExpiresAtLT(time.Now())).
+		Where(resource.Not(resource.HasNestedPool())).
+		IDs(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find expired reservations in pool ID %d", poolId)
+		return 0, errors.Wrapf(err, "Unable to find expired reservations in pool #%d", poolId)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	pool, err := ExistingPoolFromId(ctx, client, poolId)
+	if err != nil {
+		return 0, err
+	}
+	cancelled := 0
+	for _, id := range ids {
+		if err := pool.CancelReservation(id); err != nil {
+			log.Error(ctx, err, "Unable to cancel expired reservation of resource ID %d, skipping", id)
+			continue
+		}
+		cancelled++
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		return 0, err
+	}
+	return cancelled, nil
+}
@@ -0,0 +1,161 @@
+package pools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/net-auto/resourceManager/ent/resource"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestResourceLease(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "set", nil, 60)
+
+	userInput := make(map[string]interface{})
+	leased, _ := pool.ClaimResource(userInput, nil, nil)
+	notLeased, _ := pool.ClaimResource(userInput, nil, nil)
+
+	if _, err := RenewResourceLease(ctx, client, notLeased.ID, 10); err == nil {
+		t.Fatalf("Renewing lease of resource claimed without lease should return error")
+	}
+
+	zero := 0
+	if _, err := SetResourceLease(ctx, client, leased, &zero); err == nil {
+		t.Fatalf("Non positive lease should return error")
+	}
+
+	leaseSeconds := 1
+	leased, err = SetResourceLease(ctx, client, leased, &leaseSeconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leased.LeaseExpiresAt == nil {
+		t.Fatalf("Lease expiration should be set")
+	}
+
+	if freed, err := FreeExpiredLeases(ctx, client, pool.(*SetPool).ID); err != nil || freed != 0 {
+		t.Fatalf("No lease should have expired yet, freed: %d, error: %v", freed, err)
+	}
+
+	time.Sleep(time.Duration(1100) * time.Millisecond)
+
+	if poolIds, err := PoolsWithExpiredLeases(ctx, client); err != nil || len(poolIds) != 1 || poolIds[0] != pool.(*SetPool).ID {
+		t.Fatalf("Expected pool with expired lease to be found, found: %v, error: %v", poolIds, err)
+	}
+	if freed, err := FreeExpiredLeases(ctx, client, pool.(*SetPool).ID); err != nil || freed != 1 {
+		t.Fatalf("Expected 1 resource with expired lease to be freed, freed: %d, error: %v", freed, err)
+	}
+	// resource goes through the pool's safety period
+	assertDbResourceStates(ctx, client, t, 0, 1, 1, 0)
+
+	benched := client.Resource.GetX(ctx, leased.ID)
+	if benched.LeaseExpiresAt != nil {
+		t.Fatalf("Lease should be removed after resource is freed")
+	}
+
+	if _, err := RenewResourceLease(ctx, client, leased.ID, 10); err == nil {
+		t.Fatalf("Renewing lease of freed resource should return error")
+	}
+}
+
+func TestResourceLeaseRenewal(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+
+	leaseSeconds := 1
+	res, _ := pool.ClaimResource(make(map[string]interface{}), nil, nil)
+	res, err = SetResourceLease(ctx, client, res, &leaseSeconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := RenewResourceLease(ctx, client, res.ID, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed.LeaseExpiresAt.After(*res.LeaseExpiresAt) {
+		t.Fatalf("Renewed lease should expire later than the original one")
+	}
+
+	time.Sleep(time.Duration(1100) * time.Millisecond)
+
+	if freed, err := FreeExpiredLeases(ctx, client, pool.(*SetPool).ID); err != nil || freed != 0 {
+		t.Fatalf("Renewed lease should not expire, freed: %d, error: %v", freed, err)
+	}
+	assertDbResourceStates(ctx, client, t, 0, 1, 0, 0)
+}
+
+func TestExpiredLeasesSkipNestedAndFrozenPools(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, poolEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+	frozen, frozenEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 46},
+	}, "frozen", nil, schema.ResourcePoolDealocationImmediately)
+	_, nestedEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 47},
+	}, "nested", nil, schema.ResourcePoolDealocationImmediately)
+
+	leaseSeconds := 1
+	var leased []int
+	for _, p := range []Pool{pool, pool, frozen} {
+		res, err := p.ClaimResource(make(map[string]interface{}), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := SetResourceLease(ctx, client, res, &leaseSeconds); err != nil {
+			t.Fatal(err)
+		}
+		leased = append(leased, res.ID)
+	}
+	client.ResourcePool.UpdateOne(nestedEntity).SetParentResourceID(leased[0]).ExecX(ctx)
+	client.ResourcePool.UpdateOne(frozenEntity).SetLifecycleState(resourcePool.LifecycleStateFrozen).ExecX(ctx)
+
+	time.Sleep(time.Duration(1100) * time.Millisecond)
+
+	if poolIds, err := PoolsWithExpiredLeases(ctx, client); err != nil || len(poolIds) != 1 || poolIds[0] != poolEntity.ID {
+		t.Fatalf("Expected only pool %d with expired leases, found: %v, error: %v", poolEntity.ID, poolIds, err)
+	}
+	if freed, err := FreeExpiredLeases(ctx, client, poolEntity.ID); err != nil || freed != 1 {
+		t.Fatalf("Expected resource without nested pool to be freed, freed: %d, error: %v", freed, err)
+	}
+	if client.Resource.GetX(ctx, leased[0]).Status != resource.StatusClaimed || client.Resource.GetX(ctx, leased[1]).Status != resource.StatusFree {
+		t.Fatalf("Expected only resource without nested pool to be freed")
+	}
+	if poolIds, err := PoolsWithExpiredLeases(ctx, client); err != nil || len(poolIds) != 0 {
+		t.Fatalf("Expected no pools with expired leases left, found: %v, error: %v", poolIds, err)
+	}
+}
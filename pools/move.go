@@ -0,0 +1,149 @@
+package pools
+
+import (
+	"context"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// resourceAcceptor is implemented by pools able to take over a claimed resource from another pool
+type resourceAcceptor interface {
+	acceptMovedResource(moved *ent.Resource) (*ent.Resource, error)
+}
+
+// MoveResource moves a claimed resource into another pool of the same resource type. The value is claimed
+// in the target pool with the same description, alternative ID and lease, nested pools are attached to it
+// and the value is freed in the source pool. Singleton pools do not support moving resources.
+func MoveResource(ctx context.Context, client *ent.Client, resourceId int, targetPoolId int) (*ent.Resource, error) {
+	res, err := client.Resource.Query().
+		Where(resource.ID(resourceId)).
+		WithPool().
+		WithProperties(func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).
+		Only(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find resource ID %d", resourceId)
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	if res.Status != resource.StatusClaimed {
+		return nil, errors.Errorf("Unable to move resource #%d, it is not claimed", resourceId)
+	}
+	if res.Edges.Pool == nil {
+		return nil, errors.Errorf("Unable to move resource #%d, it does not belong to any pool", resourceId)
+	}
+	if res.Edges.Pool.ID == targetPoolId {
+		return nil, errors.Errorf("Unable to move resource #%d, it already belongs to pool #%d", resourceId, targetPoolId)
+	}
+	if res.Edges.Pool.PoolType == resourcePool.PoolTypeSingleton {
+		return nil, errors.Errorf("Unable to move resource #%d out of singleton pool #%d", resourceId, res.Edges.Pool.ID)
+	}
+	if res.Edges.Pool.LifecycleState == resourcePool.LifecycleStateFrozen {
+		return nil, errors.Errorf("Unable to move resource #%d out of frozen pool #%d", resourceId, res.Edges.Pool.ID)
+	}
+
+	sourcePool, err := existingPool(ctx, client, res.Edges.Pool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	targetPool, err := ExistingPoolFromId(ctx, client, targetPoolId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	if _, singleton := targetPool.(*SingletonPool); singleton {
+		return nil, errors.Errorf("Unable to move resource #%d into singleton pool #%d", resourceId, targetPoolId)
+	}
+	acceptor, ok := targetPool.(resourceAcceptor)
+	if !ok {
+		return nil, errors.Errorf("Unable to move resource #%d, pool #%d does not accept resources from other pools",
+			resourceId, targetPoolId)
+	}
+
+	sourceType, err := sourcePool.ResourceType()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	targetType, err := targetPool.ResourceType()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	if sourceType.ID != targetType.ID {
+		return nil, errors.Errorf("Unable to move resource #%d of type \"%s\" into pool #%d of type \"%s\"",
+			resourceId, sourceType.Name, targetPoolId, targetType.Name)
+	}
+
+	before, err := ResourceState(ctx, res, res.Edges.Pool.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	moved, err := acceptor.acceptMovedResource(res)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+	if res.LeaseExpiresAt != nil {
+		if err := client.Resource.UpdateOneID(moved.ID).SetLeaseExpiresAt(*res.LeaseExpiresAt).Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to move lease of resource ID %d", resourceId)
+			return nil, errors.Wrapf(err, "Unable to move lease of resource #%d", resourceId)
+		}
+	}
+
+	if err := moveNestedPools(ctx, client, res, moved); err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+
+	if err := sourcePool.FreeResources(nil, []int{res.ID}); err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d, unable to free it in source pool", resourceId)
+	}
+	for _, pool := range []Pool{sourcePool, targetPool} {
+		if err := UpdatePoolCapacity(pool); err != nil {
+			return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+		}
+	}
+	// the resource in the target pool has its own claim event, the move links it to the source resource
+	before["resourceId"] = res.ID
+	if err := RecordResourceEvent(ctx, client, resourceevent.EventMove, targetPoolId, moved.ID, before); err != nil {
+		return nil, errors.Wrapf(err, "Unable to move resource #%d", resourceId)
+	}
+
+	return client.Resource.Get(ctx, moved.ID)
+}
+
+// moveNestedPools attaches nested pools of a resource to another one, pool properties of nested
+// allocating pools are the properties of their parent resource so they are replaced as well
+func moveNestedPools(ctx context.Context, client *ent.Client, from *ent.Resource, to *ent.Resource) error {
+	nestedPools, err := from.QueryNestedPool().WithPoolProperties().All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to retrieve nested pools of resource ID %d", from.ID)
+		return errors.Wrapf(err, "Unable to retrieve nested pools of resource #%d", from.ID)
+	}
+	if len(nestedPools) == 0 {
+		return nil
+	}
+
+	props, err := to.QueryProperties().All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to retrieve properties of resource ID %d", to.ID)
+		return errors.Wrapf(err, "Unable to retrieve properties of resource #%d", to.ID)
+	}
+
+	for _, nested := range nestedPools {
+		if err := client.ResourcePool.UpdateOne(nested).SetParentResource(to).Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to move nested pool ID %d", nested.ID)
+			return errors.Wrapf(err, "Unable to move nested pool #%d", nested.ID)
+		}
+		if nested.Edges.PoolProperties == nil {
+			continue
+		}
+		if err := client.PoolProperties.UpdateOne(nested.Edges.PoolProperties).
+			ClearProperties().
+			AddProperties(props...).
+			Exec(ctx); err != nil {
+			log.Error(ctx, err, "Unable to move pool properties of nested pool ID %d", nested.ID)
+			return errors.Wrapf(err, "Unable to move pool properties of nested pool #%d", nested.ID)
+		}
+	}
+	return nil
+}
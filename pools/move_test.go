@@ -0,0 +1,119 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestMoveResource(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	source, sourceEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+	}, "source", nil, 60)
+	_, targetEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "target", nil, schema.ResourcePoolDealocationImmediately)
+
+	description := "customer"
+	altId := map[string]interface{}{"customer": "A"}
+	claimed, err := source.ClaimResource(map[string]interface{}{}, &description, altId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nested, nestedEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 100},
+	}, "nested", nil, schema.ResourcePoolDealocationImmediately)
+	client.ResourcePool.UpdateOne(nestedEntity).SetParentResource(claimed).ExecX(ctx)
+
+	if _, err := MoveResource(ctx, client, claimed.ID, sourceEntity.ID); err == nil {
+		t.Fatalf("Moving resource into its own pool should fail")
+	}
+
+	moved, err := MoveResource(ctx, client, claimed.ID, targetEntity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.QueryPool().OnlyIDX(ctx) != targetEntity.ID {
+		t.Fatalf("Resource should be claimed in target pool")
+	}
+	if *moved.Description != description || moved.AlternateID["customer"] != "A" {
+		t.Fatalf("Description and alternative ID should be kept, got: %v", moved)
+	}
+	if nested.(*SetPool).QueryParentResource().OnlyIDX(ctx) != moved.ID {
+		t.Fatalf("Nested pool should be attached to moved resource")
+	}
+	// source applies its safety period to the moved out value
+	assertDbResourceStates(ctx, client, t, 2, 1, 1, 0)
+
+	otherType := client.ResourceType.Create().SetName("other").SaveX(ctx)
+	_, otherEntity, _ := NewSetPoolWithMeta(ctx, client, otherType, nil, "other", nil,
+		schema.ResourcePoolDealocationImmediately)
+	if _, err := MoveResource(ctx, client, moved.ID, otherEntity.ID); err == nil {
+		t.Fatalf("Moving resource into pool of different resource type should fail")
+	}
+}
+
+func TestMoveResourceIntoAllocatingPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	fromType := client.PropertyType.Create().SetName("from").SetType("int").SetMandatory(true).SaveX(ctx)
+	toType := client.PropertyType.Create().SetName("to").SetType("int").SetMandatory(true).SaveX(ctx)
+	propsType := client.ResourceType.Create().SetName("vlanPool-ResourceType").
+		AddPropertyTypes(fromType, toType).SaveX(ctx)
+	poolProperties, err := CreatePoolProperties(ctx, client,
+		[]map[string]interface{}{{"from": 40, "to": 44}}, propsType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strat := client.AllocationStrategy.Create().
+		SetName("vlan").
+		SetLang(allocationstrategy.LangGo).
+		SetScript("vlan").
+		SaveX(ctx)
+	_, targetEntity, err := NewAllocatingPoolWithMeta(ctx, client, resType, strat, "vlanPool", nil,
+		schema.ResourcePoolDealocationImmediately, poolProperties)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "source", nil, schema.ResourcePoolDealocationImmediately)
+	inRange, _ := source.ClaimResource(map[string]interface{}{}, nil, nil)
+	outOfRange, _ := source.ClaimResource(map[string]interface{}{}, nil, nil)
+
+	if _, err := MoveResource(ctx, client, outOfRange.ID, targetEntity.ID); err == nil {
+		t.Fatalf("Moving resource not accepted by allocation strategy should fail")
+	}
+
+	moved, err := MoveResource(ctx, client, inRange.ID, targetEntity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, _ := PropertiesToMap(moved.QueryProperties().WithType().AllX(ctx))
+	if props["vlan"] != 44 {
+		t.Fatalf("Moved resource should keep its value, got: %v", props)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 2, 0, 0)
+}
@@ -2,24 +2,34 @@ package pools
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
 	log "github.com/net-auto/resourceManager/logging"
 
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/resource"
 	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/schema"
 	"github.com/pkg/errors"
 )
 
 // Pool is a resource provider
 type Pool interface {
 	ClaimResource(userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (*ent.Resource, error)
+	ClaimResources(count int, userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (ent.Resources, error)
+	ReserveResource(userInput map[string]interface{}, description *string, alternativeId map[string]interface{}, ttlSeconds int) (*ent.Resource, error)
+	CommitReservation(resourceId int) (*ent.Resource, error)
+	CancelReservation(resourceId int) error
 	FreeResource(RawResourceProps) error
+	FreeResources(raws []RawResourceProps, resourceIds []int) error
 	QueryResource(RawResourceProps) (*ent.Resource, error)
+	QueryClaimedResourceByAltId(alternativeId map[string]interface{}) (*ent.Resource, error)
 	QueryResources() (ent.Resources, error)
 	QueryPaginatedResources(*int, *int, *ent.Cursor, *ent.Cursor) (*ent.ResourceConnection, error)
 	Destroy() error
 	ResourceType() (*ent.ResourceType, error)
-	Capacity() (string, string, error)
+	Capacity() (*PoolCapacity, error)
 }
 
 type poolBase struct {
@@ -27,12 +37,38 @@ type poolBase struct {
 
 	ctx    context.Context
 	client *ent.Client
+	// shared by all copies of the pool, pools are passed around by value
+	capacityChange *capacityChange
+}
+
+func newPoolBase(pool *ent.ResourcePool, ctx context.Context, client *ent.Client) poolBase {
+	return poolBase{pool, ctx, client, newCapacityChange()}
 }
 
 func (pool poolBase) ResourceType() (*ent.ResourceType, error) {
 	return pool.ResourcePool.QueryResourceType().Only(pool.ctx)
 }
 
+func (pool poolBase) base() poolBase {
+	return pool
+}
+
+// checkClaimable rejects new claims from draining and frozen pools
+func (pool poolBase) checkClaimable() error {
+	if pool.LifecycleState != resourcePool.LifecycleStateActive {
+		return errors.Errorf("Unable to claim resources from pool \"%s\", pool is %s", pool.Name, pool.LifecycleState)
+	}
+	return nil
+}
+
+// checkMutable rejects any change of frozen pools
+func (pool poolBase) checkMutable() error {
+	if pool.LifecycleState == resourcePool.LifecycleStateFrozen {
+		return errors.Errorf("Unable to modify pool \"%s\", pool is frozen", pool.Name)
+	}
+	return nil
+}
+
 // SetPool is a pool providing resources from a finite/predefined set of resources
 type SetPool struct {
 	poolBase
@@ -49,6 +85,146 @@ type AllocatingPool struct {
 	invoker ScriptInvoker
 }
 
+// ClaimResourceIdempotent returns the resource already claimed from the pool under the same alternative ID,
+// a new resource is claimed only if there is none. Retrying a claim this way never allocates a second resource.
+func ClaimResourceIdempotent(pool Pool, userInput map[string]interface{}, description *string,
+	alternativeId map[string]interface{}) (*ent.Resource, error) {
+	if len(alternativeId) == 0 {
+		return nil, errors.Errorf("Unable to claim resource idempotently, alternative ID is required")
+	}
+
+	existing, err := pool.QueryClaimedResourceByAltId(alternativeId)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status == resource.StatusReserved {
+		return nil, errors.Errorf("Unable to claim resource idempotently, resource #%d with alternative ID %v "+
+			"is reserved, commit or cancel the reservation instead", existing.ID, alternativeId)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	return pool.ClaimResource(userInput, description, alternativeId)
+}
+
+// FreeResourceFailure describes a single resource that could not be freed as part of FreeResources
+type FreeResourceFailure struct {
+	Properties RawResourceProps
+	ResourceID *int
+	Err        error
+}
+
+// FreeResourcesError is returned by FreeResources when any of the resources cannot be freed
+type FreeResourcesError struct {
+	PoolName string
+	Failures []FreeResourceFailure
+}
+
+func (e *FreeResourcesError) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		if failure.ResourceID != nil {
+			msgs = append(msgs, fmt.Sprintf("resource #%d: %v", *failure.ResourceID, failure.Err))
+		} else {
+			msgs = append(msgs, fmt.Sprintf("resource %v: %v", failure.Properties, failure.Err))
+		}
+	}
+	return fmt.Sprintf("Unable to free resources in pool \"%s\". %d resource(s) failed: %s",
+		e.PoolName, len(e.Failures), strings.Join(msgs, "; "))
+}
+
+// PoolPropertiesConflict describes a single claimed resource that would not fit into updated pool properties
+type PoolPropertiesConflict struct {
+	ResourceID int
+	Properties RawResourceProps
+}
+
+// PoolPropertiesConflictError is returned by UpdatePoolProperties when claimed resources do not fit into new properties
+type PoolPropertiesConflictError struct {
+	PoolName  string
+	Conflicts []PoolPropertiesConflict
+}
+
+func (e *PoolPropertiesConflictError) Error() string {
+	msgs := make([]string, 0, len(e.Conflicts))
+	for _, conflict := range e.Conflicts {
+		msgs = append(msgs, fmt.Sprintf("resource #%d %v", conflict.ResourceID, conflict.Properties))
+	}
+	return fmt.Sprintf("Unable to update pool properties of pool \"%s\". %d claimed resource(s) would not fit: %s",
+		e.PoolName, len(e.Conflicts), strings.Join(msgs, "; "))
+}
+
+// UpdateResourcePool changes description, dealocation safety period and pool properties of an existing pool.
+// Nil values are left untouched. Only allocating pools have pool properties that can be changed.
+func UpdateResourcePool(
+	ctx context.Context,
+	client *ent.Client,
+	poolId int,
+	description *string,
+	dealocationSafetyPeriod *int,
+	poolProperties map[string]interface{}) (*ent.ResourcePool, error) {
+
+	entity, err := client.ResourcePool.Get(ctx, poolId)
+	if err != nil {
+		log.Error(ctx, err, "Unable to find pool ID %d", poolId)
+		return nil, errors.Wrapf(err, "Unable to update pool #%d", poolId)
+	}
+	if entity.LifecycleState == resourcePool.LifecycleStateFrozen {
+		return nil, errors.Errorf("Unable to update pool \"%s\", pool is frozen", entity.Name)
+	}
+	pool, err := existingPool(ctx, client, entity)
+	if err != nil {
+		return nil, err
+	}
+
+	if poolProperties != nil {
+		allocatingPool, ok := pool.(*AllocatingPool)
+		if !ok {
+			return nil, errors.Errorf("Unable to update pool properties of pool #%d, only allocating pools have pool properties", poolId)
+		}
+		if err := allocatingPool.UpdatePoolProperties(poolProperties); err != nil {
+			return nil, err
+		}
+		// new pool properties change the total capacity of the pool
+		if _, err := recomputePoolCapacity(pool); err != nil {
+			return nil, err
+		}
+	}
+
+	update := client.ResourcePool.UpdateOneID(poolId).SetNillableDescription(description)
+	if dealocationSafetyPeriod != nil {
+		if *dealocationSafetyPeriod < schema.ResourcePoolDealocationRetire {
+			return nil, errors.Errorf("Unable to update pool #%d, invalid dealocation safety period %d",
+				poolId, *dealocationSafetyPeriod)
+		}
+		update.SetDealocationSafetyPeriod(*dealocationSafetyPeriod)
+	}
+
+	updated, err := update.Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to update pool ID %d", poolId)
+		return nil, errors.Wrapf(err, "Unable to update pool #%d", poolId)
+	}
+	return updated, nil
+}
+
+// SetPoolLifecycleState changes the lifecycle state of a pool. Draining pools reject new claims
+// while resources can still be freed, frozen pools reject any change of their resources.
+func SetPoolLifecycleState(ctx context.Context, client *ent.Client, poolId int,
+	state resourcePool.LifecycleState) (*ent.ResourcePool, error) {
+	if err := resourcePool.LifecycleStateValidator(state); err != nil {
+		return nil, errors.Wrapf(err, "Unable to change lifecycle state of pool #%d", poolId)
+	}
+
+	updated, err := client.ResourcePool.UpdateOneID(poolId).SetLifecycleState(state).Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to change lifecycle state of pool ID %d to %s", poolId, state)
+		return nil, errors.Wrapf(err, "Unable to change lifecycle state of pool #%d", poolId)
+	}
+	return updated, nil
+}
+
 // Raw representation of resource property values such as ["a": 2, "b": "value"]
 type RawResourceProps map[string]interface{}
 
@@ -109,16 +285,16 @@ func existingPool(
 
 	switch pool.PoolType {
 	case resourcePool.PoolTypeSingleton:
-		return &SingletonPool{SetPool{poolBase{pool, ctx, client}}}, nil
+		return &SingletonPool{SetPool{newPoolBase(pool, ctx, client)}}, nil
 	case resourcePool.PoolTypeSet:
-		return &SetPool{poolBase{pool, ctx, client}}, nil
+		return &SetPool{newPoolBase(pool, ctx, client)}, nil
 	case resourcePool.PoolTypeAllocating:
 		wasmer, err := NewWasmerUsingEnvVars()
 		if err != nil {
 			log.Error(ctx, err, "Unable to create wasmer for %d", pool.ID)
 			return nil, err
 		}
-		return &AllocatingPool{SetPool{poolBase{pool, ctx, client}}, wasmer}, nil
+		return &AllocatingPool{SetPool{newPoolBase(pool, ctx, client)}, wasmer}, nil
 	default:
 		err := errors.Errorf("Unknown pool type \"%s\"", pool.PoolType)
 		log.Error(ctx, err, "cannot create pool")
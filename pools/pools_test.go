@@ -0,0 +1,68 @@
+package pools
+
+import (
+	"testing"
+
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestPoolLifecycleState(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	_, poolEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "pool", nil, schema.ResourcePoolDealocationImmediately)
+	if poolEntity.LifecycleState != resourcePool.LifecycleStateActive {
+		t.Fatalf("New pool should be active, got %s", poolEntity.LifecycleState)
+	}
+
+	pool, _ := ExistingPoolFromId(ctx, client, poolEntity.ID)
+	claimed, err := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SetPoolLifecycleState(ctx, client, poolEntity.ID, resourcePool.LifecycleStateFrozen); err != nil {
+		t.Fatal(err)
+	}
+	pool, _ = ExistingPoolFromId(ctx, client, poolEntity.ID)
+	if _, err := pool.ClaimResource(map[string]interface{}{}, nil, nil); err == nil {
+		t.Fatalf("Claiming from frozen pool should fail")
+	}
+	if err := pool.FreeResources(nil, []int{claimed.ID}); err == nil {
+		t.Fatalf("Freeing in frozen pool should fail")
+	}
+	if _, err := UpdateResourcePool(ctx, client, poolEntity.ID, nil, nil, nil); err == nil {
+		t.Fatalf("Updating frozen pool should fail")
+	}
+
+	if _, err := SetPoolLifecycleState(ctx, client, poolEntity.ID, resourcePool.LifecycleStateDraining); err != nil {
+		t.Fatal(err)
+	}
+	pool, _ = ExistingPoolFromId(ctx, client, poolEntity.ID)
+	if _, err := pool.ClaimResource(map[string]interface{}{}, nil, nil); err == nil {
+		t.Fatalf("Claiming from draining pool should fail")
+	}
+	if _, err := pool.ClaimResources(1, map[string]interface{}{}, nil, nil); err == nil {
+		t.Fatalf("Claiming from draining pool should fail")
+	}
+	if err := pool.FreeResources(nil, []int{claimed.ID}); err != nil {
+		t.Fatalf("Freeing in draining pool should succeed: %s", err)
+	}
+	assertDbResourceStates(ctx, client, t, 2, 0, 0, 0)
+
+	if _, err := SetPoolLifecycleState(ctx, client, poolEntity.ID, "unknown"); err == nil {
+		t.Fatalf("Unknown lifecycle state should be rejected")
+	}
+}
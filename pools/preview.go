@@ -0,0 +1,110 @@
+package pools
+
+import (
+	"context"
+	"strings"
+
+	"github.com/net-auto/resourceManager/ent"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// ClaimPreview lists resources a claim would allocate without actually claiming them
+type ClaimPreview struct {
+	Resources []RawResourceProps
+	// StrategyLog is the error output (logs) of allocation strategies invoked by the claim
+	StrategyLog string
+}
+
+type strategyLogKey struct{}
+
+// WithStrategyLog returns a context collecting error output of allocation strategies invoked by pools using it
+func WithStrategyLog(ctx context.Context) (context.Context, *strings.Builder) {
+	strategyLog := &strings.Builder{}
+	return context.WithValue(ctx, strategyLogKey{}, strategyLog), strategyLog
+}
+
+// appendStrategyLog records error output of a strategy run if the context collects it
+func appendStrategyLog(ctx context.Context, stdErr string) {
+	if stdErr == "" {
+		return
+	}
+	if strategyLog, ok := ctx.Value(strategyLogKey{}).(*strings.Builder); ok {
+		strategyLog.WriteString(stdErr)
+	}
+}
+
+// PreviewClaim claims count resources from a pool using its real strategy and real claimed resources
+// inside a transaction that is always rolled back. Nothing is persisted, the preview is not a reservation:
+// concurrent claims can allocate the previewed resources at any time.
+func PreviewClaim(ctx context.Context, client *ent.Client, poolId int,
+	userInput map[string]interface{}, count int) (*ClaimPreview, error) {
+	previewCtx, previewClient, rollback, err := openPreviewTransaction(ctx, client)
+	if err != nil {
+		log.Error(ctx, err, "Unable to open transaction to preview claim from pool ID %d", poolId)
+		return nil, errors.Wrapf(err, "Unable to preview claim from pool #%d", poolId)
+	}
+	defer func() {
+		if err := rollback(); err != nil {
+			log.Error(ctx, err, "Unable to roll back claim preview from pool ID %d", poolId)
+		}
+	}()
+
+	previewCtx, strategyLog := WithStrategyLog(previewCtx)
+	pool, err := ExistingPoolFromId(previewCtx, previewClient, poolId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to preview claim from pool #%d", poolId)
+	}
+
+	claimed, err := pool.ClaimResources(count, userInput, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ClaimPreview{StrategyLog: strategyLog.String()}
+	for _, res := range claimed {
+		props, err := res.QueryProperties().WithType().All(previewCtx)
+		if err != nil {
+			log.Error(ctx, err, "Unable to load properties of previewed resource from pool ID %d", poolId)
+			return nil, errors.Wrapf(err, "Unable to preview claim from pool #%d", poolId)
+		}
+		propMap, err := PropertiesToMap(props)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to preview claim from pool #%d", poolId)
+		}
+		preview.Resources = append(preview.Resources, propMap)
+	}
+	return preview, nil
+}
+
+const previewSavepoint = "claim_preview"
+
+// openPreviewTransaction returns a context and client of the transaction a preview runs in. The transaction
+// is exposed under ent.TxCtxKey{} for strategies executing their own SQL. A transaction already running in ctx
+// is reused within a savepoint, a new one is opened only if there is none. The returned function rolls back
+// everything done by the preview in both cases.
+func openPreviewTransaction(ctx context.Context, client *ent.Client) (context.Context, *ent.Client, func() error, error) {
+	if tx := transactionFromContext(ctx); tx != nil {
+		if err := execInTransaction(ctx, tx, "SAVEPOINT "+previewSavepoint); err != nil {
+			return nil, nil, nil, err
+		}
+		rollback := func() error {
+			if err := execInTransaction(ctx, tx, "ROLLBACK TO SAVEPOINT "+previewSavepoint); err != nil {
+				return err
+			}
+			return execInTransaction(ctx, tx, "RELEASE SAVEPOINT "+previewSavepoint)
+		}
+		return context.WithValue(ctx, ent.TxCtxKey{}, tx), tx.Client(), rollback, nil
+	}
+
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	previewCtx := ent.NewTxContext(ctx, tx)
+	return context.WithValue(previewCtx, ent.TxCtxKey{}, tx), tx.Client(), tx.Rollback, nil
+}
+
+func execInTransaction(ctx context.Context, tx *ent.Tx, statement string) error {
+	return tx.UnderlyingTx().Exec(ctx, statement, []interface{}{}, nil)
+}
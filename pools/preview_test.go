@@ -0,0 +1,56 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestPreviewClaim(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, poolEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 1},
+		RawResourceProps{"vlan": 2},
+		RawResourceProps{"vlan": 3},
+	}, "preview", nil, schema.ResourcePoolDealocationImmediately)
+	if _, err := pool.ClaimResource(map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	preview, err := PreviewClaim(ctx, client, poolEntity.ID, map[string]interface{}{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Resources) != 2 || preview.Resources[0]["vlan"] != 2 || preview.Resources[1]["vlan"] != 3 {
+		t.Fatalf("Expected vlans 2 and 3 in preview, got %v", preview.Resources)
+	}
+	// nothing is claimed by the preview
+	assertDbResourceStates(ctx, client, t, 2, 1, 0, 0)
+
+	if _, err := PreviewClaim(ctx, client, poolEntity.ID, map[string]interface{}{}, 3); err == nil {
+		t.Fatalf("Preview claiming more resources than available should fail")
+	}
+	assertDbResourceStates(ctx, client, t, 2, 1, 0, 0)
+}
+
+func TestStrategyLog(t *testing.T) {
+	appendStrategyLog(getContext(), "ignored")
+
+	ctx, strategyLog := WithStrategyLog(getContext())
+	appendStrategyLog(ctx, "first\n")
+	appendStrategyLog(ctx, "")
+	appendStrategyLog(ctx, "second\n")
+	if strategyLog.String() != "first\nsecond\n" {
+		t.Fatalf("Unexpected strategy log \"%s\"", strategyLog.String())
+	}
+}
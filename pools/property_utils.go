@@ -156,52 +156,23 @@ func ParseProps(
 			}
 		}
 
-		ppBuilder := tx.Property.Create().SetType(pt)
-
-		// TODO is there a better way of parsing individual types ? Reuse something from inv ?
-		// TODO add additional types
-		switch pt.Type {
-		case "int":
-			var atoi int
-			var err error
-
-			if pvType := reflect.TypeOf(pv); pvType.Kind() == reflect.Float64 {
-				atoi, err = strconv.Atoi(fmt.Sprintf("%v", int(pv.(float64))))
-			} else {
-				atoi, err = strconv.Atoi(fmt.Sprintf("%v", pv))
-			}
-
-			if err != nil {
-				err := errors.Wrapf(err, "Unable to parse int value from \"%s\"", pv)
-				log.Error(ctx, err, "Unable to parse int value")
-				return nil, err
-			}
-			ppBuilder.SetIntVal(atoi)
-		case "string":
-			ppBuilder.SetStringVal(pv.(string))
-		case "float":
-			// Parse the float from string to be sure
-			parsedFloat, err := strconv.ParseFloat(fmt.Sprintf("%v", pv), 64)
-			if err != nil {
-				err := errors.Wrapf(err, "Unable to parse float value from \"%s\"", pv)
-				log.Error(ctx, err, "Unable to parse float value")
-				return nil, err
-			}
-			ppBuilder.SetFloatVal(parsedFloat)
-		case "bool":
-			parsedBool, err := strconv.ParseBool(fmt.Sprintf("%v", pv))
-			if err != nil {
-				err := errors.Wrapf(err, "Unable to parse bool value from \"%s\"", pv)
-				log.Error(ctx, err, "Unable to parse bool value")
-				return nil, err
-			}
-			ppBuilder.SetBoolVal(parsedBool)
-		default:
-			err := errors.Errorf("Unsupported property type \"%s\"", pt.Type)
-			log.Error(ctx, err, "Unsupported property type")
+		value, err := parsePropertyValue(ctx, pt, pv)
+		if err != nil {
 			return nil, err
 		}
 
+		ppBuilder := tx.Property.Create().SetType(pt)
+		switch v := value.(type) {
+		case int:
+			ppBuilder.SetIntVal(v)
+		case string:
+			ppBuilder.SetStringVal(v)
+		case float64:
+			ppBuilder.SetFloatVal(v)
+		case bool:
+			ppBuilder.SetBoolVal(v)
+		}
+
 		pp, err := ppBuilder.Save(ctx)
 		if err != nil {
 			err := errors.Wrapf(err, "Unable to instantiate property of type \"%s\"", pt.Type)
@@ -214,6 +185,53 @@ func ParseProps(
 	return props, nil
 }
 
+// parsePropertyValue converts raw property value into the go type matching the property type
+func parsePropertyValue(ctx context.Context, pt *ent.PropertyType, pv interface{}) (interface{}, error) {
+	// TODO is there a better way of parsing individual types ? Reuse something from inv ?
+	// TODO add additional types
+	switch pt.Type {
+	case "int":
+		var atoi int
+		var err error
+
+		if pvType := reflect.TypeOf(pv); pvType.Kind() == reflect.Float64 {
+			atoi, err = strconv.Atoi(fmt.Sprintf("%v", int(pv.(float64))))
+		} else {
+			atoi, err = strconv.Atoi(fmt.Sprintf("%v", pv))
+		}
+
+		if err != nil {
+			err := errors.Wrapf(err, "Unable to parse int value from \"%s\"", pv)
+			log.Error(ctx, err, "Unable to parse int value")
+			return nil, err
+		}
+		return atoi, nil
+	case "string":
+		return pv.(string), nil
+	case "float":
+		// Parse the float from string to be sure
+		parsedFloat, err := strconv.ParseFloat(fmt.Sprintf("%v", pv), 64)
+		if err != nil {
+			err := errors.Wrapf(err, "Unable to parse float value from \"%s\"", pv)
+			log.Error(ctx, err, "Unable to parse float value")
+			return nil, err
+		}
+		return parsedFloat, nil
+	case "bool":
+		parsedBool, err := strconv.ParseBool(fmt.Sprintf("%v", pv))
+		if err != nil {
+			err := errors.Wrapf(err, "Unable to parse bool value from \"%s\"", pv)
+			log.Error(ctx, err, "Unable to parse bool value")
+			return nil, err
+		}
+		return parsedBool, nil
+	default:
+		err := errors.Errorf("Unsupported property type \"%s\"", pt.Type)
+		log.Error(ctx, err, "Unsupported property type")
+		return nil, err
+	}
+}
+
 // ToRawTypes converts between []map[string]interface{} and []RawResourceProps
 //  which is the same thing ... but not to the compiler
 func ToRawTypes(poolValues []map[string]interface{}) []RawResourceProps {
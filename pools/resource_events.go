@@ -0,0 +1,92 @@
+package pools
+
+import (
+	"context"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
+	"github.com/net-auto/resourceManager/ent/schema"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+)
+
+// ResourceState describes a resource in its event history: status, properties, alternative ID, description and pool
+func ResourceState(ctx context.Context, res *ent.Resource, poolId int) (map[string]interface{}, error) {
+	props, err := res.QueryProperties().WithType().All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to retrieve properties of resource ID %d", res.ID)
+		return nil, errors.Wrapf(err, "Unable to retrieve properties of resource #%d", res.ID)
+	}
+	propMap, err := PropertiesToMap(props)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to serialize properties of resource #%d", res.ID)
+	}
+
+	state := map[string]interface{}{
+		"status":     res.Status.String(),
+		"properties": map[string]interface{}(propMap),
+		"poolId":     poolId,
+	}
+	if len(res.AlternateID) > 0 {
+		// callers modify alternative IDs in place
+		alternateId := make(map[string]interface{}, len(res.AlternateID))
+		for k, v := range res.AlternateID {
+			alternateId[k] = v
+		}
+		state["alternateId"] = alternateId
+	}
+	if res.Description != nil {
+		state["description"] = *res.Description
+	}
+	return state, nil
+}
+
+// RecordResourceEvent stores a change of a resource together with identity of the user making it.
+// before is the state of the resource prior to the change, nil for resources created by the change.
+// The state after the change is loaded from DB, it is nil for resources deleted by the change.
+func RecordResourceEvent(ctx context.Context, client *ent.Client, event resourceevent.Event,
+	poolId int, resourceId int, before map[string]interface{}) error {
+	create := client.ResourceEvent.Create().
+		SetEvent(event).
+		SetResourceID(resourceId).
+		SetPoolID(poolId)
+
+	if identity, err := schema.GetIdentity(ctx); err == nil {
+		create.SetTenant(identity.Tenant).SetUser(identity.User).SetRoles(identity.Roles)
+	}
+
+	res, err := client.Resource.Get(ctx, resourceId)
+	if err != nil && !ent.IsNotFound(err) {
+		log.Error(ctx, err, "Unable to retrieve resource ID %d", resourceId)
+		return errors.Wrapf(err, "Unable to record %s of resource #%d", event, resourceId)
+	}
+	if res != nil {
+		after, err := ResourceState(ctx, res, poolId)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to record %s of resource #%d", event, resourceId)
+		}
+		create.SetAfter(after).SetNillableDescription(res.Description)
+	} else if description, ok := before["description"].(string); ok {
+		create.SetDescription(description)
+	}
+	if before != nil {
+		create.SetBefore(before)
+	}
+
+	if err := create.Exec(ctx); err != nil {
+		log.Error(ctx, err, "Unable to record %s of resource ID %d", event, resourceId)
+		return errors.Wrapf(err, "Unable to record %s of resource #%d", event, resourceId)
+	}
+	return nil
+}
+
+// recordClaims stores claim events of resources, before holds states of resources prior to the claim by resource ID
+func recordClaims(ctx context.Context, client *ent.Client, poolId int,
+	claimed []*ent.Resource, before map[int]map[string]interface{}) error {
+	for _, res := range claimed {
+		if err := RecordResourceEvent(ctx, client, resourceevent.EventClaim, poolId, res.ID, before[res.ID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
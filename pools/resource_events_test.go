@@ -0,0 +1,120 @@
+package pools
+
+import (
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestResourceEvents(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, poolEntity, err := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+	if err != nil {
+		t.Fatal(err)
+	}
+	description := "uplink"
+	claimed, err := pool.ClaimResource(map[string]interface{}{}, &description, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.FreeResource(RawResourceProps{"vlan": 44}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := client.ResourceEvent.Query().
+		Where(resourceevent.ResourceID(claimed.ID)).
+		Order(ent.Asc(resourceevent.FieldID)).
+		AllX(ctx)
+	if len(events) != 2 {
+		t.Fatalf("Expected claim and free events, got %v", events)
+	}
+
+	claim := events[0]
+	if claim.Event != resourceevent.EventClaim || claim.PoolID != poolEntity.ID {
+		t.Fatalf("Expected claim event in pool %d, got %v", poolEntity.ID, claim)
+	}
+	if claim.Tenant != "fb" || claim.User != "fb-user" || len(claim.Roles) != 3 {
+		t.Fatalf("Expected identity of the claiming user, got %v", claim)
+	}
+	if claim.Description == nil || *claim.Description != description {
+		t.Fatalf("Expected description %s, got %v", description, claim.Description)
+	}
+	if claim.Before["status"] != "free" || claim.After["status"] != "claimed" {
+		t.Fatalf("Expected status change from free to claimed, got %v -> %v", claim.Before, claim.After)
+	}
+	if claim.After["properties"].(map[string]interface{})["vlan"] != float64(44) {
+		t.Fatalf("Expected properties of the claimed resource, got %v", claim.After)
+	}
+
+	free := events[1]
+	if free.Event != resourceevent.EventFree ||
+		free.Before["status"] != "claimed" || free.After["status"] != "free" {
+		t.Fatalf("Expected free event changing status from claimed to free, got %v", free)
+	}
+}
+
+func TestResourceEventsOfReservationsAndAdminFrees(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _, err := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+	}, "set", nil, schema.ResourcePoolDealocationRetire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reserved, err := pool.ReserveResource(map[string]interface{}{}, nil, nil, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.CommitReservation(reserved.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ForceFreeResources(ctx, client, []int{reserved.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := client.ResourceEvent.Query().
+		Where(resourceevent.ResourceID(reserved.ID)).
+		Order(ent.Asc(resourceevent.FieldID)).
+		AllX(ctx)
+	expected := []struct {
+		event         resourceevent.Event
+		before, after string
+	}{
+		{resourceevent.EventClaim, "free", "claimed"},
+		{resourceevent.EventReserve, "claimed", "reserved"},
+		{resourceevent.EventCommit, "reserved", "claimed"},
+		{resourceevent.EventFree, "claimed", "free"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %v", len(expected), events)
+	}
+	for i, e := range expected {
+		if events[i].Event != e.event || events[i].Before["status"] != e.before || events[i].After["status"] != e.after {
+			t.Fatalf("Expected %s event changing status from %s to %s, got %v", e.event, e.before, e.after, events[i])
+		}
+	}
+	if events[3].User != "fb-user" {
+		t.Fatalf("Expected identity of the admin freeing the resource, got %v", events[3])
+	}
+}
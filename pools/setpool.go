@@ -2,14 +2,17 @@ package pools
 
 import (
 	"context"
+	"fmt"
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/predicate"
 	resource "github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
 	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
 	"github.com/net-auto/resourceManager/ent/schema"
 	log "github.com/net-auto/resourceManager/logging"
 	"github.com/pkg/errors"
-	"strconv"
+	"math/big"
+	"strings"
 	"time"
 )
 
@@ -36,8 +39,6 @@ func NewSetPoolWithMeta(
 	description *string,
 	poolDealocationSafetyPeriod int) (Pool, *ent.ResourcePool, error) {
 
-	// TODO check that propertyValues are unique
-
 	pool, err := newFixedPoolInner(ctx, client, resourceType, propertyValues,
 		poolName, description, resourcePool.PoolTypeSet, poolDealocationSafetyPeriod)
 
@@ -46,11 +47,15 @@ func NewSetPoolWithMeta(
 		return nil, nil, err
 	}
 
-	return &SetPool{poolBase{pool, ctx, client}}, pool, nil
+	return &SetPool{newPoolBase(pool, ctx, client)}, pool, nil
 }
 
 // Destroy removes the pool from DB if there are no more claims
 func (pool SetPool) Destroy() error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
 	// Check if there are no more claims
 	claims, err := pool.QueryResources()
 	if err != nil {
@@ -99,8 +104,142 @@ func (pool SetPool) Destroy() error {
 	return nil
 }
 
+// AddValues adds new free resources to the pool, values already present in the pool are rejected
+func (pool SetPool) AddValues(values []RawResourceProps) (ent.Resources, error) {
+	if err := pool.checkMutable(); err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, errors.Errorf("Unable to add values to pool \"%s\". No values specified", pool.Name)
+	}
+
+	resourceType, err := pool.ResourceType()
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable retrieve resource type for pool with ID: %d", pool.ID)
+		return nil, errors.Wrapf(err, "Unable to add values to pool \"%s\"", pool.Name)
+	}
+
+	created, err := PreCreateResources(pool.ctx, pool.client, values, pool.ResourcePool, resourceType,
+		resource.StatusFree, nil, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to add values to pool \"%s\"", pool.Name)
+	}
+	pool.capacityChange.add(int64(len(created)), 0)
+	return created, nil
+}
+
+// RemoveValues removes resources from the pool, either all of them or none.
+// Claimed and reserved resources, resources with nested pools and benched resources within
+// the dealocation safety period are only removed when forced. Forced removal destroys nested pools.
+func (pool SetPool) RemoveValues(values []RawResourceProps, force bool) error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
+	if len(values) == 0 {
+		return errors.Errorf("Unable to remove values from pool \"%s\". No values specified", pool.Name)
+	}
+
+	var failures []string
+	toRemove := make([]*ent.Resource, 0, len(values))
+	seen := make(map[int]bool)
+
+	// Validate all resources before removing any of them
+	check := func(res *ent.Resource, err error) error {
+		if err != nil {
+			return err
+		}
+		if seen[res.ID] {
+			return errors.Errorf("Resource #%d is listed more than once", res.ID)
+		}
+		seen[res.ID] = true
+		if force {
+			toRemove = append(toRemove, res)
+			return nil
+		}
+
+		switch res.Status {
+		case resource.StatusClaimed, resource.StatusReserved:
+			return errors.Errorf("Resource #%d is %s", res.ID, res.Status)
+		case resource.StatusRetired:
+			return errors.Errorf("Resource #%d is retired", res.ID)
+		case resource.StatusBench:
+			cutoff := res.UpdatedAt.Add(time.Duration(pool.DealocationSafetyPeriod) * time.Second)
+			if time.Now().Before(cutoff) {
+				return errors.Errorf("Resource #%d is benched until %s", res.ID, cutoff)
+			}
+		}
+		if err := pool.checkNoNestedPool(res); err != nil {
+			return err
+		}
+		toRemove = append(toRemove, res)
+		return nil
+	}
+
+	for _, raw := range values {
+		if err := check(pool.findResourceToFree(raw)); err != nil {
+			failures = append(failures, fmt.Sprintf("resource %v: %v", raw, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		err := errors.Errorf("Unable to remove values from pool \"%s\". %d value(s) failed: %s",
+			pool.Name, len(failures), strings.Join(failures, "; "))
+		log.Warn(pool.ctx, "%s", err.Error())
+		return err
+	}
+
+	for _, res := range toRemove {
+		if err := pool.removeResource(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeResource deletes the resource together with its properties, nested pools are destroyed first
+func (pool SetPool) removeResource(res *ent.Resource) error {
+	nestedPools, err := res.QueryNestedPool().All(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve nested pools of resource ID %d", res.ID)
+		return errors.Wrapf(err, "Unable to remove resource #%d from pool \"%s\"", res.ID, pool.Name)
+	}
+	for _, nested := range nestedPools {
+		nestedPool, err := existingPool(pool.ctx, pool.client, nested)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to remove resource #%d from pool \"%s\"", res.ID, pool.Name)
+		}
+		if err := nestedPool.Destroy(); err != nil {
+			return errors.Wrapf(err, "Unable to remove resource #%d from pool \"%s\". "+
+				"Unable to destroy nested pool #%d", res.ID, pool.Name, nested.ID)
+		}
+	}
+
+	for _, prop := range res.Edges.Properties {
+		if err := pool.client.Property.DeleteOne(prop).Exec(pool.ctx); err != nil {
+			log.Error(pool.ctx, err, "Cannot delete properties of resource ID %d", res.ID)
+			return errors.Wrapf(err, "Unable to remove resource #%d from pool \"%s\". "+
+				"Unable to cleanup properties", res.ID, pool.Name)
+		}
+	}
+	if err := pool.client.Resource.DeleteOne(res).Exec(pool.ctx); err != nil {
+		log.Error(pool.ctx, err, "Cannot delete resource ID %d", res.ID)
+		return errors.Wrapf(err, "Unable to remove resource #%d from pool \"%s\"", res.ID, pool.Name)
+	}
+	if usesSetPoolCapacity(res.Status) {
+		pool.capacityChange.add(0, -1)
+	} else {
+		pool.capacityChange.add(-1, 0)
+	}
+	return nil
+}
+
 // ClaimResource allocates the next available resource
 func (pool SetPool) ClaimResource(userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (*ent.Resource, error) {
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
+	}
 
 	// Allocate new resource for this tag
 	unclaimedRes, err := pool.queryUnclaimedResourceEager()
@@ -109,6 +248,11 @@ func (pool SetPool) ClaimResource(userInput map[string]interface{}, description
 			pool.Name)
 	}
 
+	before, err := ResourceState(pool.ctx, unclaimedRes, pool.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to claim a resource in pool \"%s\"", pool.Name)
+	}
+
 	err = pool.client.Resource.
 		UpdateOne(unclaimedRes).
 		SetStatus(resource.StatusClaimed).
@@ -121,25 +265,220 @@ func (pool SetPool) ClaimResource(userInput map[string]interface{}, description
 		log.Error(pool.ctx, err, "Unable to claim a resource")
 		return nil, err
 	}
+	pool.trackStatusChange(unclaimedRes.Status, resource.StatusClaimed)
+	if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventClaim, pool.ID, unclaimedRes.ID, before); err != nil {
+		return nil, err
+	}
 	return unclaimedRes, err
 }
 
-func (pool SetPool) Capacity() (string, string, error) {
-	claimedResources, err := pool.QueryResources()
+// ClaimResources allocates count next available resources, either all of them or none
+func (pool SetPool) ClaimResources(count int, userInput map[string]interface{}, description *string, alternativeId map[string]interface{}) (ent.Resources, error) {
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
+	}
+
+	if count < 1 {
+		return nil, errors.Errorf("Unable to claim resources in pool \"%s\", count must be positive, got %d",
+			pool.Name, count)
+	}
+
+	unclaimedRes, err := pool.queryUnclaimedResourcesEager(count)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find unclaimed resources in pool \"%s\"",
+			pool.Name)
+	}
+
+	ids := make([]int, len(unclaimedRes))
+	before := make(map[int]map[string]interface{}, len(unclaimedRes))
+	for i, res := range unclaimedRes {
+		ids[i] = res.ID
+		if before[res.ID], err = ResourceState(pool.ctx, res, pool.ID); err != nil {
+			return nil, errors.Wrapf(err, "Unable to claim %d resources in pool \"%s\"", count, pool.Name)
+		}
+	}
+
+	err = pool.client.Resource.Update().
+		Where(resource.IDIn(ids...)).
+		SetStatus(resource.StatusClaimed).
+		SetNillableDescription(description).
+		SetAlternateID(alternativeId).
+		Exec(pool.ctx)
+
+	if err != nil {
+		err := errors.Wrapf(err, "Unable to claim %d resources in pool \"%s\"", count, pool.Name)
+		log.Error(pool.ctx, err, "Unable to claim resources")
+		return nil, err
+	}
+	for _, res := range unclaimedRes {
+		pool.trackStatusChange(res.Status, resource.StatusClaimed)
+	}
+
+	claimed, err := pool.client.Resource.Query().
+		Where(resource.IDIn(ids...)).
+		Order(ent.Asc(resource.FieldID)).
+		All(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve claimed resources in pool ID %d", pool.ID)
+		return nil, errors.Wrapf(err, "Unable to retrieve claimed resources in pool \"%s\"", pool.Name)
+	}
+	if err := recordClaims(pool.ctx, pool.client, pool.ID, claimed, before); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (pool SetPool) Capacity() (*PoolCapacity, error) {
+	// reserved resources are not available for claiming
+	claimedResources, err := pool.findResources().
+		Where(resource.StatusIn(resource.StatusClaimed, resource.StatusReserved)).
+		All(pool.ctx)
 
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable to retrieve resources for pool ID %d", pool.ID)
-		return "0", "0", err
+		return nil, err
 	}
 
 	resources, err := pool.client.Resource.Query().Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).All(pool.ctx)
 
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable to retrieve resources for pool ID %d", pool.ID)
-		return "0", "0", err
+		return nil, err
+	}
+
+	return newPoolCapacityFromInts(len(resources)-len(claimedResources), len(claimedResources)), nil
+}
+
+// carvedOutCapacity is the utilized capacity of resources backing nested pools
+func (pool SetPool) carvedOutCapacity() (*big.Int, error) {
+	carvedOut, err := pool.findResources().Where(resource.HasNestedPool()).Count(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to count resources with nested pools in pool ID %d", pool.ID)
+		return nil, err
+	}
+	return big.NewInt(int64(carvedOut)), nil
+}
+
+// ReserveResource holds the next available resource for ttlSeconds until it is committed or cancelled
+func (pool SetPool) ReserveResource(userInput map[string]interface{}, description *string,
+	alternativeId map[string]interface{}, ttlSeconds int) (*ent.Resource, error) {
+	if err := pool.checkReservationTtl(ttlSeconds); err != nil {
+		return nil, err
+	}
+
+	res, err := pool.ClaimResource(userInput, description, alternativeId)
+	if err != nil {
+		return nil, err
+	}
+	return pool.reserveResource(res, ttlSeconds)
+}
+
+func (pool SetPool) checkReservationTtl(ttlSeconds int) error {
+	if ttlSeconds <= 0 {
+		return errors.Errorf("Unable to reserve a resource in pool \"%s\", ttl must be positive, got %d seconds",
+			pool.Name, ttlSeconds)
+	}
+	return nil
+}
+
+func (pool SetPool) reserveResource(res *ent.Resource, ttlSeconds int) (*ent.Resource, error) {
+	// claimed resources returned by pools may not reflect the stored status
+	claimed, err := pool.client.Resource.Get(pool.ctx, res.ID)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve resource ID %d", res.ID)
+		return nil, errors.Wrapf(err, "Unable to reserve a resource in pool \"%s\"", pool.Name)
+	}
+	before, err := ResourceState(pool.ctx, claimed, pool.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to reserve a resource in pool \"%s\"", pool.Name)
+	}
+	reserved, err := pool.client.Resource.UpdateOne(res).
+		SetStatus(resource.StatusReserved).
+		SetLeaseExpiresAt(time.Now().Add(time.Duration(ttlSeconds) * time.Second)).
+		Save(pool.ctx)
+	if err != nil {
+		err := errors.Wrapf(err, "Unable to reserve a resource in pool \"%s\"", pool.Name)
+		log.Error(pool.ctx, err, "Unable to reserve a resource")
+		return nil, err
+	}
+	if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventReserve, pool.ID, reserved.ID, before); err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// CommitReservation turns a reserved resource into a claimed one, expired reservations cannot be committed
+func (pool SetPool) CommitReservation(resourceId int) (*ent.Resource, error) {
+	if err := pool.checkMutable(); err != nil {
+		return nil, err
+	}
+
+	res, err := pool.findReservedResource(resourceId)
+	if err != nil {
+		return nil, err
+	}
+	// expired reservations wait for the lease reaper to return them to the pool
+	if res.LeaseExpiresAt != nil && !res.LeaseExpiresAt.After(time.Now()) {
+		return nil, errors.Errorf("Unable to commit reservation of resource #%d in pool \"%s\", reservation expired at %s",
+			resourceId, pool.Name, res.LeaseExpiresAt.Format(time.RFC3339))
+	}
+
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to commit reservation of resource #%d in pool \"%s\"", resourceId, pool.Name)
+	}
+	claimed, err := pool.client.Resource.UpdateOne(res).
+		SetStatus(resource.StatusClaimed).
+		ClearLeaseExpiresAt().
+		Save(pool.ctx)
+	if err != nil {
+		err := errors.Wrapf(err, "Unable to commit reservation of resource #%d in pool \"%s\"", resourceId, pool.Name)
+		log.Error(pool.ctx, err, "Unable to commit reservation")
+		return nil, err
+	}
+	if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventCommit, pool.ID, claimed.ID, before); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// CancelReservation returns a reserved resource back to the pool, the safety period does not apply
+func (pool SetPool) CancelReservation(resourceId int) error {
+	return pool.cancelReservationInner(resourceId, pool.freeResourceImmediately)
+}
+
+func (pool SetPool) cancelReservationInner(resourceId int, freeResource func(res *ent.Resource) error) error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
+	res, err := pool.findReservedResource(resourceId)
+	if err != nil {
+		return err
 	}
 
-	return strconv.Itoa(len(resources) - len(claimedResources)), strconv.Itoa(len(claimedResources)), nil
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to cancel reservation of resource #%d in pool \"%s\"", resourceId, pool.Name)
+	}
+	if err := freeResource(res); err != nil {
+		err := errors.Wrapf(err, "Unable to cancel reservation of resource #%d in pool \"%s\"", resourceId, pool.Name)
+		log.Error(pool.ctx, err, "Unable to cancel reservation")
+		return err
+	}
+	return RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventFree, pool.ID, resourceId, before)
+}
+
+func (pool SetPool) findReservedResource(resourceId int) (*ent.Resource, error) {
+	res, err := pool.findResourceToFreeById(resourceId)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to find resource ID %d in pool ID %d", resourceId, pool.ID)
+		return nil, errors.Wrapf(err, "Unable to find reservation in pool \"%s\"", pool.Name)
+	}
+	if res.Status != resource.StatusReserved {
+		return nil, errors.Errorf("Resource #%d in pool \"%s\" is not reserved", resourceId, pool.Name)
+	}
+	return res, nil
 }
 
 // FreeResource deallocates the resource identified by its properties
@@ -152,6 +491,10 @@ func (pool SetPool) freeResourceInner(raw RawResourceProps,
 	freeResource func(res *ent.Resource) error,
 	benchResource func(res *ent.Resource) error,
 ) error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
 	query, err := pool.findResource(raw)
 	if err != nil {
 		err := errors.Wrapf(err, "Unable to find resource in pool: \"%s\"", pool.Name)
@@ -173,7 +516,15 @@ func (pool SetPool) freeResourceInner(raw RawResourceProps,
 		return errors.Wrapf(err, "Unable to free a resource in pool \"%s\". It has not been claimed", pool.Name)
 	}
 
-	// Make sure there are no nested pools attached
+	if err := pool.checkNoNestedPool(res); err != nil {
+		return err
+	}
+
+	return pool.unclaimResource(res, retireResource, freeResource, benchResource)
+}
+
+// checkNoNestedPool makes sure there are no nested pools attached to the resource
+func (pool SetPool) checkNoNestedPool(res *ent.Resource) error {
 	if nestedPool, err := res.QueryNestedPool().First(pool.ctx); err != nil && !ent.IsNotFound(err) {
 		log.Error(pool.ctx, err, "Unable to free a resource in pool ID %d", pool.ID)
 		return errors.Wrapf(err, "Unable to free a resource in pool \"%s\". "+
@@ -184,13 +535,30 @@ func (pool SetPool) freeResourceInner(raw RawResourceProps,
 		log.Error(pool.ctx, err, "Unable to free a resource in pool ID %d", pool.ID)
 		return err
 	}
+	return nil
+}
+
+// unclaimResource applies the dealocation safety period of the pool to the resource
+func (pool SetPool) unclaimResource(res *ent.Resource,
+	retireResource func(res *ent.Resource) error,
+	freeResource func(res *ent.Resource) error,
+	benchResource func(res *ent.Resource) error,
+) error {
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to free a resource in pool \"%s\"", pool.Name)
+	}
 
+	var event resourceevent.Event
 	switch pool.ResourcePool.DealocationSafetyPeriod {
 	case schema.ResourcePoolDealocationRetire:
+		event = resourceevent.EventRetire
 		err = retireResource(res)
 	case schema.ResourcePoolDealocationImmediately:
+		event = resourceevent.EventFree
 		err = freeResource(res)
 	default:
+		event = resourceevent.EventBench
 		err = benchResource(res)
 	}
 
@@ -200,19 +568,186 @@ func (pool SetPool) freeResourceInner(raw RawResourceProps,
 		return err
 	}
 
+	return RecordResourceEvent(pool.ctx, pool.client, event, pool.ID, res.ID, before)
+}
+
+// FreeResources deallocates all resources identified by their properties or IDs.
+// Either all resources are freed or none and the returned FreeResourcesError lists every failing resource.
+func (pool SetPool) FreeResources(raws []RawResourceProps, resourceIds []int) error {
+	return pool.freeResourcesInner(raws, resourceIds, pool.retireResource, pool.freeResourceImmediately, pool.benchResource)
+}
+
+func (pool SetPool) freeResourcesInner(raws []RawResourceProps, resourceIds []int,
+	retireResource func(res *ent.Resource) error,
+	freeResource func(res *ent.Resource) error,
+	benchResource func(res *ent.Resource) error,
+) error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
+	if len(raws) == 0 && len(resourceIds) == 0 {
+		return errors.Errorf("Unable to free resources in pool \"%s\". No resources specified", pool.Name)
+	}
+
+	var failures []FreeResourceFailure
+	toFree := make([]*ent.Resource, 0, len(raws)+len(resourceIds))
+	seen := make(map[int]bool)
+
+	// Validate all resources before freeing any of them
+	check := func(res *ent.Resource, err error) error {
+		if err != nil {
+			return err
+		}
+		if seen[res.ID] {
+			return errors.Errorf("Resource #%d is listed more than once", res.ID)
+		}
+		if res.Status != resource.StatusClaimed {
+			return errors.Errorf("Resource #%d has not been claimed", res.ID)
+		}
+		if err := pool.checkNoNestedPool(res); err != nil {
+			return err
+		}
+		seen[res.ID] = true
+		toFree = append(toFree, res)
+		return nil
+	}
+
+	for _, raw := range raws {
+		if err := check(pool.findResourceToFree(raw)); err != nil {
+			failures = append(failures, FreeResourceFailure{Properties: raw, Err: err})
+		}
+	}
+	for _, resourceId := range resourceIds {
+		id := resourceId
+		if err := check(pool.findResourceToFreeById(id)); err != nil {
+			failures = append(failures, FreeResourceFailure{ResourceID: &id, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		err := &FreeResourcesError{PoolName: pool.Name, Failures: failures}
+		log.Warn(pool.ctx, "%s", err.Error())
+		return err
+	}
+
+	for _, res := range toFree {
+		if err := pool.unclaimResource(res, retireResource, freeResource, benchResource); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (pool SetPool) findResourceToFree(raw RawResourceProps) (*ent.Resource, error) {
+	query, err := pool.findResource(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find resource")
+	}
+	res, err := query.WithProperties().Only(pool.ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find resource")
+	}
+	return res, nil
+}
+
+func (pool SetPool) findResourceToFreeById(resourceId int) (*ent.Resource, error) {
+	res, err := pool.client.Resource.Query().
+		Where(resource.ID(resourceId)).
+		Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).
+		WithProperties().
+		Only(pool.ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find resource #%d", resourceId)
+	}
+	return res, nil
+}
+
+// acceptMovedResource claims the value of a resource moved from another pool, the value has to be available
+func (pool SetPool) acceptMovedResource(moved *ent.Resource) (*ent.Resource, error) {
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
+	}
+
+	raw, err := PropertiesToMap(moved.Edges.Properties)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to serialize properties of resource #%d", moved.ID)
+	}
+	res, err := pool.findResourceToFree(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Pool \"%s\" does not contain resource %v", pool.Name, raw)
+	}
+
+	switch res.Status {
+	case resource.StatusFree:
+	case resource.StatusBench:
+		cutoff := res.UpdatedAt.Add(time.Duration(pool.DealocationSafetyPeriod) * time.Second)
+		if time.Now().Before(cutoff) {
+			return nil, errors.Errorf("Resource %v in pool \"%s\" cannot be claimed before %s", raw, pool.Name, cutoff)
+		}
+	default:
+		return nil, errors.Errorf("Resource %v in pool \"%s\" is %s", raw, pool.Name, res.Status)
+	}
+
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := pool.client.Resource.UpdateOne(res).
+		SetStatus(resource.StatusClaimed).
+		SetNillableDescription(moved.Description).
+		SetAlternateID(moved.AlternateID).
+		Save(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to claim moved resource ID %d in pool ID %d", moved.ID, pool.ID)
+		return nil, errors.Wrapf(err, "Unable to claim resource %v in pool \"%s\"", raw, pool.Name)
+	}
+	pool.trackStatusChange(res.Status, resource.StatusClaimed)
+	if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventClaim, pool.ID, claimed.ID, before); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
 func (pool SetPool) benchResource(res *ent.Resource) error {
-	return pool.client.Resource.UpdateOne(res).SetStatus(resource.StatusBench).Exec(pool.ctx)
+	return pool.unclaimWithStatus(res, resource.StatusBench)
 }
 
 func (pool SetPool) freeResourceImmediately(res *ent.Resource) error {
-	return pool.client.Resource.UpdateOne(res).SetStatus(resource.StatusFree).Exec(pool.ctx)
+	return pool.unclaimWithStatus(res, resource.StatusFree)
 }
 
 func (pool SetPool) retireResource(res *ent.Resource) error {
-	return pool.client.Resource.UpdateOne(res).SetStatus(resource.StatusRetired).Exec(pool.ctx)
+	return pool.unclaimWithStatus(res, resource.StatusRetired)
+}
+
+func (pool SetPool) unclaimWithStatus(res *ent.Resource, status resource.Status) error {
+	if err := pool.setUnclaimedStatus(res, status); err != nil {
+		return err
+	}
+	pool.trackStatusChange(res.Status, status)
+	return nil
+}
+
+// setUnclaimedStatus changes status of a resource leaving it in the pool, capacity is tracked by the caller
+func (pool SetPool) setUnclaimedStatus(res *ent.Resource, status resource.Status) error {
+	return pool.client.Resource.UpdateOne(res).SetStatus(status).ClearLeaseExpiresAt().Exec(pool.ctx)
+}
+
+// usesSetPoolCapacity tells whether a resource of the status uses capacity of a set or singleton pool
+func usesSetPoolCapacity(status resource.Status) bool {
+	return status == resource.StatusClaimed || status == resource.StatusReserved
+}
+
+// trackStatusChange records the change of capacity caused by a resource of the pool changing its status
+func (pool SetPool) trackStatusChange(from resource.Status, to resource.Status) {
+	switch {
+	case !usesSetPoolCapacity(from) && usesSetPoolCapacity(to):
+		pool.capacityChange.add(-1, 1)
+	case usesSetPoolCapacity(from) && !usesSetPoolCapacity(to):
+		pool.capacityChange.add(1, -1)
+	}
 }
 
 func (pool SetPool) findResource(raw RawResourceProps) (*ent.ResourceQuery, error) {
@@ -275,11 +810,86 @@ func (pool SetPool) queryUnclaimedResourceEager() (*ent.Resource, error) {
 	return res, err
 }
 
+// load count unclaimed resources, free ones first and then benched ones past their safety period
+func (pool SetPool) queryUnclaimedResourcesEager(count int) (ent.Resources, error) {
+	res, err := pool.findResources().
+		Where(resource.StatusEQ(resource.StatusFree)).
+		Order(ent.Asc(resource.FieldID)).
+		Limit(count).
+		All(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve free resources in pool ID %d", pool.ID)
+		return nil, err
+	}
+
+	if len(res) < count {
+		benched, err := pool.findResources().
+			Where(resource.StatusEQ(resource.StatusBench)).
+			Where(resource.UpdatedAtLT(time.Now().Add(time.Duration(-pool.ResourcePool.DealocationSafetyPeriod) * time.Second))).
+			Order(ent.Asc(resource.FieldID)).
+			Limit(count - len(res)).
+			All(pool.ctx)
+		if err != nil {
+			log.Error(pool.ctx, err, "Unable to retrieve benched resources in pool ID %d", pool.ID)
+			return nil, err
+		}
+		res = append(res, benched...)
+	}
+
+	if len(res) < count {
+		err := errors.Errorf("Not enough free resources in the pool: \"%s\", requested %d, available %d",
+			pool.Name, count, len(res))
+		log.Error(pool.ctx, err, "Not enough free resources in pool ID %d", pool.ID)
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (pool SetPool) findResources() *ent.ResourceQuery {
 	return pool.client.Resource.Query().
 		Where(resource.HasPoolWith(resourcePool.ID(pool.ID)))
 }
 
+// QueryClaimedResourceByAltId returns the claimed or reserved resource whose alternative ID is exactly alternativeId,
+// nil if there is none. Resources with additional keys in their alternative ID do not match.
+func (pool SetPool) QueryClaimedResourceByAltId(alternativeId map[string]interface{}) (*ent.Resource, error) {
+	altId := make(map[string]interface{}, len(alternativeId))
+	for k, v := range alternativeId {
+		altId[k] = v
+	}
+	altId, err := ConvertValuesToFloat64(pool.ctx, altId)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := pool.findResources().
+		Where(resource.StatusIn(resource.StatusClaimed, resource.StatusReserved)).
+		Where(HasAlternativeId(altId)).
+		All(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve resources with alternative ID %v in pool ID %d", alternativeId, pool.ID)
+		return nil, errors.Wrapf(err, "Unable to query resources by alternative ID in pool \"%s\"", pool.Name)
+	}
+	// HasAlternativeId matches alternative IDs containing the requested keys, only the same number of keys is exact
+	var res []*ent.Resource
+	for _, candidate := range candidates {
+		if len(candidate.AlternateID) == len(altId) {
+			res = append(res, candidate)
+		}
+	}
+
+	switch len(res) {
+	case 0:
+		return nil, nil
+	case 1:
+		return res[0], nil
+	default:
+		return nil, errors.Errorf("Unable to find resource by alternative ID %v in pool \"%s\", %d resources match",
+			alternativeId, pool.Name, len(res))
+	}
+}
+
 // QueryResources returns all allocated resources
 func (pool SetPool) QueryResources() (ent.Resources, error) {
 	res, err := pool.findResources().
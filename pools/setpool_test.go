@@ -1,6 +1,7 @@
 package pools
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -170,3 +171,308 @@ func TestResourceDealocationSafetyWindow(t *testing.T) {
 	pool.FreeResource(RawResourceProps{"vlan": *claim1Again.QueryProperties().AllX(ctx)[0].IntVal})
 	assertDbResourceStates(ctx, client, t, 0, 0, 2, 0)
 }
+
+func TestClaimResourcesSetPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+		RawResourceProps{"vlan": 46},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+
+	userInput := make(map[string]interface{})
+	if _, err := pool.ClaimResources(4, userInput, nil, nil); err == nil {
+		t.Fatalf("Claiming more resources than available should return error")
+	}
+	assertDbResourceStates(ctx, client, t, 3, 0, 0, 0)
+
+	claims, err := pool.ClaimResources(2, userInput, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 2 {
+		t.Fatalf("Expected 2 claims, got: %d", len(claims))
+	}
+	assertDbResourceStates(ctx, client, t, 1, 2, 0, 0)
+
+	if _, err := pool.ClaimResources(2, userInput, nil, nil); err == nil {
+		t.Fatalf("Claiming more resources than available should return error")
+	}
+	assertDbResourceStates(ctx, client, t, 1, 2, 0, 0)
+
+	if _, err := pool.ClaimResources(0, userInput, nil, nil); err == nil {
+		t.Fatalf("Claiming zero resources should return error")
+	}
+}
+
+func TestFreeResourcesSetPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+		RawResourceProps{"vlan": 46},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+
+	userInput := make(map[string]interface{})
+	claims, err := pool.ClaimResources(2, userInput, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed := []RawResourceProps{
+		{"vlan": *claims[0].QueryProperties().OnlyX(ctx).IntVal},
+	}
+
+	// second resource is fine, but unknown and unclaimed resources make the whole call fail
+	err = pool.FreeResources(
+		append(claimed, RawResourceProps{"vlan": 99}, RawResourceProps{"vlan": 46}),
+		[]int{claims[1].ID, claims[1].ID})
+	var freeErr *FreeResourcesError
+	if !errors.As(err, &freeErr) {
+		t.Fatalf("Expected FreeResourcesError, got: %v", err)
+	}
+	if len(freeErr.Failures) != 3 {
+		t.Fatalf("Expected 3 failures, got: %v", freeErr.Failures)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 2, 0, 0)
+
+	if err := pool.FreeResources(claimed, []int{claims[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 3, 0, 0, 0)
+}
+
+func TestReserveResourceSetPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "set", nil, 60)
+
+	userInput := make(map[string]interface{})
+	if _, err := pool.ReserveResource(userInput, nil, nil, 0); err == nil {
+		t.Fatalf("Reserving with non positive ttl should return error")
+	}
+
+	reserved1, err := pool.ReserveResource(userInput, nil, nil, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reserved2, err := pool.ReserveResource(userInput, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reserved resources are utilized and cannot be claimed
+	if capacity, _ := pool.Capacity(); capacity.Free.Int64() != 0 || capacity.Utilized.Int64() != 2 {
+		t.Fatalf("Expected capacity 0 free and 2 utilized, got %s free and %s utilized", capacity.Free, capacity.Utilized)
+	}
+	if _, err := pool.ClaimResource(userInput, nil, nil); err == nil {
+		t.Fatalf("Claiming from pool with all resources reserved should return error")
+	}
+	if err := pool.FreeResources(nil, []int{reserved1.ID}); err == nil {
+		t.Fatalf("Freeing reserved resource should return error")
+	}
+
+	claimed, err := pool.CommitReservation(reserved1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed.LeaseExpiresAt != nil {
+		t.Fatalf("Committed reservation should not expire")
+	}
+	if _, err := pool.CommitReservation(reserved1.ID); err == nil {
+		t.Fatalf("Committing claimed resource should return error")
+	}
+	assertDbResourceStates(ctx, client, t, 0, 1, 0, 0)
+
+	time.Sleep(time.Duration(1100) * time.Millisecond)
+
+	if _, err := pool.CommitReservation(reserved2.ID); err == nil {
+		t.Fatalf("Committing expired reservation should return error")
+	}
+
+	// expired reservation returns to the pool without the safety period
+	if cancelled, err := CancelExpiredReservations(ctx, client, pool.(*SetPool).ID); err != nil || cancelled != 1 {
+		t.Fatalf("Expected 1 expired reservation to be cancelled, cancelled: %d, error: %v", cancelled, err)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 1, 0, 0)
+
+	if err := pool.CancelReservation(reserved2.ID); err == nil {
+		t.Fatalf("Cancelling already cancelled reservation should return error")
+	}
+}
+
+func TestClaimResourceIdempotentSetPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+		RawResourceProps{"vlan": 46},
+		RawResourceProps{"vlan": 47},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately)
+
+	userInput := make(map[string]interface{})
+	if _, err := ClaimResourceIdempotent(pool, userInput, nil, nil); err == nil {
+		t.Fatalf("Idempotent claim without alternative ID should return error")
+	}
+
+	altId := map[string]interface{}{"device": "R1", "unit": 1}
+	claim1, err := ClaimResourceIdempotent(pool, userInput, nil, altId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := ClaimResourceIdempotent(pool, userInput, nil, map[string]interface{}{"device": "R1", "unit": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.ID != claim1.ID {
+		t.Fatalf("Retried claim should return resource %d, got %d", claim1.ID, retried.ID)
+	}
+	assertDbResourceStates(ctx, client, t, 3, 1, 0, 0)
+
+	claim2, err := ClaimResourceIdempotent(pool, userInput, nil, map[string]interface{}{"device": "R1", "unit": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim2.ID == claim1.ID {
+		t.Fatalf("Claim with different alternative ID should return a different resource")
+	}
+	assertDbResourceStates(ctx, client, t, 2, 2, 0, 0)
+
+	// only an exact alternative ID matches, a partial one belongs to a different caller
+	claim3, err := ClaimResourceIdempotent(pool, userInput, nil, map[string]interface{}{"device": "R1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim3.ID == claim1.ID || claim3.ID == claim2.ID {
+		t.Fatalf("Claim with partial alternative ID should return a different resource")
+	}
+	assertDbResourceStates(ctx, client, t, 1, 3, 0, 0)
+
+	// retrying a claim whose resource is only reserved must not allocate a second resource
+	if _, err := pool.ReserveResource(userInput, nil, map[string]interface{}{"order": "A"}, 60); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ClaimResourceIdempotent(pool, userInput, nil, map[string]interface{}{"order": "A"}); err == nil {
+		t.Fatalf("Idempotent claim of a reserved resource should return error")
+	}
+	assertDbResourceStates(ctx, client, t, 0, 3, 0, 0)
+
+	// freed resource is not returned again
+	if err := pool.FreeResources(nil, []int{claim1.ID}); err != nil {
+		t.Fatal(err)
+	}
+	reclaimed, err := ClaimResourceIdempotent(pool, userInput, nil, altId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 0, 3, 0, 0)
+	if reclaimed.AlternateID["device"] != "R1" {
+		t.Fatalf("Reclaimed resource should carry the alternative ID, got %v", reclaimed.AlternateID)
+	}
+}
+
+func TestDuplicateValuesSetPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	if _, err := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 44},
+	}, "set", nil, schema.ResourcePoolDealocationImmediately); err == nil {
+		t.Fatalf("Creating set pool with duplicate values should fail")
+	}
+}
+
+func TestAddAndRemoveSetPoolValues(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, _ := NewSetPool(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+	}, "set", nil, 60)
+	setPool := pool.(*SetPool)
+
+	if _, err := setPool.AddValues([]RawResourceProps{{"vlan": 46}, {"vlan": 45}}); err == nil {
+		t.Fatalf("Adding value already present in the pool should fail")
+	}
+	if _, err := setPool.AddValues([]RawResourceProps{{"vlan": 46}, {"vlan": 46}}); err == nil {
+		t.Fatalf("Adding duplicate values should fail")
+	}
+	assertInstancesInDb(client.Resource.Query().AllX(ctx), 2, t)
+
+	added, err := setPool.AddValues([]RawResourceProps{{"vlan": 46}, {"vlan": 47}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 {
+		t.Fatalf("Expected 2 added resources, got: %d", len(added))
+	}
+	assertDbResourceStates(ctx, client, t, 4, 0, 0, 0)
+
+	claimed, _ := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	benched, _ := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+	if err := pool.FreeResources(nil, []int{benched.ID}); err != nil {
+		t.Fatal(err)
+	}
+	claimedVlan, _ := PropertiesToMap(claimed.QueryProperties().WithType().AllX(ctx))
+	benchedVlan, _ := PropertiesToMap(benched.QueryProperties().WithType().AllX(ctx))
+
+	if err := setPool.RemoveValues([]RawResourceProps{{"vlan": 47}, claimedVlan}, false); err == nil {
+		t.Fatalf("Removing claimed value should fail")
+	}
+	if err := setPool.RemoveValues([]RawResourceProps{{"vlan": 47}, benchedVlan}, false); err == nil {
+		t.Fatalf("Removing benched value within safety period should fail")
+	}
+	assertDbResourceStates(ctx, client, t, 2, 1, 1, 0)
+
+	if err := setPool.RemoveValues([]RawResourceProps{{"vlan": 47}}, false); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 1, 1, 0)
+
+	if err := setPool.RemoveValues([]RawResourceProps{claimedVlan, benchedVlan}, true); err != nil {
+		t.Fatal(err)
+	}
+	assertDbResourceStates(ctx, client, t, 1, 0, 0, 0)
+	assertInstancesInDb(client.Property.Query().AllX(ctx), 1, t)
+}
@@ -3,9 +3,9 @@ package pools
 import (
 	"context"
 	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourceevent"
 	log "github.com/net-auto/resourceManager/logging"
 	"github.com/pkg/errors"
-	"strconv"
 
 	"github.com/net-auto/resourceManager/ent"
 	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
@@ -41,13 +41,26 @@ func NewSingletonPoolWithMeta(
 		return nil, nil, err
 	}
 
-	return &SingletonPool{SetPool{poolBase{pool, ctx, client}}}, pool, nil
+	return &SingletonPool{SetPool{newPoolBase(pool, ctx, client)}}, pool, nil
 }
 
 func (pool SingletonPool) ClaimResource(userInput map[string]interface{}, description *string,
 	alternativeId map[string]interface{}) (*ent.Resource, error) {
+	if err := pool.checkClaimable(); err != nil {
+		return nil, err
+	}
 
-	_, err := pool.client.Resource.Update().
+	res, err := pool.client.Resource.Query().Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).Only(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve resource in pool ID %d", pool.ID)
+		return nil, err
+	}
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = pool.client.Resource.Update().
 		SetStatus(resource.StatusClaimed).
 		SetAlternateID(alternativeId).
 		Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).
@@ -58,29 +71,91 @@ func (pool SingletonPool) ClaimResource(userInput map[string]interface{}, descri
 		return nil, err
 	}
 
+	pool.trackStatusChange(res.Status, resource.StatusClaimed)
+
 	if description != nil {
 		log.Warn(pool.ctx, "Description for a resource from singleton pool will be ignored")
 	}
+	if err := RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventClaim, pool.ID, res.ID, before); err != nil {
+		return nil, err
+	}
 
 	return pool.client.Resource.Query().Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).Only(pool.ctx)
 }
 
+// ClaimResources can only ever provide the single resource of the pool
+func (pool SingletonPool) ClaimResources(count int, userInput map[string]interface{}, description *string,
+	alternativeId map[string]interface{}) (ent.Resources, error) {
+
+	if count != 1 {
+		log.Warn(pool.ctx, "Unable to claim %d resources in singleton pool ID %d", count, pool.ID)
+		return nil, errors.Errorf("Unable to claim %d resources in pool \"%s\", singleton pool provides exactly one resource",
+			count, pool.Name)
+	}
+
+	res, err := pool.ClaimResource(userInput, description, alternativeId)
+	if err != nil {
+		return nil, err
+	}
+
+	return ent.Resources{res}, nil
+}
+
+// ReserveResource holds the single resource of the pool, only possible when nobody holds it
+func (pool SingletonPool) ReserveResource(userInput map[string]interface{}, description *string,
+	alternativeId map[string]interface{}, ttlSeconds int) (*ent.Resource, error) {
+	if err := pool.checkReservationTtl(ttlSeconds); err != nil {
+		return nil, err
+	}
+
+	res, err := pool.client.Resource.Query().Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).Only(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve resource in pool ID %d", pool.ID)
+		return nil, err
+	}
+	if res.Status != resource.StatusFree {
+		return nil, errors.Errorf("Unable to reserve resource in pool \"%s\", it is %s", pool.Name, res.Status)
+	}
+
+	res, err = pool.ClaimResource(userInput, description, alternativeId)
+	if err != nil {
+		return nil, err
+	}
+	return pool.reserveResource(res, ttlSeconds)
+}
+
 func (pool SingletonPool) FreeResource(raw RawResourceProps) error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
+	res, err := pool.client.Resource.Query().Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).Only(pool.ctx)
+	if err != nil {
+		log.Error(pool.ctx, err, "Unable to retrieve resource in pool ID %d", pool.ID)
+		return err
+	}
+	before, err := ResourceState(pool.ctx, res, pool.ID)
+	if err != nil {
+		return err
+	}
+
 	pool.client.Resource.Update().
 		SetStatus(resource.StatusFree).
+		ClearLeaseExpiresAt().
 		Where(resource.HasPoolWith(resourcePool.ID(pool.ID))).
 		Save(pool.ctx)
-	return nil
+	pool.trackStatusChange(res.Status, resource.StatusFree)
+	return RecordResourceEvent(pool.ctx, pool.client, resourceevent.EventFree, pool.ID, res.ID, before)
 }
 
-func (pool SingletonPool) Capacity() (string, string, error) {
+func (pool SingletonPool) Capacity() (*PoolCapacity, error) {
 	claimedResources, err := pool.QueryResources()
 
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable to retrieve resources in pool ID %d", pool.ID)
-		return "0", "0", err
+		return nil, err
 	}
-	return strconv.Itoa(1 - len(claimedResources)), strconv.Itoa(len(claimedResources)), nil
+	return newPoolCapacityFromInts(1-len(claimedResources), len(claimedResources)), nil
 }
 
 // QueryResource returns always the same resource
@@ -99,7 +174,7 @@ func (pool SingletonPool) QueryResources() (ent.Resources, error) {
 	all, err := pool.client.Resource.Query().Where(
 		resource.And(
 			resource.HasPoolWith(resourcePool.ID(pool.ID)),
-			resource.StatusIn(resource.StatusBench, resource.StatusClaimed))).All(pool.ctx)
+			resource.StatusIn(resource.StatusBench, resource.StatusClaimed, resource.StatusReserved))).All(pool.ctx)
 
 	if err != nil {
 		log.Error(pool.ctx, err, "Unable retrieve resources for pool ID %d", pool.ID)
@@ -109,6 +184,10 @@ func (pool SingletonPool) QueryResources() (ent.Resources, error) {
 }
 
 func (pool SingletonPool) Destroy() error {
+	if err := pool.checkMutable(); err != nil {
+		return err
+	}
+
 	claims, errQr := pool.QueryResources()
 
 	if errQr != nil {
@@ -36,3 +36,60 @@ func TestNewSingletonPool(t *testing.T) {
 	}
 	assertDb(ctx, client, t, 1, 1, 0, 1, 0)
 }
+
+func TestClaimResourcesSingletonPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+	pool, err := NewSingletonPool(ctx, client, resType, map[string]interface{}{
+		"vlan": 44,
+	}, "singleton", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.ClaimResources(2, map[string]interface{}{}, nil, nil); err == nil {
+		t.Fatalf("Claiming 2 resources from singleton pool should return error")
+	}
+
+	claims, err := pool.ClaimResources(1, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 1 {
+		t.Fatalf("Expected 1 claim, got: %d", len(claims))
+	}
+}
+
+func TestClaimResourceIdempotentSingletonPool(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+	pool, err := NewSingletonPool(ctx, client, resType, map[string]interface{}{
+		"vlan": 44,
+	}, "singleton", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	altId := map[string]interface{}{"device": "R1"}
+	claimed, err := ClaimResourceIdempotent(pool, map[string]interface{}{}, nil, altId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := ClaimResourceIdempotent(pool, map[string]interface{}{}, nil, altId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.ID != claimed.ID || len(retried.AlternateID) != 1 {
+		t.Fatalf("Retried claim should return the same resource, got %v", retried)
+	}
+}
@@ -0,0 +1,332 @@
+package pools
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/allocationstrategyrevision"
+	"github.com/net-auto/resourceManager/ent/resource"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// StrategyUpgrade is the outcome of upgrading a pool to another revision of its allocation strategy
+type StrategyUpgrade struct {
+	Pool         *ent.ResourcePool
+	FromRevision int
+	ToRevision   int
+	// Capacity of the pool computed by the new revision
+	Capacity *PoolCapacity
+	// Claim is the resource the next claim would allocate with the new revision, nil if the pool is full
+	Claim RawResourceProps
+	// DryRunError describes why the new revision is not compatible with current claims of the pool
+	DryRunError *string
+	Upgraded    bool
+}
+
+// StrategyAtRevision returns a copy of the strategy running the script of the revision
+func StrategyAtRevision(strat *ent.AllocationStrategy, revision *ent.AllocationStrategyRevision) *ent.AllocationStrategy {
+	pinned := *strat
+	pinned.Lang = allocationstrategy.Lang(revision.Lang)
+	pinned.Script = revision.Script
+	return &pinned
+}
+
+// LatestStrategyRevision loads the latest revision of the strategy. Strategies created before revisions
+// were introduced get their first revision stored from their current script.
+func LatestStrategyRevision(ctx context.Context, client *ent.Client, strat *ent.AllocationStrategy) (
+	*ent.AllocationStrategyRevision, error) {
+	latest, err := strat.QueryRevisions().
+		Order(ent.Desc(allocationstrategyrevision.FieldRevision)).
+		First(ctx)
+	if err == nil {
+		return latest, nil
+	}
+	if !ent.IsNotFound(err) {
+		log.Error(ctx, err, "Unable to load revisions of allocation strategy %d", strat.ID)
+		return nil, errors.Wrapf(err, "Unable to load revisions of allocation strategy #%d", strat.ID)
+	}
+
+	latest, err = client.AllocationStrategyRevision.Create().
+		SetRevision(1).
+		SetLang(allocationstrategyrevision.Lang(strat.Lang)).
+		SetScript(strat.Script).
+		SetAllocationStrategy(strat).
+		Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to store first revision of allocation strategy %d", strat.ID)
+		return nil, errors.Wrapf(err, "Unable to store first revision of allocation strategy #%d", strat.ID)
+	}
+	return latest, nil
+}
+
+// PoolStrategyRevision loads the revision of the strategy the pool runs, nil for pools without a strategy
+func PoolStrategyRevision(ctx context.Context, client *ent.Client, pool *ent.ResourcePool) (
+	*ent.AllocationStrategyRevision, error) {
+	pinned, err := pool.QueryStrategyRevision().Only(ctx)
+	if err == nil || !ent.IsNotFound(err) {
+		return pinned, err
+	}
+	strat, err := pool.QueryAllocationStrategy().Only(ctx)
+	if ent.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return LatestStrategyRevision(ctx, client, strat)
+}
+
+// UpdateAllocationStrategy stores a new revision of the strategy. Pools running the latest revision are pinned
+// to it first, so that they keep their behaviour until upgraded by UpgradePoolStrategy.
+// If testInvoker is set, the new revision is stored only if it passes all test cases of the strategy.
+func UpdateAllocationStrategy(ctx context.Context, client *ent.Client, strategyId int,
+	lang *allocationstrategy.Lang, script string, description *string, testInvoker ScriptInvoker) (
+	*ent.AllocationStrategy, error) {
+	strat, err := client.AllocationStrategy.Get(ctx, strategyId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find allocation strategy #%d", strategyId)
+	}
+	if lang == nil {
+		lang = &strat.Lang
+	}
+	if strat.Lang == allocationstrategy.LangGo || *lang == allocationstrategy.LangGo {
+		return nil, errors.Errorf("Unable to update allocation strategy %s, go strategies are compiled in", strat.Name)
+	}
+	if *lang == strat.Lang && script == strat.Script {
+		return nil, errors.Errorf("Unable to update allocation strategy %s, script is unchanged", strat.Name)
+	}
+
+	if testInvoker != nil {
+		testCases, err := strat.QueryTestCases().All(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to load test cases of allocation strategy %s", strat.Name)
+		}
+		candidate := *strat
+		candidate.Lang = *lang
+		candidate.Script = script
+		if err := RequirePassingStrategyTests(ctx, testInvoker, &candidate, testCases); err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := LatestStrategyRevision(ctx, client, strat)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.ResourcePool.Update().
+		Where(resourcePool.HasAllocationStrategyWith(allocationstrategy.ID(strat.ID))).
+		Where(resourcePool.Not(resourcePool.HasStrategyRevision())).
+		SetStrategyRevision(current).
+		Exec(ctx); err != nil {
+		log.Error(ctx, err, "Unable to pin pools to revision %d of allocation strategy %d", current.Revision, strat.ID)
+		return nil, errors.Wrapf(err, "Unable to pin pools to revision %d of allocation strategy %s",
+			current.Revision, strat.Name)
+	}
+
+	if err := client.AllocationStrategyRevision.Create().
+		SetRevision(current.Revision + 1).
+		SetLang(allocationstrategyrevision.Lang(*lang)).
+		SetScript(script).
+		SetAllocationStrategy(strat).
+		Exec(ctx); err != nil {
+		log.Error(ctx, err, "Unable to store revision %d of allocation strategy %d", current.Revision+1, strat.ID)
+		return nil, errors.Wrapf(err, "Unable to store revision %d of allocation strategy %s",
+			current.Revision+1, strat.Name)
+	}
+
+	updated, err := client.AllocationStrategy.UpdateOne(strat).
+		SetLang(*lang).
+		SetScript(script).
+		SetNillableDescription(description).
+		Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to update allocation strategy %d", strat.ID)
+		return nil, errors.Wrapf(err, "Unable to update allocation strategy %s", strat.Name)
+	}
+	return updated, nil
+}
+
+func strategyRevision(ctx context.Context, strat *ent.AllocationStrategy, revision int) (
+	*ent.AllocationStrategyRevision, error) {
+	found, err := strat.QueryRevisions().
+		Where(allocationstrategyrevision.Revision(revision)).
+		Only(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find revision %d of allocation strategy %s", revision, strat.Name)
+	}
+	return found, nil
+}
+
+// UpgradePoolStrategy switches an allocating pool to another revision of its strategy, the latest by default.
+// The revision is dry-run against current claims of the pool first, the pool is upgraded only if the dry run
+// succeeds and dryRunOnly is false. A failed dry run is reported in the result, not as an error.
+func UpgradePoolStrategy(ctx context.Context, client *ent.Client, poolId int, revision *int,
+	userInput map[string]interface{}, dryRunOnly bool) (*StrategyUpgrade, error) {
+	pool, err := ExistingPoolFromId(ctx, client, poolId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to find pool #%d", poolId)
+	}
+	allocatingPool, ok := pool.(*AllocatingPool)
+	if !ok {
+		return nil, errors.Errorf("Unable to upgrade strategy of pool #%d, only allocating pools have strategies", poolId)
+	}
+	return allocatingPool.upgradeStrategy(revision, userInput, dryRunOnly)
+}
+
+func (pool AllocatingPool) upgradeStrategy(revision *int, userInput map[string]interface{}, dryRunOnly bool) (
+	*StrategyUpgrade, error) {
+	ctx, client, poolId := pool.ctx, pool.client, pool.ID
+	if err := pool.checkMutable(); err != nil {
+		return nil, err
+	}
+
+	strat, err := pool.QueryAllocationStrategy().Only(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to load allocation strategy of pool #%d", poolId)
+	}
+	from, err := PoolStrategyRevision(ctx, client, pool.ResourcePool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to load strategy revision of pool #%d", poolId)
+	}
+	to, err := LatestStrategyRevision(ctx, client, strat)
+	if err != nil {
+		return nil, err
+	}
+	if revision != nil {
+		if to, err = strategyRevision(ctx, strat, *revision); err != nil {
+			return nil, err
+		}
+	}
+
+	upgrade := &StrategyUpgrade{
+		Pool:         pool.ResourcePool,
+		FromRevision: from.Revision,
+		ToRevision:   to.Revision,
+	}
+	upgrade.Capacity, upgrade.Claim, err = pool.dryRunStrategy(StrategyAtRevision(strat, to), userInput)
+	if err != nil {
+		dryRunError := fmt.Sprintf("Revision %d of allocation strategy %s is not compatible with pool %s: %v",
+			to.Revision, strat.Name, pool.Name, err)
+		upgrade.DryRunError = &dryRunError
+		return upgrade, nil
+	}
+	if dryRunOnly {
+		return upgrade, nil
+	}
+
+	if upgrade.Pool, err = client.ResourcePool.UpdateOneID(poolId).SetStrategyRevision(to).Save(ctx); err != nil {
+		log.Error(ctx, err, "Unable to pin pool %d to revision %d of allocation strategy %d", poolId, to.Revision, strat.ID)
+		return nil, errors.Wrapf(err, "Unable to upgrade strategy of pool #%d", poolId)
+	}
+	// capacity of all resources is measured by the new revision
+	if _, err := recomputePoolCapacity(&pool); err != nil {
+		return nil, err
+	}
+	upgrade.Upgraded = true
+	return upgrade, nil
+}
+
+// dryRunStrategy runs a strategy against current claims of the pool without storing anything. Claims have to fit
+// the pool (if the strategy is able to validate resources), the capacity has to be computed and the next claim
+// must not allocate a resource which is already in use.
+func (pool AllocatingPool) dryRunStrategy(strat *ent.AllocationStrategy, userInput map[string]interface{}) (
+	*PoolCapacity, RawResourceProps, error) {
+	_, propMap, _, err := pool.loadStrategyInput()
+	if err != nil {
+		return nil, nil, err
+	}
+	resourcePool := model.ResourcePoolInput{
+		ResourcePoolID:   pool.ID,
+		PoolProperties:   propMap,
+		ResourcePoolName: pool.Name,
+	}
+
+	validator, err := newResourceValidator(pool.ctx, strat, resourcePool, propMap)
+	if err != nil {
+		return nil, nil, err
+	}
+	if validator != nil {
+		claims, err := pool.QueryClaims().
+			Where(resource.StatusIn(resource.StatusClaimed, resource.StatusReserved)).
+			WithProperties(func(propertyQuery *ent.PropertyQuery) { propertyQuery.WithType() }).
+			All(pool.ctx)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Unable to get claimed resources from pool #%d", pool.ID)
+		}
+		outside, err := ResourcesOutsideOfPoolProperties(pool.ctx, strat, resourcePool, propMap, claims)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(outside) > 0 {
+			return nil, nil, errors.Errorf("%d claimed resource(s) do not fit the pool, e.g. resource #%d",
+				len(outside), outside[0].ID)
+		}
+	}
+
+	var currentResources []*model.ResourceInput
+	if !isManualSqlExecutionStrategy(strat) {
+		if currentResources, err = getFullListOfResources(pool); err != nil {
+			return nil, nil, err
+		}
+	}
+	capacity, err := pool.strategyCapacity(strat, propMap, currentResources)
+	if err != nil {
+		return nil, nil, err
+	}
+	if capacity.Free.Cmp(big.NewInt(0)) <= 0 {
+		return capacity, nil, nil
+	}
+
+	functionName := "invoke()"
+	if strat.Lang == allocationstrategy.LangPy {
+		functionName = "script_fun()"
+	}
+	claim, stdErr, err := InvokeAllocationStrategy(
+		pool.ctx, pool.invoker, strat, userInput, resourcePool, currentResources, propMap, functionName)
+	appendStrategyLog(pool.ctx, stdErr)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "claim failed")
+	}
+	query, err := pool.findResource(RawResourceProps(claim))
+	if err != nil {
+		return nil, nil, err
+	}
+	used, err := query.Where(resource.StatusIn(
+		resource.StatusClaimed, resource.StatusReserved, resource.StatusRetired)).First(pool.ctx)
+	if err == nil {
+		return nil, nil, errors.Errorf("next claim would allocate resource #%d which is already %s", used.ID, used.Status)
+	} else if !ent.IsNotFound(err) {
+		return nil, nil, err
+	}
+	return capacity, claim, nil
+}
+
+// DiffStrategyRevisions compares scripts of two revisions of a strategy in unified diff format
+func DiffStrategyRevisions(ctx context.Context, client *ent.Client, strategyId int, from int, to int) (string, error) {
+	strat, err := client.AllocationStrategy.Get(ctx, strategyId)
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to find allocation strategy #%d", strategyId)
+	}
+	fromRevision, err := strategyRevision(ctx, strat, from)
+	if err != nil {
+		return "", err
+	}
+	toRevision, err := strategyRevision(ctx, strat, to)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromRevision.Script),
+		B:        difflib.SplitLines(toRevision.Script),
+		FromFile: fmt.Sprintf("%s@%d (%s)", strat.Name, from, fromRevision.Lang),
+		ToFile:   fmt.Sprintf("%s@%d (%s)", strat.Name, to, toRevision.Lang),
+		Context:  3,
+	})
+}
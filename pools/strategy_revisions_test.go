@@ -0,0 +1,119 @@
+package pools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/schema"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+// scriptInvoker allocates the vlan given by the strategy script
+type scriptInvoker map[string]int
+
+func (m scriptInvoker) invokeJs(
+	strategyScript string,
+	userInput map[string]interface{},
+	resourcePool model.ResourcePoolInput,
+	currentResources []*model.ResourceInput,
+	poolPropertiesMaps map[string]interface{},
+	functionName string,
+) (map[string]interface{}, string, error) {
+	if functionName == "capacity()" {
+		return map[string]interface{}{
+			"freeCapacity":     float64(10 - len(currentResources)),
+			"utilizedCapacity": float64(len(currentResources)),
+		}, "", nil
+	}
+	return map[string]interface{}{"vlan": m[strategyScript]}, "", nil
+}
+
+func (m scriptInvoker) invokePy(
+	strategyScript string,
+	userInput map[string]interface{},
+	resourcePool model.ResourcePoolInput,
+	currentResources []*model.ResourceInput,
+	poolPropertiesMaps map[string]interface{},
+	functionName string,
+) (map[string]interface{}, string, error) {
+	return m.invokeJs(strategyScript, userInput, resourcePool, currentResources, poolPropertiesMaps, functionName)
+}
+
+func TestStrategyRevisions(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+	strat := client.AllocationStrategy.Create().
+		SetName("revisioned").
+		SetLang(allocationstrategy.LangJs).
+		SetScript("first").
+		SaveX(ctx)
+	invoker := scriptInvoker{"first": 1, "second": 1, "third": 3}
+
+	pool, _, err := newAllocatingPoolWithMetaInternal(ctx, client, resType, strat, "revisioned", nil,
+		invoker, schema.ResourcePoolDealocationImmediately, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.ClaimResource(map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UpdateAllocationStrategy(ctx, client, strat.ID, nil, "first", nil, nil); err == nil {
+		t.Fatalf("Updating strategy with an unchanged script should fail")
+	}
+	if _, err := UpdateAllocationStrategy(ctx, client, strat.ID, nil, "second", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	allocatingPool := pool.(*AllocatingPool)
+	if pinned, err := allocatingPool.AllocationStrategy(); err != nil || pinned.Script != "first" {
+		t.Fatalf("Expected pool pinned to the first revision, got %v %v", pinned, err)
+	}
+
+	// the second revision allocates the vlan claimed already
+	upgrade, err := allocatingPool.upgradeStrategy(nil, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgrade.Upgraded || upgrade.DryRunError == nil || upgrade.FromRevision != 1 || upgrade.ToRevision != 2 ||
+		!strings.Contains(*upgrade.DryRunError, "already claimed") {
+		t.Fatalf("Expected failed dry run of revision 2, got %+v", upgrade)
+	}
+
+	if _, err := UpdateAllocationStrategy(ctx, client, strat.ID, nil, "third", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	upgrade, err = allocatingPool.upgradeStrategy(nil, map[string]interface{}{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgrade.Upgraded || upgrade.DryRunError != nil || upgrade.Claim["vlan"] != 3 ||
+		upgrade.Capacity.Free.Int64() != 9 {
+		t.Fatalf("Expected successful dry run of revision 3 claiming vlan 3, got %+v", upgrade)
+	}
+	if upgrade, err = allocatingPool.upgradeStrategy(nil, map[string]interface{}{}, false); err != nil || !upgrade.Upgraded {
+		t.Fatalf("Expected pool upgraded to revision 3, got %+v %v", upgrade, err)
+	}
+	if pinned, err := allocatingPool.AllocationStrategy(); err != nil || pinned.Script != "third" {
+		t.Fatalf("Expected pool pinned to the third revision, got %v %v", pinned, err)
+	}
+
+	diff, err := DiffStrategyRevisions(ctx, client, strat.ID, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "-first") || !strings.Contains(diff, "+third") {
+		t.Fatalf("Expected diff of the first and the third revision, got %s", diff)
+	}
+	if _, err := DiffStrategyRevisions(ctx, client, strat.ID, 1, 4); err == nil {
+		t.Fatalf("Diff of a missing revision should fail")
+	}
+}
@@ -0,0 +1,235 @@
+package pools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/strategytestcase"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// StrategyTestResult is the outcome of running a single test case of an allocation strategy
+type StrategyTestResult struct {
+	TestCase *ent.StrategyTestCase
+	Passed   bool
+	// Output of the strategy, nil if the strategy failed
+	Output map[string]interface{}
+	// Error the strategy failed with
+	Error *string
+	// Diff between the expected and the actual output or error, nil for passed test cases
+	Diff   *string
+	StdErr string
+}
+
+// StrategyTestCaseFromInput creates an unsaved test case from its GraphQL input
+func StrategyTestCaseFromInput(input model.StrategyTestCaseInput) *ent.StrategyTestCase {
+	testCase := &ent.StrategyTestCase{
+		Name:             input.Name,
+		PoolProperties:   input.PoolProperties,
+		CurrentResources: input.CurrentResources,
+		UserInput:        input.UserInput,
+		ExpectedOutput:   input.ExpectedOutput,
+		ExpectedError:    input.ExpectedError,
+	}
+	if testCase.CurrentResources == nil {
+		testCase.CurrentResources = []map[string]interface{}{}
+	}
+	if testCase.UserInput == nil {
+		testCase.UserInput = map[string]interface{}{}
+	}
+	return testCase
+}
+
+// CreateStrategyTestCases stores test cases of the strategy
+func CreateStrategyTestCases(ctx context.Context, client *ent.Client, strat *ent.AllocationStrategy,
+	testCases []*ent.StrategyTestCase) ([]*ent.StrategyTestCase, error) {
+	created := make([]*ent.StrategyTestCase, 0, len(testCases))
+	for _, testCase := range testCases {
+		if testCase.ExpectedOutput == nil && testCase.ExpectedError == nil {
+			return nil, errors.Errorf("Unable to create test case %s of allocation strategy %s, "+
+				"either expected output or expected error is required", testCase.Name, strat.Name)
+		}
+		if testCase.ExpectedOutput != nil && testCase.ExpectedError != nil {
+			return nil, errors.Errorf("Unable to create test case %s of allocation strategy %s, "+
+				"expected output and expected error are mutually exclusive", testCase.Name, strat.Name)
+		}
+		if testCase.ExpectedError != nil && *testCase.ExpectedError == "" {
+			return nil, errors.Errorf("Unable to create test case %s of allocation strategy %s, "+
+				"expected error must not be empty", testCase.Name, strat.Name)
+		}
+
+		create := client.StrategyTestCase.Create().
+			SetName(testCase.Name).
+			SetPoolProperties(testCase.PoolProperties).
+			SetCurrentResources(testCase.CurrentResources).
+			SetUserInput(testCase.UserInput).
+			SetNillableExpectedError(testCase.ExpectedError).
+			SetAllocationStrategy(strat)
+		if testCase.ExpectedOutput != nil {
+			create.SetExpectedOutput(testCase.ExpectedOutput)
+		}
+		saved, err := create.Save(ctx)
+		if err != nil {
+			log.Error(ctx, err, "Unable to create test case %s of allocation strategy %d", testCase.Name, strat.ID)
+			return nil, errors.Wrapf(err, "Unable to create test case %s of allocation strategy %s",
+				testCase.Name, strat.Name)
+		}
+		created = append(created, saved)
+	}
+	return created, nil
+}
+
+// RunStrategyTests runs all stored test cases of the strategy
+func RunStrategyTests(ctx context.Context, invoker ScriptInvoker, strat *ent.AllocationStrategy) (
+	[]StrategyTestResult, error) {
+	testCases, err := strat.QueryTestCases().
+		Order(ent.Asc(strategytestcase.FieldName)).
+		All(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to load test cases of allocation strategy %d", strat.ID)
+		return nil, errors.Wrapf(err, "Unable to load test cases of allocation strategy %s", strat.Name)
+	}
+	return RunStrategyTestCases(ctx, invoker, strat, testCases), nil
+}
+
+// RunStrategyTestCases runs the test cases against the strategy, which does not need to be stored yet
+func RunStrategyTestCases(ctx context.Context, invoker ScriptInvoker, strat *ent.AllocationStrategy,
+	testCases []*ent.StrategyTestCase) []StrategyTestResult {
+	results := make([]StrategyTestResult, 0, len(testCases))
+	for _, testCase := range testCases {
+		results = append(results, runStrategyTestCase(ctx, invoker, strat, testCase))
+	}
+	return results
+}
+
+// RequirePassingStrategyTests fails unless all the test cases pass against the strategy
+func RequirePassingStrategyTests(ctx context.Context, invoker ScriptInvoker, strat *ent.AllocationStrategy,
+	testCases []*ent.StrategyTestCase) error {
+	var failed []string
+	for _, result := range RunStrategyTestCases(ctx, invoker, strat, testCases) {
+		if !result.Passed {
+			failed = append(failed, result.TestCase.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d test cases of allocation strategy %s failed: %s",
+			len(failed), len(testCases), strat.Name, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func runStrategyTestCase(ctx context.Context, invoker ScriptInvoker, strat *ent.AllocationStrategy,
+	testCase *ent.StrategyTestCase) StrategyTestResult {
+	result := StrategyTestResult{TestCase: testCase}
+	output, stdErr, err := invokeStrategyTestCase(ctx, invoker, strat, testCase)
+	result.StdErr = stdErr
+
+	if err != nil {
+		message := err.Error()
+		result.Error = &message
+		// an empty expected error would match any error
+		if testCase.ExpectedError != nil && *testCase.ExpectedError != "" &&
+			strings.Contains(message, *testCase.ExpectedError) {
+			result.Passed = true
+			return result
+		}
+		expected := "<output>"
+		if testCase.ExpectedError != nil {
+			expected = *testCase.ExpectedError
+		}
+		result.Diff = strategyTestDiff(expected, message)
+		return result
+	}
+
+	result.Output = output
+	if testCase.ExpectedError != nil {
+		result.Diff = strategyTestDiff(*testCase.ExpectedError, indentedJSON(output))
+		return result
+	}
+	expected := normalizedJSON(testCase.ExpectedOutput)
+	if reflect.DeepEqual(expected, normalizedJSON(output)) {
+		result.Passed = true
+		return result
+	}
+	result.Diff = strategyTestDiff(indentedJSON(testCase.ExpectedOutput), indentedJSON(output))
+	return result
+}
+
+func invokeStrategyTestCase(ctx context.Context, invoker ScriptInvoker, strat *ent.AllocationStrategy,
+	testCase *ent.StrategyTestCase) (map[string]interface{}, string, error) {
+	poolProperties, _ := normalizedJSON(testCase.PoolProperties).(map[string]interface{})
+	userInput, _ := normalizedJSON(testCase.UserInput).(map[string]interface{})
+	if poolProperties == nil {
+		poolProperties = map[string]interface{}{}
+	}
+	if userInput == nil {
+		userInput = map[string]interface{}{}
+	}
+
+	updatedAt := time.Now().String()
+	currentResources := make([]*model.ResourceInput, 0, len(testCase.CurrentResources))
+	for _, props := range testCase.CurrentResources {
+		normalized, _ := normalizedJSON(props).(map[string]interface{})
+		currentResources = append(currentResources, &model.ResourceInput{
+			Properties: normalized,
+			Status:     resource.StatusClaimed.String(),
+			UpdatedAt:  updatedAt,
+		})
+	}
+	resourcePool := model.ResourcePoolInput{
+		ResourcePoolName: fmt.Sprintf("%s test %s", strat.Name, testCase.Name),
+		PoolProperties:   poolProperties,
+	}
+
+	functionName := "invoke()"
+	if strat.Lang == allocationstrategy.LangPy {
+		functionName = "script_fun()"
+	}
+	return InvokeAllocationStrategy(
+		ctx, invoker, strat, userInput, resourcePool, currentResources, poolProperties, functionName)
+}
+
+// normalizedJSON converts the value to its JSON representation, so that numbers of any type compare equal
+func normalizedJSON(value interface{}) interface{} {
+	serialized, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(serialized, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+func indentedJSON(value interface{}) string {
+	serialized, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(serialized)
+}
+
+func strategyTestDiff(expected string, actual string) *string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(expected + "\n"),
+		B:        difflib.SplitLines(actual + "\n"),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		diff = fmt.Sprintf("expected:\n%s\nactual:\n%s", expected, actual)
+	}
+	return &diff
+}
@@ -0,0 +1,122 @@
+package pools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/graph/graphql/model"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestRunStrategyTests(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	strat := client.AllocationStrategy.Create().
+		SetName("vlan").
+		SetLang(allocationstrategy.LangGo).
+		SetScript("go").
+		SaveX(ctx)
+
+	fullPool := "Unable to allocate VLAN"
+	testCases := []*ent.StrategyTestCase{
+		StrategyTestCaseFromInput(model.StrategyTestCaseInput{
+			Name:             "next free",
+			PoolProperties:   map[string]interface{}{"from": 1, "to": 10},
+			CurrentResources: []map[string]interface{}{{"vlan": 1}},
+			ExpectedOutput:   map[string]interface{}{"vlan": 2},
+		}),
+		StrategyTestCaseFromInput(model.StrategyTestCaseInput{
+			Name:           "wrong expectation",
+			PoolProperties: map[string]interface{}{"from": 1, "to": 10},
+			ExpectedOutput: map[string]interface{}{"vlan": 5},
+		}),
+		StrategyTestCaseFromInput(model.StrategyTestCaseInput{
+			Name:             "full",
+			PoolProperties:   map[string]interface{}{"from": 1, "to": 1},
+			CurrentResources: []map[string]interface{}{{"vlan": 1}},
+			ExpectedError:    &fullPool,
+		}),
+	}
+	if _, err := CreateStrategyTestCases(ctx, client, strat, []*ent.StrategyTestCase{
+		StrategyTestCaseFromInput(model.StrategyTestCaseInput{Name: "no expectation"}),
+	}); err == nil {
+		t.Fatalf("Creating a test case without expected output or error should fail")
+	}
+	emptyError := ""
+	if _, err := CreateStrategyTestCases(ctx, client, strat, []*ent.StrategyTestCase{
+		StrategyTestCaseFromInput(model.StrategyTestCaseInput{Name: "empty error", ExpectedError: &emptyError}),
+	}); err == nil {
+		t.Fatalf("Creating a test case with an empty expected error should fail")
+	}
+	if result := runStrategyTestCase(ctx, nil, strat, StrategyTestCaseFromInput(model.StrategyTestCaseInput{
+		Name:             "empty error",
+		PoolProperties:   map[string]interface{}{"from": 1, "to": 1},
+		CurrentResources: []map[string]interface{}{{"vlan": 1}},
+		ExpectedError:    &emptyError,
+	})); result.Passed {
+		t.Fatalf("An empty expected error should not match any error")
+	}
+	if _, err := CreateStrategyTestCases(ctx, client, strat, testCases); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunStrategyTests(ctx, nil, strat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed := map[string]StrategyTestResult{}
+	for _, result := range results {
+		passed[result.TestCase.Name] = result
+	}
+	if len(results) != 3 || !passed["next free"].Passed || !passed["full"].Passed {
+		t.Fatalf("Expected next free and full test cases to pass, got %+v", results)
+	}
+	if failed := passed["wrong expectation"]; failed.Passed || failed.Diff == nil ||
+		!strings.Contains(*failed.Diff, `-  "vlan": 5`) || !strings.Contains(*failed.Diff, `+  "vlan": 1`) {
+		t.Fatalf("Expected diff of the wrong expectation, got %+v", failed)
+	}
+	if err := RequirePassingStrategyTests(ctx, nil, strat, testCases); err == nil ||
+		!strings.Contains(err.Error(), "wrong expectation") {
+		t.Fatalf("Expected the wrong expectation test case to fail, got %v", err)
+	}
+}
+
+func TestUpdateAllocationStrategyRequiringPassingTests(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	strat := client.AllocationStrategy.Create().
+		SetName("tested").
+		SetLang(allocationstrategy.LangJs).
+		SetScript("first").
+		SaveX(ctx)
+	invoker := scriptInvoker{"first": 1, "second": 2, "third": 1}
+
+	if _, err := CreateStrategyTestCases(ctx, client, strat, []*ent.StrategyTestCase{
+		StrategyTestCaseFromInput(model.StrategyTestCaseInput{
+			Name:           "first vlan",
+			PoolProperties: map[string]interface{}{},
+			ExpectedOutput: map[string]interface{}{"vlan": 1},
+		}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UpdateAllocationStrategy(ctx, client, strat.ID, nil, "second", nil, invoker); err == nil {
+		t.Fatalf("Updating strategy failing its tests should fail")
+	}
+	if latest, err := LatestStrategyRevision(ctx, client, strat); err != nil || latest.Revision != 1 {
+		t.Fatalf("Expected no revision stored for a failing script, got %v %v", latest, err)
+	}
+	if _, err := UpdateAllocationStrategy(ctx, client, strat.ID, nil, "second", nil, nil); err != nil {
+		t.Fatalf("Updating strategy without requiring tests should succeed, got %v", err)
+	}
+	if _, err := UpdateAllocationStrategy(ctx, client, strat.ID, nil, "third", nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+}
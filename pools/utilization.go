@@ -0,0 +1,144 @@
+package pools
+
+import (
+	"context"
+
+	"github.com/net-auto/resourceManager/ent"
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/net-auto/resourceManager/server/metrics"
+	"github.com/pkg/errors"
+)
+
+// UtilizationEvent describes a pool whose utilization crossed one of its thresholds, in either direction
+type UtilizationEvent struct {
+	PoolID      int
+	PoolName    string
+	Previous    resourcePool.UtilizationLevel
+	Level       resourcePool.UtilizationLevel
+	Utilization float64
+}
+
+func logUtilizationEvent(ctx context.Context, event UtilizationEvent) {
+	if event.Level == resourcePool.UtilizationLevelOk {
+		log.Info(ctx, "Pool \"%s\" (ID %d) utilization %.2f%% is back from %s to %s level",
+			event.PoolName, event.PoolID, event.Utilization, event.Previous, event.Level)
+	} else {
+		log.Warn(ctx, "Pool \"%s\" (ID %d) utilization %.2f%% changed from %s to %s level",
+			event.PoolName, event.PoolID, event.Utilization, event.Previous, event.Level)
+	}
+}
+
+// SetPoolUtilizationThresholds configures warning and critical utilization thresholds of a pool in percent.
+// Nil disables a threshold. The utilization level of the pool is re-evaluated right away.
+func SetPoolUtilizationThresholds(ctx context.Context, client *ent.Client, poolId int,
+	warning *float64, critical *float64) (*ent.ResourcePool, error) {
+	for _, threshold := range []*float64{warning, critical} {
+		if threshold != nil && (*threshold <= 0 || *threshold > 100) {
+			return nil, errors.Errorf("Unable to set utilization thresholds of pool #%d, "+
+				"threshold must be within (0, 100] percent, got %v", poolId, *threshold)
+		}
+	}
+	if warning != nil && critical != nil && *warning > *critical {
+		return nil, errors.Errorf("Unable to set utilization thresholds of pool #%d, "+
+			"warning threshold %v is above critical threshold %v", poolId, *warning, *critical)
+	}
+
+	update := client.ResourcePool.UpdateOneID(poolId)
+	if warning != nil {
+		update.SetUtilizationWarningThreshold(*warning)
+	} else {
+		update.ClearUtilizationWarningThreshold()
+	}
+	if critical != nil {
+		update.SetUtilizationCriticalThreshold(*critical)
+	} else {
+		update.ClearUtilizationCriticalThreshold()
+	}
+	updated, err := update.Save(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to set utilization thresholds of pool ID %d", poolId)
+		return nil, errors.Wrapf(err, "Unable to set utilization thresholds of pool #%d", poolId)
+	}
+
+	pool, err := existingPool(ctx, client, updated)
+	if err != nil {
+		return nil, err
+	}
+	CheckPoolUtilization(pool)
+	return client.ResourcePool.Get(ctx, poolId)
+}
+
+// CheckPoolUtilization compares utilization of a pool, as stored in its capacity counters, against its thresholds.
+// Threshold crossings update the stored level. Utilization is recorded as a metric and crossings are logged
+// only once the transaction running in the pool context commits, so rolled back changes are never reported.
+// Pools without thresholds are skipped. Errors are only logged, they never fail the claim or free.
+func CheckPoolUtilization(pool Pool) {
+	b, ok := pool.(interface{ base() poolBase })
+	if !ok {
+		return
+	}
+	base := b.base()
+
+	// reload the entity, the pool might have been loaded before its thresholds changed
+	entity, err := base.client.ResourcePool.Get(base.ctx, base.ID)
+	if err != nil {
+		log.Warn(base.ctx, "Unable to check utilization of pool ID %d: %v", base.ID, err)
+		return
+	}
+	if entity.UtilizationWarningThreshold == nil && entity.UtilizationCriticalThreshold == nil &&
+		entity.UtilizationLevel == resourcePool.UtilizationLevelOk {
+		return
+	}
+
+	capacity, err := StoredPoolCapacity(base.ctx, base.client, entity)
+	if err != nil {
+		log.Warn(base.ctx, "Unable to check utilization of pool ID %d: %v", base.ID, err)
+		return
+	}
+	utilization := capacity.UtilizationPercentage()
+	level := utilizationLevel(entity, utilization)
+
+	if level == entity.UtilizationLevel {
+		afterCommit(base.ctx, func() {
+			metrics.RecordPoolUtilization(base.ctx, entity.Name, utilization, utilizationLevelValue(level))
+		})
+		return
+	}
+	if err := base.client.ResourcePool.UpdateOneID(entity.ID).SetUtilizationLevel(level).Exec(base.ctx); err != nil {
+		log.Warn(base.ctx, "Unable to store utilization level of pool ID %d: %v", base.ID, err)
+		return
+	}
+	event := UtilizationEvent{
+		PoolID:      entity.ID,
+		PoolName:    entity.Name,
+		Previous:    entity.UtilizationLevel,
+		Level:       level,
+		Utilization: utilization,
+	}
+	afterCommit(base.ctx, func() {
+		metrics.RecordPoolUtilization(base.ctx, entity.Name, utilization, utilizationLevelValue(level))
+		logUtilizationEvent(base.ctx, event)
+	})
+}
+
+func utilizationLevel(pool *ent.ResourcePool, utilization float64) resourcePool.UtilizationLevel {
+	if pool.UtilizationCriticalThreshold != nil && utilization >= *pool.UtilizationCriticalThreshold {
+		return resourcePool.UtilizationLevelCritical
+	}
+	if pool.UtilizationWarningThreshold != nil && utilization >= *pool.UtilizationWarningThreshold {
+		return resourcePool.UtilizationLevelWarning
+	}
+	return resourcePool.UtilizationLevelOk
+}
+
+func utilizationLevelValue(level resourcePool.UtilizationLevel) int64 {
+	switch level {
+	case resourcePool.UtilizationLevelWarning:
+		return 1
+	case resourcePool.UtilizationLevelCritical:
+		return 2
+	default:
+		return 0
+	}
+}
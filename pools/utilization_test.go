@@ -0,0 +1,128 @@
+package pools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/net-auto/resourceManager/ent"
+
+	resourcePool "github.com/net-auto/resourceManager/ent/resourcepool"
+	"github.com/net-auto/resourceManager/ent/schema"
+
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+)
+
+func TestPoolUtilizationThresholds(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+	resType, err := getResourceType(ctx, client)
+	if err != nil {
+		t.Fatalf("Unable to create resource type: %s", err)
+	}
+
+	pool, poolEntity, _ := NewSetPoolWithMeta(ctx, client, resType, []RawResourceProps{
+		RawResourceProps{"vlan": 44},
+		RawResourceProps{"vlan": 45},
+		RawResourceProps{"vlan": 46},
+		RawResourceProps{"vlan": 47},
+	}, "pool", nil, schema.ResourcePoolDealocationImmediately)
+
+	warning, critical := 80.0, 50.0
+	if _, err := SetPoolUtilizationThresholds(ctx, client, poolEntity.ID, &warning, &critical); err == nil {
+		t.Fatalf("Warning threshold above critical threshold should be rejected")
+	}
+	warning = 150
+	if _, err := SetPoolUtilizationThresholds(ctx, client, poolEntity.ID, &warning, nil); err == nil {
+		t.Fatalf("Threshold above 100 percent should be rejected")
+	}
+
+	warning, critical = 50, 75
+	if _, err := SetPoolUtilizationThresholds(ctx, client, poolEntity.ID, &warning, &critical); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := pool.ClaimResources(2, map[string]interface{}{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		t.Fatal(err)
+	}
+	assertUtilizationLevel(t, client.ResourcePool.GetX(ctx, poolEntity.ID).UtilizationLevel, resourcePool.UtilizationLevelWarning)
+
+	if _, err := pool.ClaimResource(map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		t.Fatal(err)
+	}
+	assertUtilizationLevel(t, client.ResourcePool.GetX(ctx, poolEntity.ID).UtilizationLevel, resourcePool.UtilizationLevelCritical)
+
+	if err := pool.FreeResources(nil, []int{claimed[0].ID, claimed[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		t.Fatal(err)
+	}
+	assertUtilizationLevel(t, client.ResourcePool.GetX(ctx, poolEntity.ID).UtilizationLevel, resourcePool.UtilizationLevelOk)
+
+	// disabling thresholds resets the level right away
+	if _, err := pool.ClaimResources(2, map[string]interface{}{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdatePoolCapacity(pool); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := SetPoolUtilizationThresholds(ctx, client, poolEntity.ID, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUtilizationLevel(t, updated.UtilizationLevel, resourcePool.UtilizationLevelOk)
+}
+
+func TestUtilizationReportedAfterCommit(t *testing.T) {
+	ctx := getContext()
+	client := openDb(ctx)
+	defer client.Close()
+
+	reported := 0
+	afterCommit(ctx, func() { reported++ })
+	if reported != 1 {
+		t.Fatalf("Utilization should be reported right away without a transaction")
+	}
+
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterCommit(context.WithValue(ctx, ent.TxCtxKey{}, tx), func() { reported++ })
+	if reported != 1 {
+		t.Fatalf("Utilization should not be reported before the transaction commits")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if reported != 2 {
+		t.Fatalf("Utilization should be reported once the transaction commits")
+	}
+
+	tx, err = client.Tx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterCommit(ent.NewTxContext(ctx, tx), func() { reported++ })
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if reported != 2 {
+		t.Fatalf("Utilization should never be reported for a rolled back transaction")
+	}
+}
+
+func assertUtilizationLevel(t *testing.T, actual resourcePool.UtilizationLevel, expected resourcePool.UtilizationLevel) {
+	if actual != expected {
+		t.Fatalf("Expected utilization level %s, got %s", expected, actual)
+	}
+}
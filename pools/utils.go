@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqljson"
 	log "github.com/net-auto/resourceManager/logging"
 
 	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/predicate"
 	"github.com/net-auto/resourceManager/ent/propertytype"
 	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourcepool"
 	"github.com/pkg/errors"
 )
 
@@ -52,6 +56,29 @@ func CreatePropertyType(
 func PreCreateResources(ctx context.Context, client *ent.Client, propertyValues []RawResourceProps, pool *ent.ResourcePool,
 	resourceType *ent.ResourceType, claimed resource.Status, description *string, alternativeId map[string]interface{}) ([]*ent.Resource, error) {
 
+	// Fail when any resource is already in the pool or listed more than once, before creating any of them
+	seen := make(map[string]bool)
+	for _, rawResourceProps := range propertyValues {
+		key, err := resourceValuesKey(ctx, rawResourceProps)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			err := errors.Errorf("Resource %v is listed more than once", rawResourceProps)
+			log.Error(ctx, err, "Duplicate resource")
+			return nil, err
+		}
+		seen[key] = true
+
+		if exists, err := resourceExistsInPool(ctx, client, pool, resourceType, rawResourceProps); err != nil {
+			return nil, err
+		} else if exists {
+			err := errors.Errorf("Resource %v already exists in pool \"%s\"", rawResourceProps, pool.Name)
+			log.Error(ctx, err, "Duplicate resource")
+			return nil, err
+		}
+	}
+
 	var created []*ent.Resource
 	for _, rawResourceProps := range propertyValues {
 		// Parse & create the props
@@ -61,7 +88,6 @@ func PreCreateResources(ctx context.Context, client *ent.Client, propertyValues
 			return nil, errors.Wrapf(err, "Error parsing properties")
 		}
 
-		// FIXME: fail when this resource is already in DB (same logic as in FreeResource)
 		// Create pre-allocated resource
 		var resource *ent.Resource
 		resource, err = client.Resource.Create().
@@ -82,6 +108,52 @@ func PreCreateResources(ctx context.Context, client *ent.Client, propertyValues
 	return created, nil
 }
 
+// resourceValuesKey serializes property values so that equal values produce the same key regardless of number types
+func resourceValuesKey(ctx context.Context, raw RawResourceProps) (string, error) {
+	values := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		values[k] = v
+	}
+	values, err := ConvertValuesToFloat64(ctx, values)
+	if err != nil {
+		return "", err
+	}
+	key, err := json.Marshal(values)
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to serialize resource %v", raw)
+	}
+	return string(key), nil
+}
+
+// resourceExistsInPool checks whether the pool contains a resource with the same property values in any state
+func resourceExistsInPool(ctx context.Context, client *ent.Client, pool *ent.ResourcePool,
+	resourceType *ent.ResourceType, raw RawResourceProps) (bool, error) {
+	propComparator, err := CompareProps(ctx, resourceType, raw)
+	if err != nil {
+		return false, errors.Wrapf(err, "Unable to check for duplicate resources in pool \"%s\"", pool.Name)
+	}
+
+	query := client.Resource.Query().Where(resource.HasPoolWith(resourcepool.ID(pool.ID)))
+	for _, propPred := range propComparator {
+		query = query.Where(resource.HasPropertiesWith(propPred))
+	}
+	exists, err := query.Exist(ctx)
+	if err != nil {
+		log.Error(ctx, err, "Unable to check for duplicate resources in pool ID %d", pool.ID)
+		return false, errors.Wrapf(err, "Unable to check for duplicate resources in pool \"%s\"", pool.Name)
+	}
+	return exists, nil
+}
+
+// HasAlternativeId matches resources whose alternative ID contains all keys and values of alternativeId
+func HasAlternativeId(alternativeId map[string]interface{}) predicate.Resource {
+	return func(selector *sql.Selector) {
+		for k, v := range alternativeId {
+			selector.Where(sqljson.ValueContains(resource.FieldAlternateID, v, sqljson.Path(k)))
+		}
+	}
+}
+
 func ConvertValuesToFloat64(ctx context.Context, datamap map[string]interface{}) (map[string]interface{}, error) {
 	for k, v := range datamap {
 		switch t := v.(type) {
@@ -105,3 +177,51 @@ func ConvertValuesToFloat64(ctx context.Context, datamap map[string]interface{})
 
 	return datamap, nil
 }
+
+// transactionFromContext returns the transaction running in ctx, either exposed to strategies
+// under ent.TxCtxKey{} or attached by ent.NewTxContext. Nil if there is none.
+func transactionFromContext(ctx context.Context) *ent.Tx {
+	if tx, ok := ctx.Value(ent.TxCtxKey{}).(*ent.Tx); ok && tx != nil {
+		return tx
+	}
+	return ent.TxFromContext(ctx)
+}
+
+// withStrategyTransaction exposes a transaction under ent.TxCtxKey{} for strategies executing their own SQL.
+// The transaction already running in ctx is reused, a new one is opened only if there is none.
+// The returned function finishes the newly opened transaction, it does nothing for a reused one.
+func withStrategyTransaction(ctx context.Context, client *ent.Client) (context.Context, func(), error) {
+	if tx := transactionFromContext(ctx); tx != nil {
+		return context.WithValue(ctx, ent.TxCtxKey{}, tx), func() {}, nil
+	}
+
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	finish := func() {
+		if err := tx.Commit(); err != nil {
+			log.Error(ctx, err, "Unable to commit strategy transaction")
+		}
+	}
+	return context.WithValue(ctx, ent.TxCtxKey{}, tx), finish, nil
+}
+
+// afterCommit runs f once the transaction running in ctx commits, f never runs if the transaction rolls back.
+// Without a transaction in ctx, changes are already committed and f runs right away.
+func afterCommit(ctx context.Context, f func()) {
+	tx := transactionFromContext(ctx)
+	if tx == nil {
+		f()
+		return
+	}
+	tx.OnCommit(func(next ent.Committer) ent.Committer {
+		return ent.CommitFunc(func(ctx context.Context, tx *ent.Tx) error {
+			if err := next.Commit(ctx, tx); err != nil {
+				return err
+			}
+			f()
+			return nil
+		})
+	})
+}
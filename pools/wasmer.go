@@ -16,6 +16,7 @@ import (
 
 	"github.com/net-auto/resourceManager/ent"
 	"github.com/net-auto/resourceManager/ent/allocationstrategy"
+	"github.com/net-auto/resourceManager/ent/resource"
 	"github.com/net-auto/resourceManager/graph/graphql/model"
 	"github.com/pkg/errors"
 )
@@ -34,6 +35,9 @@ const jsBinDefault = "./wasm/quickjs/quickjs.wasm"
 const pyBinDefault = "./wasm/python/bin/python.wasm"
 const pyLibDefault = "wasm/python/lib"
 
+// batchResultKey holds the list of resources allocated by a batch invocation of a strategy
+const batchResultKey = "resources"
+
 func loadEnvVar(key string, defaultValue string) (string, error) {
 	value, found := os.LookupEnv(key)
 	if !found {
@@ -127,6 +131,132 @@ func InvokeAllocationStrategy(
 	}
 }
 
+// InvokeAllocationStrategyBatch runs invoke() of a strategy count times within a single strategy run.
+// Every allocated resource is appended to currentResources before the next round, so that the strategy
+// never allocates the same resource twice.
+func InvokeAllocationStrategyBatch(
+	ctx context.Context,
+	invoker ScriptInvoker,
+	strat *ent.AllocationStrategy,
+	userInput map[string]interface{},
+	resourcePool model.ResourcePoolInput,
+	currentResources []*model.ResourceInput,
+	poolPropertiesMaps map[string]interface{},
+	count int,
+) ([]map[string]interface{}, string, error) {
+
+	// do not let the batch modify resources of the caller
+	currentResources = append([]*model.ResourceInput{}, currentResources...)
+	updatedAt := time.Now().String()
+
+	var output map[string]interface{}
+	var stdErr string
+	var err error
+	switch strat.Lang {
+	case allocationstrategy.LangJs:
+		output, stdErr, err = invoker.invokeJs(strat.Script, userInput, resourcePool, currentResources, poolPropertiesMaps,
+			jsBatchInvocation(count, updatedAt))
+	case allocationstrategy.LangPy:
+		output, stdErr, err = invoker.invokePy(pyBatchScript(strat.Script, count, updatedAt), userInput, resourcePool,
+			currentResources, poolPropertiesMaps, "script_batch_fun()")
+	case allocationstrategy.LangGo:
+		return invokeGoBatch(ctx, strat, userInput, resourcePool, currentResources, poolPropertiesMaps, count, updatedAt)
+	default:
+		err := errors.Errorf("Unknown language \"%s\" for strategy \"%s\"", strat.Lang, strat.Name)
+		log.Error(nil, err, "Unknown strategy language")
+		return nil, "", err
+	}
+
+	if err != nil {
+		return nil, stdErr, err
+	}
+
+	allocated, err := parseBatchOutput(output, count)
+	if err != nil {
+		log.Error(ctx, err, "Unable to parse batch output of strategy \"%s\"", strat.Name)
+		return nil, stdErr, errors.Wrapf(err, "Error output: \"%s\"", stdErr)
+	}
+	return allocated, stdErr, nil
+}
+
+// jsBatchInvocation wraps invoke() into an expression producing {resources: [...]}
+func jsBatchInvocation(count int, updatedAt string) string {
+	return `(function() {
+	const allocated = [];
+	for (let i = 0; i < ` + strconv.Itoa(count) + `; i++) {
+		const allocatedResource = invoke();
+		if (allocatedResource == null) {
+			break;
+		}
+		allocated.push(allocatedResource);
+		currentResources.push({Properties: allocatedResource, Status: "` + resource.StatusClaimed.String() + `", UpdatedAt: ` + strconv.Quote(updatedAt) + `});
+	}
+	return {` + batchResultKey + `: allocated};
+})()`
+}
+
+// pyBatchScript nests a python script into a function invoking it repeatedly, returning {"resources": [...]}
+func pyBatchScript(script string, count int, updatedAt string) string {
+	return `def script_fun():
+` + prefixLines(script, "  ") + `
+allocated = []
+for _ in range(` + strconv.Itoa(count) + `):
+  allocated_resource = script_fun()
+  if allocated_resource is None:
+    break
+  allocated.append(allocated_resource)
+  currentResources.append({"Properties": allocated_resource, "Status": "` + resource.StatusClaimed.String() + `", "UpdatedAt": ` + strconv.Quote(updatedAt) + `})
+return {"` + batchResultKey + `": allocated}
+`
+}
+
+func invokeGoBatch(
+	ctx context.Context,
+	strategy *ent.AllocationStrategy,
+	userInput map[string]interface{},
+	resourcePool model.ResourcePoolInput,
+	currentResources []*model.ResourceInput,
+	poolPropertiesMaps map[string]interface{},
+	count int,
+	updatedAt string,
+) ([]map[string]interface{}, string, error) {
+	var allocated []map[string]interface{}
+	for i := 0; i < count; i++ {
+		output, _, err := invokeGo(ctx, strategy, userInput, resourcePool, currentResources, poolPropertiesMaps, "invoke()")
+		if err != nil {
+			return nil, "", err
+		}
+		allocated = append(allocated, output)
+		currentResources = append(currentResources, &model.ResourceInput{
+			Properties: output,
+			Status:     resource.StatusClaimed.String(),
+			UpdatedAt:  updatedAt,
+		})
+	}
+	return allocated, "", nil
+}
+
+func parseBatchOutput(output map[string]interface{}, count int) ([]map[string]interface{}, error) {
+	rawResources, ok := output[batchResultKey].([]interface{})
+	if !ok {
+		return nil, errors.Errorf("Unable to parse allocation function output, missing \"%s\" list: \"%v\"",
+			batchResultKey, output)
+	}
+	if len(rawResources) != count {
+		return nil, errors.Errorf("Strategy allocated only %d out of %d requested resources", len(rawResources), count)
+	}
+
+	allocated := make([]map[string]interface{}, len(rawResources))
+	for i, rawResource := range rawResources {
+		resourceProperties, ok := rawResource.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("Unable to parse allocated resource: \"%v\"", rawResource)
+		}
+		allocated[i] = resourceProperties
+	}
+	return allocated, nil
+}
+
 func currentResourcesToArray(currentResources []*model.ResourceInput) ([]map[string]interface{}, error) {
 	var mapInterface []map[string]interface{}
 	for _, element := range currentResources {
@@ -163,6 +293,32 @@ func runStrategy(goStrategy GoStrategy, functionName string) (map[string]interfa
 	}
 }
 
+// ResourceValidator is implemented by go strategies able to tell whether an existing resource
+// fits into the pool properties the strategy was created with
+type ResourceValidator interface {
+	Contains(resourceProperties map[string]interface{}) (bool, error)
+}
+
+// FreeRangesLister is implemented by go strategies able to list free ranges of values left in the pool
+type FreeRangesLister interface {
+	FreeRanges() (*strategies.FreeRanges, error)
+}
+
+func newGoStrategy(
+	ctx context.Context,
+	strategy *ent.AllocationStrategy,
+	userInput map[string]interface{},
+	resourcePool model.ResourcePoolInput,
+	currentResourcesArray []map[string]interface{},
+	poolPropertiesMaps map[string]interface{},
+) (GoStrategy, error) {
+	definition, ok := goStrategyDefinition(strategy.Name)
+	if !ok {
+		return nil, errors.Errorf("Go strategy \"%s\" is not registered", strategy.Name)
+	}
+	return definition.Factory(ctx, resourcePool, currentResourcesArray, poolPropertiesMaps, userInput), nil
+}
+
 func invokeGo(
 	ctx context.Context,
 	strategy *ent.AllocationStrategy,
@@ -172,43 +328,17 @@ func invokeGo(
 	poolPropertiesMaps map[string]interface{},
 	functionName string,
 ) (map[string]interface{}, string, error) {
-	var output map[string]interface{}
-	var goStrategy GoStrategy
-
 	currentResourcesArray, err := currentResourcesToArray(currentResources)
 	if err != nil {
 		return nil, "", err
 	}
 	log.Debug(nil, "CurrentResources:\n %s\n poolProperties:\n %s", currentResourcesArray, poolPropertiesMaps)
 
-	switch strategy.Name {
-	case "vlan":
-		// TODO: Pass currentResourcesArray as pointer
-		vlan := strategies.NewVlan(currentResourcesArray, poolPropertiesMaps, userInput)
-		goStrategy = &vlan
-	case "unique_id":
-		id := strategies.NewUniqueId(ctx, resourcePool.ResourcePoolID, poolPropertiesMaps, userInput)
-		goStrategy = &id
-	case "ipv4":
-		// TODO: Pass currentResourcesArray as pointer
-		id := strategies.NewIpv4(currentResourcesArray, poolPropertiesMaps, userInput)
-		goStrategy = &id
-	case "ipv6":
-		// TODO: Pass currentResourcesArray as pointer
-		id := strategies.NewIpv6(currentResourcesArray, poolPropertiesMaps, userInput)
-		goStrategy = &id
-	case "ipv6_prefix":
-		// TODO: Pass currentResourcesArray as pointer
-		id := strategies.NewIpv6Prefix(currentResourcesArray, poolPropertiesMaps, userInput)
-		goStrategy = &id
-	case "ipv4_prefix":
-		// TODO: Pass currentResourcesArray as pointer
-		id := strategies.NewIpv4Prefix(currentResourcesArray, poolPropertiesMaps, userInput)
-		goStrategy = &id
-	default:
-		return nil, "", errors.New("Not known go strategy")
+	goStrategy, err := newGoStrategy(ctx, strategy, userInput, resourcePool, currentResourcesArray, poolPropertiesMaps)
+	if err != nil {
+		return nil, "", err
 	}
-	output, err = runStrategy(goStrategy, functionName)
+	output, err := runStrategy(goStrategy, functionName)
 
 	if err != nil {
 		return nil, "", err
@@ -217,6 +347,86 @@ func invokeGo(
 	return output, "", nil
 }
 
+// newResourceValidator creates the validator of a strategy, nil if the strategy is unable to validate resources
+func newResourceValidator(
+	ctx context.Context,
+	strategy *ent.AllocationStrategy,
+	resourcePool model.ResourcePoolInput,
+	poolPropertiesMaps map[string]interface{},
+) (ResourceValidator, error) {
+	if strategy.Lang != allocationstrategy.LangGo {
+		return nil, nil
+	}
+	goStrategy, err := newGoStrategy(ctx, strategy, map[string]interface{}{}, resourcePool, nil, poolPropertiesMaps)
+	if err != nil {
+		return nil, err
+	}
+	validator, _ := goStrategy.(ResourceValidator)
+	return validator, nil
+}
+
+// ResourcesOutsideOfPoolProperties returns resources that would no longer fit into a pool with poolPropertiesMaps.
+// Only go strategies implementing ResourceValidator are able to answer, other strategies return an error.
+func ResourcesOutsideOfPoolProperties(
+	ctx context.Context,
+	strategy *ent.AllocationStrategy,
+	resourcePool model.ResourcePoolInput,
+	poolPropertiesMaps map[string]interface{},
+	resources []*ent.Resource,
+) ([]*ent.Resource, error) {
+	validator, err := newResourceValidator(ctx, strategy, resourcePool, poolPropertiesMaps)
+	if err != nil {
+		return nil, err
+	}
+	if validator == nil {
+		return nil, errors.Errorf("Allocation strategy \"%s\" is unable to validate existing resources", strategy.Name)
+	}
+
+	var outside []*ent.Resource
+	for _, res := range resources {
+		props, err := PropertiesToMap(res.Edges.Properties)
+		if err != nil {
+			log.Error(ctx, err, "Unable to serialize properties of resource ID %d", res.ID)
+			return nil, errors.Wrapf(err, "Unable to serialize properties of resource #%d", res.ID)
+		}
+		contains, err := validator.Contains(props)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to validate resource #%d", res.ID)
+		}
+		if !contains {
+			outside = append(outside, res)
+		}
+	}
+	return outside, nil
+}
+
+// ListFreeRanges lists maximal free ranges (and CIDR blocks of prefix pools) left in a pool.
+// Only strategies implementing FreeRangesLister are able to answer, other strategies return an error.
+func ListFreeRanges(
+	ctx context.Context,
+	strategy *ent.AllocationStrategy,
+	resourcePool model.ResourcePoolInput,
+	currentResources []*model.ResourceInput,
+	poolPropertiesMaps map[string]interface{},
+) (*strategies.FreeRanges, error) {
+	currentResourcesArray, err := currentResourcesToArray(currentResources)
+	if err != nil {
+		return nil, err
+	}
+
+	// pools pinned to a js revision of a registered go strategy list free ranges in go as well
+	definition, ok := goStrategyDefinition(strategy.Name)
+	if !ok {
+		return nil, errors.Errorf("Allocation strategy \"%s\" is unable to list free ranges", strategy.Name)
+	}
+	lister, _ := definition.Factory(ctx, resourcePool, currentResourcesArray, poolPropertiesMaps,
+		map[string]interface{}{}).(FreeRangesLister)
+	if lister == nil {
+		return nil, errors.Errorf("Allocation strategy \"%s\" is unable to list free ranges", strategy.Name)
+	}
+	return lister.FreeRanges()
+}
+
 func serializeJsVariable(name string, data interface{}) (string, error) {
 	userInputBytes, err := json.Marshal(data)
 	if err != nil {
@@ -0,0 +1,105 @@
+package lease
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/schema"
+	log "github.com/net-auto/resourceManager/logging"
+	"github.com/net-auto/resourceManager/pools"
+	"github.com/net-auto/resourceManager/server/lock"
+)
+
+// Reaper periodically frees claimed resources whose lease has expired and cancels expired reservations
+type Reaper struct {
+	client         *ent.Client
+	lockingService lock.LockingService
+	interval       time.Duration
+}
+
+// NewReaper creates a reaper freeing expired leases every interval, non positive interval disables the reaper.
+// The locking service has to be the one used by the graphql handler so that the reaper and claims do not race.
+func NewReaper(client *ent.Client, lockingService lock.LockingService, interval time.Duration) *Reaper {
+	return &Reaper{client: client, lockingService: lockingService, interval: interval}
+}
+
+// Serve runs the reaper until the context is done
+func (r *Reaper) Serve(ctx context.Context) error {
+	if r.interval <= 0 {
+		log.Info(ctx, "Lease reaper disabled")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := r.Reap(ctx); err != nil {
+				log.Error(ctx, err, "Unable to free resources with expired lease")
+			}
+		}
+	}
+}
+
+// Reap frees all resources with expired lease and cancels expired reservations. Every pool is reaped
+// in its own serializable transaction while holding the pool lock, the same way mutations claim from it.
+// Pools that fail to be reaped are logged and skipped.
+func (r *Reaper) Reap(ctx context.Context) (int, error) {
+	ctx = schema.WithFullAccessIdentity(ctx)
+	poolIds, err := pools.PoolsWithExpiredLeases(ctx, r.client)
+	if err != nil {
+		return 0, err
+	}
+
+	freed, cancelled := 0, 0
+	for _, poolId := range poolIds {
+		poolFreed, poolCancelled, err := r.reapPool(ctx, poolId)
+		if err != nil {
+			log.Error(ctx, err, "Unable to free expired leases in pool ID %d", poolId)
+			continue
+		}
+		freed += poolFreed
+		cancelled += poolCancelled
+	}
+
+	if freed > 0 || cancelled > 0 {
+		log.Info(ctx, "Freed %d resources with expired lease, cancelled %d expired reservations", freed, cancelled)
+	}
+	return freed + cancelled, nil
+}
+
+func (r *Reaper) reapPool(ctx context.Context, poolId int) (int, int, error) {
+	lockName := strconv.Itoa(poolId)
+	r.lockingService.Acquire(lockName).Lock()
+	defer r.lockingService.Unlock(lockName)
+
+	tx, err := r.client.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return 0, 0, err
+	}
+	ctx = ent.NewTxContext(ctx, tx)
+	ctx = context.WithValue(ctx, ent.TxCtxKey{}, tx)
+
+	freed, err := pools.FreeExpiredLeases(ctx, tx.Client(), poolId)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+	cancelled, err := pools.CancelExpiredReservations(ctx, tx.Client(), poolId)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return freed, cancelled, nil
+}
@@ -3,7 +3,13 @@ package lock
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/net-auto/resourceManager/ent"
+	"github.com/net-auto/resourceManager/ent/resource"
+	"github.com/net-auto/resourceManager/ent/resourcepool"
 	log "github.com/net-auto/resourceManager/logging"
 	"github.com/vektah/gqlparser/v2/ast"
 )
@@ -25,41 +31,269 @@ var _ interface {
 } = &LockRequestInterceptor{}
 
 // InterceptResponse intercepts the graphql response.
-// If the response is a mutation, have poolId as argument and is trying to claim resource, it locks the resource pool.
+// If the response is a mutation changing resources or capacity of a pool, it locks the resource pool.
+// Moving a resource locks both the source and the target pool, always in the same order to prevent deadlocks.
+// Admin frees lock every pool of the freed resources.
+// Instantiating a pool template locks the template.
 // We are wrapping the next(ctx) in a lock/unlock pair to ensure that the resource pool is unlocked even if the response is nil.
 // In this response interceptor we are providing also DB read/write safety access when there are multiple concurrent requests/commits.
 func (l *LockRequestInterceptor) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
 	oc := graphql.GetOperationContext(ctx)
 
 	if isMutation(oc) {
-		if isLockable(oc) {
-			poolId, err := getArgument(oc, "poolId")
-			if err != nil {
-				log.Warn(ctx, "Unable to find poolId for query %s. Query will not be locked", oc.OperationName)
-				return next(ctx)
+		poolIds := lockedPoolIds(ctx, oc)
+		if len(poolIds) == 0 {
+			return next(ctx)
+		}
+
+		for _, poolId := range poolIds {
+			l.lockingService.Acquire(poolId).Lock()
+		}
+
+		select {
+		case <-ctx.Done():
+			l.unlockAll(poolIds)
+			log.Warn(ctx, "HTTP request finished before successfully locking. Skipping further executions")
+			return graphql.ErrorResponse(ctx, "Request has been canceled")
+		default:
+			response := next(ctx)
+			l.unlockAll(poolIds)
+			return response
+		}
+	}
+
+	return next(ctx)
+}
+
+func (l *LockRequestInterceptor) unlockAll(poolIds []string) {
+	for i := len(poolIds) - 1; i >= 0; i-- {
+		l.lockingService.Unlock(poolIds[i])
+	}
+}
+
+// lockedPoolIds returns sorted IDs of pools to be locked for the mutation, empty if the mutation does not need locking
+func lockedPoolIds(ctx context.Context, oc *graphql.OperationContext) []string {
+	if isLockable(oc) {
+		poolId, err := getArgument(oc, "poolId")
+		if err != nil {
+			log.Warn(ctx, "Unable to find poolId for query %s. Query will not be locked", oc.OperationName)
+			return nil
+		}
+		return []string{*poolId}
+	}
+
+	if isInputLockable(oc) {
+		poolId, err := inputPoolId(oc)
+		if err != nil {
+			log.Warn(ctx, "Unable to find input poolId for query %s. Query will not be locked", oc.OperationName)
+			return nil
+		}
+		return []string{poolId}
+	}
+
+	if matchesNameAndArgument(oc, "resourceId", "CancelReservation") {
+		poolId, err := sourcePoolId(ctx, oc)
+		if err != nil {
+			log.Warn(ctx, "Unable to find pool of resource for query %s. Query will not be locked: %v", oc.OperationName, err)
+			return nil
+		}
+		return []string{poolId}
+	}
+
+	if isAdminFree(oc) {
+		poolIds, err := resourcesPoolIds(ctx, oc)
+		if err != nil {
+			log.Warn(ctx, "Unable to find pools of resources for query %s. Query will not be locked: %v", oc.OperationName, err)
+			return nil
+		}
+		sort.Strings(poolIds)
+		return poolIds
+	}
+
+	if matchesNameAndArgument(oc, "targetPoolId", "MoveResource") {
+		targetPoolId, err := getArgument(oc, "targetPoolId")
+		if err != nil {
+			log.Warn(ctx, "Unable to find targetPoolId for query %s. Query will not be locked", oc.OperationName)
+			return nil
+		}
+		poolIds := []string{*targetPoolId}
+		if sourcePoolId, err := sourcePoolId(ctx, oc); err != nil {
+			log.Warn(ctx, "Unable to find source pool for query %s. Only target pool will be locked: %v", oc.OperationName, err)
+		} else if sourcePoolId != *targetPoolId {
+			poolIds = append(poolIds, sourcePoolId)
+		}
+		sort.Strings(poolIds)
+		return poolIds
+	}
+
+	// Parent resources of nested pools are claimed from pools created by the same request, those are not visible
+	// to other requests until the transaction commits. Instantiations of the same template are serialized
+	// by a lock of the template, named so that it never clashes with pool locks.
+	if matchesNameAndArgument(oc, "templateId", "InstantiatePoolTemplate") {
+		templateId, err := getArgument(oc, "templateId")
+		if err != nil {
+			log.Warn(ctx, "Unable to find templateId for query %s. Query will not be locked", oc.OperationName)
+			return nil
+		}
+		return []string{"template-" + *templateId}
+	}
+
+	if matchesNameAndArgument(oc, "poolId", "DeleteResourcePoolTree") {
+		poolIds, err := poolTreeIds(ctx, oc)
+		if err != nil {
+			log.Warn(ctx, "Unable to find pool tree for query %s. Query will not be locked: %v", oc.OperationName, err)
+			return nil
+		}
+		sort.Strings(poolIds)
+		return poolIds
+	}
+
+	return nil
+}
+
+// poolTreeIds finds the pool being deleted together with all pools nested in it
+func poolTreeIds(ctx context.Context, oc *graphql.OperationContext) ([]string, error) {
+	client := ent.FromContext(ctx)
+	if client == nil {
+		return nil, fmt.Errorf("no client attached to context")
+	}
+	poolIdArg, err := getArgument(oc, "poolId")
+	if err != nil {
+		return nil, err
+	}
+	poolId, err := strconv.Atoi(*poolIdArg)
+	if err != nil {
+		return nil, err
+	}
+
+	var poolIds []string
+	visited := map[int]bool{}
+	for level := []int{poolId}; len(level) > 0; {
+		for _, id := range level {
+			visited[id] = true
+			poolIds = append(poolIds, strconv.Itoa(id))
+		}
+		nested, err := client.ResourcePool.Query().
+			Where(resourcepool.HasParentResourceWith(resource.HasPoolWith(resourcepool.IDIn(level...)))).
+			IDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		level = level[:0]
+		for _, id := range nested {
+			if !visited[id] {
+				level = append(level, id)
 			}
+		}
+	}
+	return poolIds, nil
+}
+
+// sourcePoolId finds the pool of the resource being moved or cancelled
+func sourcePoolId(ctx context.Context, oc *graphql.OperationContext) (string, error) {
+	client := ent.FromContext(ctx)
+	if client == nil {
+		return "", fmt.Errorf("no client attached to context")
+	}
+	resourceIdArg, err := getArgument(oc, "resourceId")
+	if err != nil {
+		return "", err
+	}
+	resourceId, err := strconv.Atoi(*resourceIdArg)
+	if err != nil {
+		return "", err
+	}
+	poolId, err := client.Resource.Query().Where(resource.ID(resourceId)).QueryPool().OnlyID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(poolId), nil
+}
+
+// resourcesPoolIds finds distinct pools of resources freed by an admin
+func resourcesPoolIds(ctx context.Context, oc *graphql.OperationContext) ([]string, error) {
+	client := ent.FromContext(ctx)
+	if client == nil {
+		return nil, fmt.Errorf("no client attached to context")
+	}
+	resourceIds, err := getListArgument(oc, "resourceIds")
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(resourceIds))
+	for _, resourceIdArg := range resourceIds {
+		resourceId, err := strconv.Atoi(resourceIdArg)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, resourceId)
+	}
+	poolIds, err := client.ResourcePool.Query().Where(resourcepool.HasClaimsWith(resource.IDIn(ids...))).IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(poolIds))
+	for _, poolId := range poolIds {
+		names = append(names, strconv.Itoa(poolId))
+	}
+	return names, nil
+}
 
-			l.lockingService.Acquire(*poolId).Lock()
-
-			select {
-			case <-ctx.Done():
-				l.lockingService.Unlock(*poolId)
-				log.Warn(ctx, "HTTP request finished before successfully locking. Skipping further executions")
-				return graphql.ErrorResponse(ctx, "Request has been canceled")
-			default:
-				response := next(ctx)
-				l.lockingService.Unlock(*poolId)
-				return response
+// getListArgument reads items of a list argument of the first field
+func getListArgument(oc *graphql.OperationContext, propertyName string) ([]string, error) {
+	for _, selection := range oc.Operation.SelectionSet {
+		if field, ok := selection.(*ast.Field); ok {
+			items, ok := field.ArgumentMap(oc.Variables)[propertyName].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot find %s argument", propertyName)
+			}
+			values := make([]string, 0, len(items))
+			for _, item := range items {
+				values = append(values, fmt.Sprintf("%v", item))
 			}
+			return values, nil
 		}
 	}
+	return nil, fmt.Errorf("cannot find %s argument", propertyName)
+}
 
-	return next(ctx)
+// inputPoolId reads poolId of the input object argument
+func inputPoolId(oc *graphql.OperationContext) (string, error) {
+	for _, selection := range oc.Operation.SelectionSet {
+		if field, ok := selection.(*ast.Field); ok {
+			input, ok := field.ArgumentMap(oc.Variables)["input"].(map[string]interface{})
+			if !ok || input["poolId"] == nil {
+				return "", fmt.Errorf("cannot find poolId of input argument")
+			}
+			return fmt.Sprintf("%v", input["poolId"]), nil
+		}
+	}
+	return "", fmt.Errorf("cannot find input argument")
 }
 
 func isLockable(oc *graphql.OperationContext) bool {
 	return matchesNameAndArgument(oc, "poolId", "ClaimResource") ||
-		matchesNameAndArgument(oc, "poolId", "ClaimResourceWithAltId")
+		matchesNameAndArgument(oc, "poolId", "ClaimResourceWithAltId") ||
+		matchesNameAndArgument(oc, "poolId", "ClaimResources") ||
+		matchesNameAndArgument(oc, "poolId", "ReserveResource") ||
+		matchesNameAndArgument(oc, "poolId", "FreeResource") ||
+		matchesNameAndArgument(oc, "poolId", "FreeResources") ||
+		matchesNameAndArgument(oc, "poolId", "RecomputePoolCapacity") ||
+		matchesNameAndArgument(oc, "poolId", "UpgradePoolStrategy")
+}
+
+// isInputLockable checks if the mutation changes a pool identified by poolId of its input argument
+func isInputLockable(oc *graphql.OperationContext) bool {
+	return matchesNameAndArgument(oc, "input", "UpdateResourcePool") ||
+		matchesNameAndArgument(oc, "input", "AddSetPoolValues") ||
+		matchesNameAndArgument(oc, "input", "RemoveSetPoolValues")
+}
+
+// isAdminFree checks if the mutation frees resources identified by their IDs regardless of their pools
+func isAdminFree(oc *graphql.OperationContext) bool {
+	return matchesNameAndArgument(oc, "resourceIds", "UnbenchResources") ||
+		matchesNameAndArgument(oc, "resourceIds", "UnretireResources") ||
+		matchesNameAndArgument(oc, "resourceIds", "ForceFreeResources")
 }
 
 func (l *LockRequestInterceptor) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/net-auto/resourceManager/ent"
+	_ "github.com/net-auto/resourceManager/ent/runtime"
+	"github.com/net-auto/resourceManager/ent/schema"
+	"github.com/net-auto/resourceManager/graph/graphql/generated"
+	"github.com/net-auto/resourceManager/pools"
+	"github.com/vektah/gqlparser/v2"
+)
+
+func operationContext(t *testing.T, query string) *graphql.OperationContext {
+	doc, errs := gqlparser.LoadQuery(generated.NewExecutableSchema(generated.Config{}).Schema(), query)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	return &graphql.OperationContext{Operation: doc.Operations[0], Variables: map[string]interface{}{}}
+}
+
+func TestLockedPoolIds(t *testing.T) {
+	for query, expected := range map[string]string{
+		`mutation { ClaimResource(poolId: 1, userInput: {}) { id } }`:                                   "1",
+		`mutation { FreeResource(poolId: 2, input: {vlan: 4}) }`:                                        "2",
+		`mutation { FreeResources(poolId: 3, resourceIds: [7]) }`:                                       "3",
+		`mutation { RecomputePoolCapacity(poolId: 4) { freeCapacity } }`:                                "4",
+		`mutation { UpgradePoolStrategy(poolId: 5) { upgraded } }`:                                      "5",
+		`mutation { UpdateResourcePool(input: {poolId: 6, description: "d"}) { pool { id } } }`:         "6",
+		`mutation { AddSetPoolValues(input: {poolId: 7, poolValues: [{vlan: 1}]}) { pool { id } } }`:    "7",
+		`mutation { RemoveSetPoolValues(input: {poolId: 8, poolValues: [{vlan: 1}]}) { pool { id } } }`: "8",
+	} {
+		poolIds := lockedPoolIds(context.Background(), operationContext(t, query))
+		if len(poolIds) != 1 || poolIds[0] != expected {
+			t.Fatalf("Expected pool %s to be locked by %s, got %v", expected, query, poolIds)
+		}
+	}
+
+	if poolIds := lockedPoolIds(context.Background(), operationContext(t,
+		`mutation { CreateTag(input: {tagText: "edge"}) { tag { id } } }`)); len(poolIds) != 0 {
+		t.Fatalf("Expected no pool to be locked, got %v", poolIds)
+	}
+}
+
+func TestLockedPoolIdsOfAdminFrees(t *testing.T) {
+	schema.InitializeAdminRoles("OWNER")
+	ctx := schema.WithIdentity(context.Background(), "fb", "fb-user", "OWNER", "network-admin")
+	client, err := ent.Open("sqlite3", "file:lock?mode=memory&cache=shared&_fk=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Schema.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+	propType := client.PropertyType.Create().SetName("vlan").SetType("int").SetIntVal(0).SetMandatory(true).SaveX(ctx)
+	resType := client.ResourceType.Create().SetName("vlan").AddPropertyTypes(propType).SaveX(ctx)
+
+	var resourceIds []int
+	var expected []string
+	for _, name := range []string{"first", "second"} {
+		pool, poolEntity, err := pools.NewSetPoolWithMeta(ctx, client, resType,
+			[]pools.RawResourceProps{{"vlan": 1}}, name, nil, 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := pool.ClaimResource(map[string]interface{}{}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resourceIds = append(resourceIds, res.ID)
+		expected = append(expected, fmt.Sprint(poolEntity.ID))
+	}
+
+	ctx = ent.NewContext(ctx, client)
+	for _, mutation := range []string{"UnbenchResources", "UnretireResources", "ForceFreeResources"} {
+		query := fmt.Sprintf(`mutation { %s(resourceIds: [%d, %d]) }`, mutation, resourceIds[1], resourceIds[0])
+		if poolIds := lockedPoolIds(ctx, operationContext(t, query)); !reflect.DeepEqual(poolIds, expected) {
+			t.Fatalf("Expected pools %v to be locked by %s, got %v", expected, query, poolIds)
+		}
+	}
+}
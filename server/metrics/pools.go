@@ -0,0 +1,63 @@
+// Copyright (c) 2004-present Facebook All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// The following measures are recorded whenever utilization of a pool is checked.
+var (
+	PoolUtilization = stats.Float64(
+		"resource_manager/pool_utilization_percent",
+		"Utilized capacity of a pool in percent",
+		stats.UnitDimensionless,
+	)
+	PoolUtilizationLevel = stats.Int64(
+		"resource_manager/pool_utilization_level",
+		"Utilization level of a pool, 0 ok, 1 warning, 2 critical",
+		stats.UnitDimensionless,
+	)
+)
+
+// Pool is the name of the pool the measure was recorded for.
+var Pool = tag.MustNewKey("pool")
+
+// The following gauges expose the last recorded utilization of every pool.
+var (
+	PoolUtilizationView = &view.View{
+		Name:        PoolUtilization.Name(),
+		Description: PoolUtilization.Description(),
+		TagKeys:     []tag.Key{Pool},
+		Measure:     PoolUtilization,
+		Aggregation: view.LastValue(),
+	}
+	PoolUtilizationLevelView = &view.View{
+		Name:        PoolUtilizationLevel.Name(),
+		Description: PoolUtilizationLevel.Description(),
+		TagKeys:     []tag.Key{Pool},
+		Measure:     PoolUtilizationLevel,
+		Aggregation: view.LastValue(),
+	}
+)
+
+// PoolViews are the pool utilization views, they have to be registered for data to be collected.
+var PoolViews = []*view.View{
+	PoolUtilizationView,
+	PoolUtilizationLevelView,
+}
+
+// RecordPoolUtilization records utilization and utilization level of a pool.
+func RecordPoolUtilization(ctx context.Context, poolName string, utilization float64, level int64) {
+	_ = stats.RecordWithTags(ctx,
+		[]tag.Mutator{tag.Upsert(Pool, poolName)},
+		PoolUtilization.M(utilization),
+		PoolUtilizationLevel.M(level),
+	)
+}
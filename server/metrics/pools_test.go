@@ -0,0 +1,33 @@
+// Copyright (c) 2004-present Facebook All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/net-auto/resourceManager/server/metrics"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestRecordPoolUtilization(t *testing.T) {
+	require.NoError(t, view.Register(metrics.PoolViews...))
+	defer view.Unregister(metrics.PoolViews...)
+
+	metrics.RecordPoolUtilization(context.Background(), "vlans", 42.5, 1)
+	metrics.RecordPoolUtilization(context.Background(), "vlans", 80, 2)
+
+	rows, err := view.RetrieveData(metrics.PoolUtilizationView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, "vlans", rows[0].Tags[0].Value)
+	require.Equal(t, 80.0, rows[0].Data.(*view.LastValueData).Value)
+
+	rows, err = view.RetrieveData(metrics.PoolUtilizationLevelView.Name)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, 2.0, rows[0].Data.(*view.LastValueData).Value)
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/net-auto/resourceManager/graph/graphhttp"
 	logger "github.com/net-auto/resourceManager/logging"
 	"github.com/net-auto/resourceManager/logging/log"
+	"github.com/net-auto/resourceManager/server/lease"
+	"github.com/net-auto/resourceManager/server/lock"
 	"github.com/net-auto/resourceManager/server/metrics"
 	"github.com/net-auto/resourceManager/server/xserver"
 	"github.com/net-auto/resourceManager/telemetry"
@@ -22,6 +24,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gocloud.dev/server/health"
+	"time"
 )
 
 func newApplication(ctx context.Context, flags *cliFlags) (*application, func(), error) {
@@ -38,6 +41,8 @@ func newApplication(ctx context.Context, flags *cliFlags) (*application, func(),
 			"*",
 		),
 		newFixedTenancy,
+		newLeaseReaper,
+		newLockingService,
 		newHealthChecks,
 		metrics.Provider,
 		telemetry.ProvideViewExporter,
@@ -48,6 +53,20 @@ func newApplication(ctx context.Context, flags *cliFlags) (*application, func(),
 	return nil, nil, nil
 }
 
+func newLeaseReaper(ctx context.Context, flags *cliFlags, tenancy viewer.Tenancy, lockingService lock.LockingService,
+	logger *zap.Logger) (*lease.Reaper, error) {
+	client, err := tenancy.ClientFor(ctx, "", logger)
+	if err != nil {
+		return nil, err
+	}
+	return lease.NewReaper(client, lockingService, flags.LeaseInterval), nil
+}
+
+// newLockingService creates pool locks shared by the graphql handler and the lease reaper
+func newLockingService() lock.LockingService {
+	return lock.NewLockingService(time.Minute, nil)
+}
+
 func newHealthChecks(tenancy viewer.Tenancy) []health.Checker {
 	return []health.Checker{tenancy}
 }
@@ -125,5 +144,6 @@ func provideLogger(ctx context.Context, cf *cliFlags) log.Logger {
 func provideViews() []*view.View {
 	views := xserver.DefaultViews()
 	views = append(views, ocsql.DefaultViews...)
+	views = append(views, metrics.PoolViews...)
 	return views
 }
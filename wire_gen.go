@@ -12,6 +12,8 @@ import (
 	"github.com/net-auto/resourceManager/graph/graphhttp"
 	"github.com/net-auto/resourceManager/logging"
 	"github.com/net-auto/resourceManager/logging/log"
+	"github.com/net-auto/resourceManager/server/lease"
+	"github.com/net-auto/resourceManager/server/lock"
 	"github.com/net-auto/resourceManager/server/metrics"
 	"github.com/net-auto/resourceManager/server/xserver"
 	"github.com/net-auto/resourceManager/telemetry"
@@ -20,6 +22,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gocloud.dev/server/health"
+	"time"
 )
 
 import (
@@ -37,11 +40,13 @@ func newApplication(ctx context.Context, flags *cliFlags) (*application, func(),
 	}
 	config := flags.TelemetryConfig
 	v := newHealthChecks(tenancy)
+	lockingService := newLockingService()
 	graphhttpConfig := graphhttp.Config{
-		Tenancy:      tenancy,
-		Logger:       logger,
-		Telemetry:    config,
-		HealthChecks: v,
+		Tenancy:        tenancy,
+		Logger:         logger,
+		Telemetry:      config,
+		HealthChecks:   v,
+		LockingService: lockingService,
 	}
 	server, cleanup, err := graphhttp.NewServer(graphhttpConfig)
 	if err != nil {
@@ -61,12 +66,18 @@ func newApplication(ctx context.Context, flags *cliFlags) (*application, func(),
 	}
 	metricsMetrics := metrics.New(metricsConfig)
 	addr := flags.MetricsAddress
+	reaper, err := newLeaseReaper(ctx, flags, tenancy, lockingService, zapLogger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
 	mainApplication := &application{
 		Logger:      zapLogger,
 		server:      server,
 		addr:        string2,
 		metrics:     metricsMetrics,
 		metricsAddr: addr,
+		reaper:      reaper,
 	}
 	return mainApplication, func() {
 		cleanup()
@@ -75,6 +86,20 @@ func newApplication(ctx context.Context, flags *cliFlags) (*application, func(),
 
 // wire.go:
 
+func newLeaseReaper(ctx context.Context, flags *cliFlags, tenancy viewer.Tenancy, lockingService lock.LockingService,
+	logger *zap.Logger) (*lease.Reaper, error) {
+	client, err := tenancy.ClientFor(ctx, "", logger)
+	if err != nil {
+		return nil, err
+	}
+	return lease.NewReaper(client, lockingService, flags.LeaseInterval), nil
+}
+
+// newLockingService creates pool locks shared by the graphql handler and the lease reaper
+func newLockingService() lock.LockingService {
+	return lock.NewLockingService(time.Minute, nil)
+}
+
 func newHealthChecks(tenancy viewer.Tenancy) []health.Checker {
 	return []health.Checker{tenancy}
 }
@@ -152,5 +177,6 @@ func provideLogger(ctx context.Context, cf *cliFlags) log.Logger {
 func provideViews() []*view.View {
 	views := xserver.DefaultViews()
 	views = append(views, ocsql.DefaultViews...)
+	views = append(views, metrics.PoolViews...)
 	return views
 }